@@ -0,0 +1,165 @@
+// Command bulk exports and imports a postgres table as NDJSON or CSV with pkg/bulk
+//
+//	bulk -op export -dsn postgres://... -table orders -id-column order_uid \
+//	    -columns order_uid,track_number,created_at -format ndjson -file orders.ndjson -checkpoint orders.checkpoint
+//
+//	bulk -op import -dsn postgres://... -table orders -id-column order_uid \
+//	    -columns order_uid,track_number,created_at -file orders.ndjson -on-conflict upsert
+//
+// Values are read as they are stored and written back in the postgres text format, so any column type works.
+// CSV can't tell NULL from empty text: empty cells of text columns are imported as empty strings, of other columns as NULL,
+// use NDJSON to keep NULL text.
+// Run the same command again after a failure to continue from the checkpoint
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/bulk"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/storage/genericport"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/postgres"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+type flags struct {
+	op         string
+	dsn        string
+	table      string
+	idColumn   string
+	columns    string
+	format     string
+	file       string
+	batchSize  int
+	checkpoint string
+	onConflict string
+}
+
+func main() {
+	var f flags
+	flag.StringVar(&f.op, "op", "", "export or import")
+	flag.StringVar(&f.dsn, "dsn", os.Getenv("POSTGRES_DSN"), "postgres DSN, $POSTGRES_DSN by default")
+	flag.StringVar(&f.table, "table", "", "table name")
+	flag.StringVar(&f.idColumn, "id-column", "id", "primary key column")
+	flag.StringVar(&f.columns, "columns", "", "comma-separated columns, the id column included")
+	flag.StringVar(&f.format, "format", string(bulk.FormatNDJSON), "ndjson or csv")
+	flag.StringVar(&f.file, "file", "-", "file to export to or import from, - for stdout/stdin")
+	flag.IntVar(&f.batchSize, "batch", bulk.DefaultBatchSize, "objects per batch and checkpoint")
+	flag.StringVar(&f.checkpoint, "checkpoint", "", "checkpoint file to resume after a failure")
+	flag.StringVar(&f.onConflict, "on-conflict", string(bulk.ConflictFail), "import: fail, skip or upsert")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, f); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "bulk:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, f flags) error {
+	format, err := bulk.ParseFormat(f.format)
+	if err != nil {
+		return err
+	}
+	if f.dsn == "" || f.table == "" || f.columns == "" {
+		return fmt.Errorf("-dsn, -table and -columns are required")
+	}
+	if f.op == "export" && f.checkpoint != "" && f.file == "-" {
+		// a resumed export cuts the output to the checkpoint, stdout can't be cut
+		return fmt.Errorf("-checkpoint of export requires -file, stdout can't be resumed")
+	}
+	columns := strings.Split(f.columns, ",")
+	recordIDColumn = f.idColumn
+
+	pool, err := pgxpool.New(ctx, f.dsn)
+	if err != nil {
+		return fmt.Errorf("unable to connect to postgres: %w", err)
+	}
+	defer pool.Close()
+
+	storage, err := genericport.NewPostgresGenericStorage[string, record](pool, recordTable(f.table, f.idColumn, columns))
+	if err != nil {
+		return err
+	}
+
+	switch f.op {
+	case "export":
+		output, closeOutput, err := openOutput(f.file)
+		if err != nil {
+			return err
+		}
+		defer closeOutput()
+
+		stats, err := bulk.Export[string, record](ctx, storage, output, bulk.ExportOptions{
+			Format:         format,
+			Columns:        columns,
+			BatchSize:      f.batchSize,
+			CheckpointPath: f.checkpoint,
+		})
+		_, _ = fmt.Fprintf(os.Stderr, "exported %d, resumed after %d\n", stats.Exported, stats.Resumed)
+		return err
+
+	case "import":
+		mode, err := bulk.ParseConflictMode(f.onConflict)
+		if err != nil {
+			return err
+		}
+		// text cells of CSV must stay strings, record can't tell them from JSON values itself
+		var stringColumns []string
+		if format == bulk.FormatCSV {
+			if stringColumns, err = textColumns(ctx, pool, f.table); err != nil {
+				return err
+			}
+		}
+		input, closeInput, err := openInput(f.file)
+		if err != nil {
+			return err
+		}
+		defer closeInput()
+
+		stats, err := bulk.Import[string, record](ctx, storage, input, bulk.ImportOptions{
+			Format:         format,
+			StringColumns:  stringColumns,
+			OnConflict:     mode,
+			BatchSize:      f.batchSize,
+			CheckpointPath: f.checkpoint,
+			Transactor:     postgres.NewTransactor(pool, pgx.TxOptions{}),
+		})
+		_, _ = fmt.Fprintf(os.Stderr, "created %d, updated %d, skipped %d, resumed after %d\n",
+			stats.Created, stats.Updated, stats.Skipped, stats.Resumed)
+		return err
+
+	default:
+		return fmt.Errorf("-op must be export or import, got '%s'", f.op)
+	}
+}
+
+func openOutput(path string) (io.Writer, func(), error) {
+	if path == "-" {
+		return os.Stdout, func() {}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening output: %w", err)
+	}
+	return file, func() { _ = file.Close() }, nil
+}
+
+func openInput(path string) (io.Reader, func(), error) {
+	if path == "-" {
+		return os.Stdin, func() {}, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening input: %w", err)
+	}
+	return file, func() { _ = file.Close() }, nil
+}
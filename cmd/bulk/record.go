@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/storage/genericport"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recordIDColumn is the -id-column, run sets it before any record is used.
+// bulk.Import decodes records from zero values with encoding/json, so they can't carry it themselves
+var recordIDColumn string
+
+// record is a row of any table: column name - value
+type record struct {
+	values map[string]any
+}
+
+func (r record) GetUniqueIdentifier() string {
+	return fmt.Sprint(r.values[recordIDColumn])
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.values)
+}
+
+func (r *record) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&r.values); err != nil {
+		return err
+	}
+	if r.values[recordIDColumn] == nil {
+		return fmt.Errorf("id column '%s' is missing", recordIDColumn)
+	}
+	return nil
+}
+
+// recordTable describes the table for genericport.PostgresGenericStorage
+func recordTable(table string, idColumn string, columns []string) genericport.PostgresTable[record] {
+	return genericport.PostgresTable[record]{
+		Name:     table,
+		IDColumn: idColumn,
+		Columns:  columns,
+		Values: func(r *record) []any {
+			values := make([]any, len(columns))
+			for i, column := range columns {
+				values[i] = toText(r.values[column])
+			}
+			return values
+		},
+		Scan: func(row pgx.Row) (*record, error) {
+			values := make([]any, len(columns))
+			dest := make([]any, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := row.Scan(dest...); err != nil {
+				return nil, err
+			}
+
+			r := &record{values: make(map[string]any, len(columns))}
+			for i, column := range columns {
+				r.values[column] = fromPostgres(values[i])
+			}
+			return r, nil
+		},
+	}
+}
+
+// textColumns returns the columns of table with a text type. record has no typed fields, so CSV import
+// is told that their cells are strings: an empty cell is an empty string and text like null isn't parsed as JSON
+func textColumns(ctx context.Context, pool *pgxpool.Pool, table string) ([]string, error) {
+	schema, name, found := strings.Cut(table, ".")
+	if !found {
+		schema, name = "", table
+	}
+
+	rows, err := pool.Query(ctx, `SELECT column_name FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2
+		AND (data_type IN ('text', 'character varying', 'character') OR udt_name = 'citext')`, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading column types: %w", err)
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("error reading column types: %w", err)
+	}
+	return columns, nil
+}
+
+// fromPostgres converts scanned values that don't have a JSON form matching the postgres text format
+func fromPostgres(value any) any {
+	switch v := value.(type) {
+	case [16]byte:
+		return uuid.UUID(v).String()
+	case []byte:
+		return `\x` + hex.EncodeToString(v)
+	default:
+		return value
+	}
+}
+
+// toText converts a decoded JSON value into the postgres text format, pgx sends strings as text for any column type
+func toText(value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
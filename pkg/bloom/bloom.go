@@ -0,0 +1,81 @@
+// Package bloom is a Bloom filter kept in memory or in a redis bitmap,
+// and a genericports.GenericStoragePort guard that answers not found for IDs that were never created
+//
+//	filter, err := bloom.NewMemoryFilter(bloom.NewParams(1_000_000, 0.01))
+//	orders := bloom.NewGuard[string, models.Order](ordersPostgres, filter, bloom.GuardOptions[string]{})
+//	if err := orders.Rebuild(ctx); err != nil { ... } // fill with existing IDs
+//	go orders.Run(ctx, time.Hour) // and rebuild periodically to forget deleted ones
+package bloom
+
+import (
+	"context"
+	"github.com/cespare/xxhash/v2"
+	"math"
+)
+
+// Filter is a set that can tell that a key was definitely never added
+type Filter interface {
+	// Add adds keys to the filter
+	Add(ctx context.Context, keys ...string) error
+	// MayContain returns false if key was definitely never added, true means "probably added"
+	MayContain(ctx context.Context, key string) (bool, error)
+	// StartRebuild starts filling a new empty filter, keys added with Add meanwhile go into both.
+	// Rebuilder.Commit replaces the current filter with the new one
+	StartRebuild(ctx context.Context) (Rebuilder, error)
+}
+
+// Rebuilder fills a new filter started by Filter.StartRebuild
+type Rebuilder interface {
+	// Add adds keys to the new filter only
+	Add(ctx context.Context, keys ...string) error
+	// Commit replaces the current filter with the new one
+	Commit(ctx context.Context) error
+	// Abort drops the new filter
+	Abort(ctx context.Context) error
+}
+
+// Params are the size of a filter
+type Params struct {
+	// Bits is the size of the bitmap
+	Bits uint64
+	// Hashes is the amount of bits set per key
+	Hashes uint64
+}
+
+// NewParams calculates optimal Params for the expected amount of keys and the false positive rate, e.g. 0.01
+func NewParams(expectedKeys uint64, falsePositiveRate float64) Params {
+	if expectedKeys == 0 {
+		expectedKeys = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	n := float64(expectedKeys)
+	bits := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Round(bits / n * math.Ln2)
+
+	return Params{Bits: uint64(math.Max(bits, 64)), Hashes: uint64(math.Max(hashes, 1))}
+}
+
+// locations returns indexes of the bits of key, double hashing of one xxhash
+func (p Params) locations(key string) []uint64 {
+	h1 := xxhash.Sum64String(key)
+	h2 := mix(h1) | 1
+
+	result := make([]uint64, p.Hashes)
+	for i := range result {
+		result[i] = (h1 + uint64(i)*h2) % p.Bits
+	}
+	return result
+}
+
+// mix is the splitmix64 finalizer
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,159 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+// DefaultRebuildBatchSize is the amount of keys sent to Rebuilder.Add at once if not set
+const DefaultRebuildBatchSize = 1000
+
+// GuardOptions configure Guard
+type GuardOptions[I comparable] struct {
+	// Key converts ID to filter key, fmt.Sprint if nil
+	Key func(id I) string
+	// RebuildBatchSize is the amount of keys sent to Rebuilder.Add at once, DefaultRebuildBatchSize by default
+	RebuildBatchSize int
+}
+
+// Guard - implement genericports.GenericStoragePort in front of another one
+//
+// GetObjectByID, UpdateObject and DeleteObject of an ID that was never created return genericports.ErrNotFound
+// without calling the storage. CreateObject adds the ID to the filter before creating.
+//
+// The filter only grows, so deleted IDs pass until the next Rebuild.
+//
+// Reads fail open: if the filter fails, GetObjectByID, UpdateObject and DeleteObject go to the storage.
+// CreateObject fails closed: if the ID can't be added, nothing is created and the filter error is returned,
+// because an object missing from the filter would be reported as not found later
+type Guard[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	next   genericports.GenericStoragePort[I, T]
+	filter Filter
+
+	key       func(id I) string
+	batchSize int
+}
+
+// NewGuard creates a new instance of Guard, fill the filter with Rebuild before use
+func NewGuard[I comparable, T genericports.ObjectWithIdentifier[I]](next genericports.GenericStoragePort[I, T], filter Filter, opts GuardOptions[I]) *Guard[I, T] {
+	if opts.Key == nil {
+		opts.Key = func(id I) string { return fmt.Sprint(id) }
+	}
+	if opts.RebuildBatchSize <= 0 {
+		opts.RebuildBatchSize = DefaultRebuildBatchSize
+	}
+	return &Guard[I, T]{next: next, filter: filter, key: opts.Key, batchSize: opts.RebuildBatchSize}
+}
+
+// definitelyMissing tells if id was never created, filter errors are logged and mean "maybe created"
+func (g *Guard[I, T]) definitelyMissing(ctx context.Context, id I) bool {
+	mayContain, err := g.filter.MayContain(ctx, g.key(id))
+	if err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "bloom filter read failed, asking storage", zap.Error(err))
+		return false
+	}
+	return !mayContain
+}
+
+// Rebuild replaces the filter with IDs of all objects in the storage, soft-deleted included
+//
+// Objects created meanwhile are added to both filters, so they're never lost
+func (g *Guard[I, T]) Rebuild(ctx context.Context) (err error) {
+	rebuilder, err := g.filter.StartRebuild(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, rebuilder.Abort(context.WithoutCancel(ctx)))
+		}
+	}()
+
+	objects, err := g.next.GetObjects(genericports.WithDeleted(ctx))
+	if err != nil {
+		return fmt.Errorf("error getting objects for bloom filter: %w", err)
+	}
+
+	keys := make([]string, 0, g.batchSize)
+	for i, object := range objects {
+		keys = append(keys, g.key((*object).GetUniqueIdentifier()))
+		if len(keys) == g.batchSize || i == len(objects)-1 {
+			if err = rebuilder.Add(ctx, keys...); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	return rebuilder.Commit(ctx)
+}
+
+// Run rebuilds the filter every interval until ctx is done.
+// With a shared filter only one replica rebuilds at a time, the others get ErrRebuildInProgress and skip
+func (g *Guard[I, T]) Run(ctx context.Context, interval time.Duration) {
+	l := logger.GetOrCreateLoggerFromCtx(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.Info(ctx, "bloom filter rebuilds stopped")
+			return
+		case <-ticker.C:
+			err := g.Rebuild(ctx)
+			switch {
+			case errors.Is(err, ErrRebuildInProgress):
+				l.Debug(ctx, "bloom filter is rebuilt by another replica")
+			case err != nil && ctx.Err() == nil:
+				l.Error(ctx, "bloom filter rebuild failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// GetObjects - impl genericports.GenericStoragePort.GetObjects
+func (g *Guard[I, T]) GetObjects(ctx context.Context) ([]*T, error) {
+	return g.next.GetObjects(ctx)
+}
+
+// GetObjectByID - impl genericports.GenericStoragePort.GetObjectByID
+func (g *Guard[I, T]) GetObjectByID(ctx context.Context, id I) (*T, error) {
+	if g.definitelyMissing(ctx, id) {
+		return nil, fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+	return g.next.GetObjectByID(ctx, id)
+}
+
+// CreateObject - impl genericports.GenericStoragePort.CreateObject
+//
+// The ID is added before creating: a failed create only costs a false positive,
+// while adding after would hide the object from concurrent reads
+func (g *Guard[I, T]) CreateObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	if err := g.filter.Add(ctx, g.key((*fullyReadyObject).GetUniqueIdentifier())); err != nil {
+		return nil, err
+	}
+	return g.next.CreateObject(ctx, fullyReadyObject)
+}
+
+// UpdateObject - impl genericports.GenericStoragePort.UpdateObject
+func (g *Guard[I, T]) UpdateObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	id := (*fullyReadyObject).GetUniqueIdentifier()
+	if g.definitelyMissing(ctx, id) {
+		return nil, fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+	return g.next.UpdateObject(ctx, fullyReadyObject)
+}
+
+// DeleteObject - impl genericports.GenericStoragePort.DeleteObject
+func (g *Guard[I, T]) DeleteObject(ctx context.Context, id I) error {
+	if g.definitelyMissing(ctx, id) {
+		return fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+	return g.next.DeleteObject(ctx, id)
+}
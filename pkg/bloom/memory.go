@@ -0,0 +1,132 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrRebuildInProgress describes an error when a rebuild is started while another one isn't finished
+var ErrRebuildInProgress = errors.New("bloom filter rebuild is already in progress")
+
+// ErrRebuildFinished describes an error when a committed or aborted rebuild is used
+var ErrRebuildFinished = errors.New("bloom filter rebuild is already finished")
+
+// MemoryFilter - implement Filter in process memory
+type MemoryFilter struct {
+	mu      sync.RWMutex
+	params  Params
+	bits    []uint64
+	rebuild []uint64
+}
+
+// NewMemoryFilter creates a new empty instance of MemoryFilter
+//
+// Returns ErrInvalidParams if params.Bits or params.Hashes is 0
+func NewMemoryFilter(params Params) (*MemoryFilter, error) {
+	if params.Bits == 0 || params.Hashes == 0 {
+		return nil, fmt.Errorf("%w: bits and hashes must be > 0, got %+v", ErrInvalidParams, params)
+	}
+	return &MemoryFilter{params: params, bits: newBits(params)}, nil
+}
+
+func newBits(params Params) []uint64 {
+	return make([]uint64, (params.Bits+63)/64)
+}
+
+func setBits(bits []uint64, locations []uint64) {
+	for _, location := range locations {
+		bits[location/64] |= 1 << (location % 64)
+	}
+}
+
+// Add - impl Filter.Add
+func (f *MemoryFilter) Add(_ context.Context, keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range keys {
+		locations := f.params.locations(key)
+		setBits(f.bits, locations)
+		if f.rebuild != nil {
+			setBits(f.rebuild, locations)
+		}
+	}
+	return nil
+}
+
+// MayContain - impl Filter.MayContain
+func (f *MemoryFilter) MayContain(_ context.Context, key string) (bool, error) {
+	locations := f.params.locations(key)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, location := range locations {
+		if f.bits[location/64]&(1<<(location%64)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// StartRebuild - impl Filter.StartRebuild
+func (f *MemoryFilter) StartRebuild(_ context.Context) (Rebuilder, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.rebuild != nil {
+		return nil, ErrRebuildInProgress
+	}
+	f.rebuild = newBits(f.params)
+	return &memoryRebuilder{filter: f, bits: f.rebuild}, nil
+}
+
+type memoryRebuilder struct {
+	filter *MemoryFilter
+	bits   []uint64
+}
+
+// active must be called under the filter lock
+func (r *memoryRebuilder) active() error {
+	if r.filter.rebuild == nil || &r.filter.rebuild[0] != &r.bits[0] {
+		return ErrRebuildFinished
+	}
+	return nil
+}
+
+func (r *memoryRebuilder) Add(_ context.Context, keys ...string) error {
+	r.filter.mu.Lock()
+	defer r.filter.mu.Unlock()
+
+	if err := r.active(); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		setBits(r.bits, r.filter.params.locations(key))
+	}
+	return nil
+}
+
+func (r *memoryRebuilder) Commit(_ context.Context) error {
+	r.filter.mu.Lock()
+	defer r.filter.mu.Unlock()
+
+	if err := r.active(); err != nil {
+		return err
+	}
+	r.filter.bits, r.filter.rebuild = r.bits, nil
+	return nil
+}
+
+func (r *memoryRebuilder) Abort(_ context.Context) error {
+	r.filter.mu.Lock()
+	defer r.filter.mu.Unlock()
+
+	if err := r.active(); err != nil {
+		return err
+	}
+	r.filter.rebuild = nil
+	return nil
+}
@@ -0,0 +1,206 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ErrInvalidParams describes an error when Params can't be used by a filter
+var ErrInvalidParams = errors.New("invalid bloom filter params")
+
+// maxRedisBits is the max size of a redis bitmap
+const maxRedisBits = 1 << 32
+
+// DefaultRebuildTTL is the expiration of an unfinished rebuild of RedisFilter if rebuildTTL <= 0
+const DefaultRebuildTTL = time.Hour
+
+var (
+	redisAddScript = redis.NewScript(`
+local rebuilding = redis.call('EXISTS', KEYS[2]) == 1
+for i = 1, #ARGV do
+    redis.call('SETBIT', KEYS[1], ARGV[i], 1)
+    if rebuilding then
+        redis.call('SETBIT', KEYS[2], ARGV[i], 1)
+    end
+end
+return 1`)
+
+	redisMayContainScript = redis.NewScript(`
+for i = 1, #ARGV do
+    if redis.call('GETBIT', KEYS[1], ARGV[i]) == 0 then
+        return 0
+    end
+end
+return 1`)
+
+	// KEYS: rebuild, owner; ARGV: last bit, ttl ms, owner token
+	redisStartRebuildScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+    return 0
+end
+redis.call('SETBIT', KEYS[1], ARGV[1], 0)
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+redis.call('SET', KEYS[2], ARGV[3], 'PX', ARGV[2])
+return 1`)
+
+	// KEYS: rebuild, owner; ARGV: owner token, bits...
+	redisRebuildAddScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] or redis.call('EXISTS', KEYS[1]) == 0 then
+    return 0
+end
+for i = 2, #ARGV do
+    redis.call('SETBIT', KEYS[1], ARGV[i], 1)
+end
+return 1`)
+
+	// KEYS: filter, rebuild, owner; ARGV: owner token
+	redisCommitScript = redis.NewScript(`
+if redis.call('GET', KEYS[3]) ~= ARGV[1] or redis.call('EXISTS', KEYS[2]) == 0 then
+    return 0
+end
+redis.call('RENAME', KEYS[2], KEYS[1])
+redis.call('PERSIST', KEYS[1])
+redis.call('DEL', KEYS[3])
+return 1`)
+
+	// KEYS: rebuild, owner; ARGV: owner token
+	redisAbortScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+    return 0
+end
+return redis.call('DEL', KEYS[1], KEYS[2]) - 1`)
+)
+
+// RedisFilter - implement Filter in a redis bitmap shared by all replicas
+//
+// All replicas must use the same key and Params.
+// Keys are "{key}", "{key}:rebuild" and "{key}:rebuild:owner", so they're in the same cluster slot.
+// The owner key holds the token of the replica that started the rebuild,
+// so a rebuild that has expired and was started again by another replica can't be changed by the first one
+type RedisFilter struct {
+	client     redis.Cmdable
+	params     Params
+	key        string
+	rebuildKey string
+	ownerKey   string
+	rebuildTTL time.Duration
+}
+
+// NewRedisFilter creates a new instance of RedisFilter
+//
+// An unfinished rebuild (e.g. the replica crashed) expires after rebuildTTL, DefaultRebuildTTL if <= 0.
+//
+// Returns ErrInvalidParams if params.Bits is bigger than a redis bitmap (2^32)
+func NewRedisFilter(client redis.Cmdable, key string, params Params, rebuildTTL time.Duration) (*RedisFilter, error) {
+	if params.Bits == 0 || params.Bits > maxRedisBits || params.Hashes == 0 {
+		return nil, fmt.Errorf("%w: bits must be in 1..2^32 and hashes > 0, got %+v", ErrInvalidParams, params)
+	}
+	if rebuildTTL <= 0 {
+		rebuildTTL = DefaultRebuildTTL
+	}
+
+	key = "{" + key + "}"
+	return &RedisFilter{
+		client:     client,
+		params:     params,
+		key:        key,
+		rebuildKey: key + ":rebuild",
+		ownerKey:   key + ":rebuild:owner",
+		rebuildTTL: rebuildTTL,
+	}, nil
+}
+
+// args returns bit locations of keys as script arguments
+func (f *RedisFilter) args(keys []string) []any {
+	args := make([]any, 0, len(keys)*int(f.params.Hashes))
+	for _, key := range keys {
+		for _, location := range f.params.locations(key) {
+			args = append(args, location)
+		}
+	}
+	return args
+}
+
+// Add - impl Filter.Add
+func (f *RedisFilter) Add(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := redisAddScript.Run(ctx, f.client, []string{f.key, f.rebuildKey}, f.args(keys)...).Err(); err != nil {
+		return fmt.Errorf("error adding to redis bloom filter: %w", err)
+	}
+	return nil
+}
+
+// MayContain - impl Filter.MayContain
+func (f *RedisFilter) MayContain(ctx context.Context, key string) (bool, error) {
+	found, err := redisMayContainScript.Run(ctx, f.client, []string{f.key}, f.args([]string{key})...).Int()
+	if err != nil {
+		return false, fmt.Errorf("error reading redis bloom filter: %w", err)
+	}
+	return found == 1, nil
+}
+
+// StartRebuild - impl Filter.StartRebuild
+//
+// Returns ErrRebuildInProgress if any replica is rebuilding the filter
+func (f *RedisFilter) StartRebuild(ctx context.Context) (Rebuilder, error) {
+	token := uuid.NewString()
+	started, err := redisStartRebuildScript.Run(ctx, f.client, []string{f.rebuildKey, f.ownerKey},
+		f.params.Bits-1, f.rebuildTTL.Milliseconds(), token).Int()
+	if err != nil {
+		return nil, fmt.Errorf("error starting redis bloom filter rebuild: %w", err)
+	}
+	if started == 0 {
+		return nil, ErrRebuildInProgress
+	}
+	return &redisRebuilder{filter: f, token: token}, nil
+}
+
+type redisRebuilder struct {
+	filter *RedisFilter
+	// token is stored in the owner key while this rebuild is the current one
+	token string
+}
+
+func (r *redisRebuilder) Add(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := append([]any{r.token}, r.filter.args(keys)...)
+	added, err := redisRebuildAddScript.Run(ctx, r.filter.client, []string{r.filter.rebuildKey, r.filter.ownerKey}, args...).Int()
+	if err != nil {
+		return fmt.Errorf("error adding to redis bloom filter rebuild: %w", err)
+	}
+	if added == 0 {
+		return ErrRebuildFinished
+	}
+	return nil
+}
+
+func (r *redisRebuilder) Commit(ctx context.Context) error {
+	committed, err := redisCommitScript.Run(ctx, r.filter.client,
+		[]string{r.filter.key, r.filter.rebuildKey, r.filter.ownerKey}, r.token).Int()
+	if err != nil {
+		return fmt.Errorf("error committing redis bloom filter rebuild: %w", err)
+	}
+	if committed == 0 {
+		return ErrRebuildFinished
+	}
+	return nil
+}
+
+func (r *redisRebuilder) Abort(ctx context.Context) error {
+	deleted, err := redisAbortScript.Run(ctx, r.filter.client, []string{r.filter.rebuildKey, r.filter.ownerKey}, r.token).Int()
+	if err != nil {
+		return fmt.Errorf("error aborting redis bloom filter rebuild: %w", err)
+	}
+	if deleted == 0 {
+		return ErrRebuildFinished
+	}
+	return nil
+}
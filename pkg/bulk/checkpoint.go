@@ -0,0 +1,86 @@
+package bulk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint is the progress of an export or import, saved after every batch
+type Checkpoint struct {
+	// Records is the amount of records already exported or imported
+	Records int `json:"records"`
+	// LastKey is fmt.Sprint of the ID of the last exported object, export of a storage without paging only
+	LastKey string `json:"last_key,omitempty"`
+	// LastID is the JSON of the ID of the last exported object, export of a genericports.PagedStoragePort only
+	LastID json.RawMessage `json:"last_id,omitempty"`
+	// Offset is the output size after the last exported object, export only
+	Offset int64 `json:"offset,omitempty"`
+}
+
+// LoadCheckpoint reads a checkpoint file, zero Checkpoint if there's no file
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+	if path == "" {
+		return checkpoint, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return checkpoint, fmt.Errorf("error reading checkpoint: %w", err)
+	}
+	if err = json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("error parsing checkpoint '%s': %w", path, err)
+	}
+	return checkpoint, nil
+}
+
+// saveCheckpoint writes checkpoint atomically: into a temp file that is renamed then
+func saveCheckpoint(path string, checkpoint Checkpoint) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating checkpoint: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error saving checkpoint: %w", err)
+	}
+	return nil
+}
+
+// removeCheckpoint deletes the checkpoint of a finished run
+func removeCheckpoint(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing checkpoint: %w", err)
+	}
+	return nil
+}
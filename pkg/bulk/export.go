@@ -0,0 +1,251 @@
+// Package bulk exports and imports all objects of a genericports.GenericStoragePort as NDJSON or CSV
+//
+//	file, _ := os.OpenFile("orders.ndjson", os.O_CREATE|os.O_WRONLY, 0o644)
+//	stats, err := bulk.Export(ctx, orders, file, bulk.ExportOptions{CheckpointPath: "orders.export.checkpoint"})
+//
+//	file, _ := os.Open("orders.ndjson")
+//	stats, err := bulk.Import(ctx, orders, file, bulk.ImportOptions{OnConflict: bulk.ConflictUpsert, Transactor: transactor})
+//
+// After a failure run the same call again: with the same checkpoint file it continues where it stopped
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"io"
+	"slices"
+	"strings"
+)
+
+// DefaultBatchSize is used if batch size isn't set
+const DefaultBatchSize = 1000
+
+// ExportOptions configure Export
+type ExportOptions struct {
+	// Format of the output, FormatNDJSON by default
+	Format Format
+	// Columns of FormatCSV, all JSON fields of the type by default
+	Columns []string
+	// BatchSize is the amount of objects written between checkpoints and the page size of
+	// a genericports.PagedStoragePort, DefaultBatchSize by default
+	BatchSize int
+	// CheckpointPath is the checkpoint file, no checkpoints if empty. It's removed after a successful export.
+	//
+	// To resume, the output must be an *os.File (or any Truncater) opened without O_TRUNC and O_APPEND:
+	// whatever was written after the checkpoint is cut off
+	CheckpointPath string
+}
+
+// ExportStats is the result of Export
+type ExportStats struct {
+	// Exported is the amount of objects written in this run
+	Exported int
+	// Resumed is the amount of objects exported by previous runs before the checkpoint
+	Resumed int
+}
+
+// Truncater is an output that can be cut to the checkpoint, e.g. *os.File
+type Truncater interface {
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// countingWriter counts written bytes for Checkpoint.Offset
+type countingWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Export writes all objects of storage into w
+//
+// A genericports.PagedStoragePort is read page by page in the order of IDs, a page per batch, so memory doesn't grow
+// with the collection and a resumed export continues after the last written ID. Other storages are read whole
+// with GetObjects and sorted by fmt.Sprint of IDs on every run, use them for small collections only.
+//
+// Objects created after the checkpoint with IDs before the last written one aren't exported by the resumed run
+func Export[I comparable, T genericports.ObjectWithIdentifier[I]](ctx context.Context, storage genericports.GenericStoragePort[I, T], w io.Writer, opts ExportOptions) (ExportStats, error) {
+	var stats ExportStats
+	if opts.Format == "" {
+		opts.Format = FormatNDJSON
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	checkpoint, err := LoadCheckpoint(opts.CheckpointPath)
+	if err != nil {
+		return stats, err
+	}
+
+	if err = rewind(w, checkpoint); err != nil {
+		return stats, err
+	}
+	output := &countingWriter{w: w, written: checkpoint.Offset}
+
+	writer, err := newRecordWriter[T](opts.Format, output, opts.Columns, checkpoint.Records == 0)
+	if err != nil {
+		return stats, err
+	}
+
+	e := &exporter[T]{
+		writer:     writer,
+		output:     output,
+		path:       opts.CheckpointPath,
+		checkpoint: checkpoint,
+		stats:      ExportStats{Resumed: checkpoint.Records},
+	}
+	if paged, ok := storage.(genericports.PagedStoragePort[I, T]); ok {
+		err = exportPages(ctx, paged, e, opts.BatchSize)
+	} else {
+		err = exportAll(ctx, storage, e, opts.BatchSize)
+	}
+	if err != nil {
+		return e.stats, err
+	}
+
+	if err = writer.flush(); err != nil {
+		return e.stats, fmt.Errorf("error flushing output: %w", err)
+	}
+	return e.stats, removeCheckpoint(opts.CheckpointPath)
+}
+
+// exportPages writes objects of storage page by page after Checkpoint.LastID
+func exportPages[I comparable, T genericports.ObjectWithIdentifier[I]](ctx context.Context, storage genericports.PagedStoragePort[I, T], e *exporter[T], batchSize int) error {
+	var afterID *I
+	if e.checkpoint.Records > 0 {
+		if len(e.checkpoint.LastID) == 0 {
+			return fmt.Errorf("checkpoint '%s' has no last id, it was saved by an export without paging", e.path)
+		}
+		var id I
+		if err := json.Unmarshal(e.checkpoint.LastID, &id); err != nil {
+			return fmt.Errorf("error parsing last id of checkpoint '%s': %w", e.path, err)
+		}
+		afterID = &id
+	}
+
+	for {
+		page, err := storage.GetObjectsAfter(ctx, afterID, batchSize)
+		if err != nil {
+			return fmt.Errorf("error getting objects: %w", err)
+		}
+		for _, object := range page {
+			id := (*object).GetUniqueIdentifier()
+			if err = e.write(object, fmt.Sprint(id)); err != nil {
+				return err
+			}
+			afterID = &id
+		}
+
+		if len(page) > 0 {
+			if e.checkpoint.LastID, err = json.Marshal(*afterID); err != nil {
+				return fmt.Errorf("error encoding id '%v' for checkpoint: %w", *afterID, err)
+			}
+			if err = e.commit(); err != nil {
+				return err
+			}
+		}
+		if len(page) < batchSize {
+			return nil
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// exportAll writes objects of storage without paging, sorted by fmt.Sprint of IDs, after Checkpoint.LastKey
+func exportAll[I comparable, T genericports.ObjectWithIdentifier[I]](ctx context.Context, storage genericports.GenericStoragePort[I, T], e *exporter[T], batchSize int) error {
+	objects, err := storage.GetObjects(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting objects: %w", err)
+	}
+
+	keys := make([]string, len(objects))
+	order := make([]int, len(objects))
+	for i, object := range objects {
+		keys[i] = fmt.Sprint((*object).GetUniqueIdentifier())
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return strings.Compare(keys[a], keys[b]) })
+
+	batch := 0
+	for _, i := range order {
+		if e.checkpoint.Records > 0 && keys[i] <= e.checkpoint.LastKey {
+			continue
+		}
+
+		if err = e.write(objects[i], keys[i]); err != nil {
+			return err
+		}
+		e.checkpoint.LastKey = keys[i]
+
+		if batch++; batch == batchSize {
+			batch = 0
+			if err = e.commit(); err != nil {
+				return err
+			}
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exporter writes objects and saves the checkpoint after every batch
+type exporter[T any] struct {
+	writer     recordWriter[T]
+	output     *countingWriter
+	path       string
+	checkpoint Checkpoint
+	stats      ExportStats
+}
+
+// write writes one object, key is used in errors
+func (e *exporter[T]) write(object *T, key string) error {
+	if err := e.writer.write(object); err != nil {
+		return fmt.Errorf("error writing object '%s': %w", key, err)
+	}
+	e.stats.Exported++
+	e.checkpoint.Records++
+	return nil
+}
+
+// commit flushes the output and saves the checkpoint at its end
+func (e *exporter[T]) commit() error {
+	if err := e.writer.flush(); err != nil {
+		return fmt.Errorf("error flushing output: %w", err)
+	}
+	e.checkpoint.Offset = e.output.written
+	return saveCheckpoint(e.path, e.checkpoint)
+}
+
+// rewind cuts the output to the checkpoint on resume, so objects written after it aren't duplicated.
+// A fresh export doesn't touch the output: pipes and stdout are *os.File too, but they can't be truncated
+func rewind(w io.Writer, checkpoint Checkpoint) error {
+	if checkpoint.Records == 0 {
+		return nil
+	}
+	truncater, ok := w.(Truncater)
+	if !ok {
+		return fmt.Errorf("export can be resumed only into a Truncater output, got %T", w)
+	}
+
+	offset := checkpoint.Offset
+	if err := truncater.Truncate(offset); err != nil {
+		return fmt.Errorf("error truncating output to checkpoint: %w", err)
+	}
+	if _, err := truncater.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking output to checkpoint: %w", err)
+	}
+	return nil
+}
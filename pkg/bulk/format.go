@@ -0,0 +1,288 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// ErrUnknownFormat describes an error when the format is neither FormatNDJSON nor FormatCSV
+var ErrUnknownFormat = errors.New("unknown bulk format")
+
+// ErrInvalidRecord describes an error when a record can't be encoded or decoded
+var ErrInvalidRecord = errors.New("invalid record")
+
+// Format is the file format of export and import
+type Format string
+
+const (
+	// FormatNDJSON is one JSON object per line
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV is a header row with JSON field names, then one row per object.
+	//
+	// String fields are written as is, other fields as JSON (numbers, booleans, nested objects),
+	// null and missing fields as an empty cell
+	FormatCSV Format = "csv"
+)
+
+// ParseFormat returns Format by its name, ErrUnknownFormat if there's no such one
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatNDJSON, FormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownFormat, name)
+	}
+}
+
+type recordWriter[T any] interface {
+	write(object *T) error
+	flush() error
+}
+
+type recordReader[T any] interface {
+	// read returns io.EOF when there are no more records
+	read() (*T, error)
+}
+
+func newRecordWriter[T any](format Format, w io.Writer, columns []string, header bool) (recordWriter[T], error) {
+	switch format {
+	case FormatNDJSON:
+		buffered := bufio.NewWriter(w)
+		return &ndjsonWriter[T]{buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	case FormatCSV:
+		if len(columns) == 0 {
+			columns = fieldsOf[T]().names
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("%w: csv columns can't be detected, set them explicitly", ErrInvalidRecord)
+		}
+		return &csvWriter[T]{writer: csv.NewWriter(w), columns: columns, header: header}, nil
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownFormat, format)
+	}
+}
+
+func newRecordReader[T any](format Format, r io.Reader, stringColumns []string) (recordReader[T], error) {
+	switch format {
+	case FormatNDJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		return &ndjsonReader[T]{decoder: decoder}, nil
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.ReuseRecord = true
+		fields := fieldsOf[T]()
+		for _, column := range stringColumns {
+			fields.isString[column] = true
+		}
+		return &csvReader[T]{reader: reader, fields: fields}, nil
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownFormat, format)
+	}
+}
+
+//region ndjson
+
+type ndjsonWriter[T any] struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *ndjsonWriter[T]) write(object *T) error {
+	if err := w.encoder.Encode(object); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+	return nil
+}
+
+func (w *ndjsonWriter[T]) flush() error {
+	return w.buffered.Flush()
+}
+
+type ndjsonReader[T any] struct {
+	decoder *json.Decoder
+}
+
+func (r *ndjsonReader[T]) read() (*T, error) {
+	var object T
+	if err := r.decoder.Decode(&object); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+	return &object, nil
+}
+
+//endregion
+
+//region csv
+
+type csvWriter[T any] struct {
+	writer  *csv.Writer
+	columns []string
+	header  bool
+	row     []string
+}
+
+func (w *csvWriter[T]) write(object *T) error {
+	if w.header {
+		if err := w.writer.Write(w.columns); err != nil {
+			return err
+		}
+		w.header = false
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("%w: csv requires a JSON object: %w", ErrInvalidRecord, err)
+	}
+
+	w.row = w.row[:0]
+	for _, column := range w.columns {
+		raw, ok := fields[column]
+		switch {
+		case !ok || string(raw) == "null":
+			w.row = append(w.row, "")
+		case raw[0] == '"':
+			var s string
+			if err = json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("%w: field '%s': %w", ErrInvalidRecord, column, err)
+			}
+			w.row = append(w.row, s)
+		default:
+			w.row = append(w.row, string(raw))
+		}
+	}
+	return w.writer.Write(w.row)
+}
+
+func (w *csvWriter[T]) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type csvReader[T any] struct {
+	reader  *csv.Reader
+	fields  fields
+	columns []string
+}
+
+func (r *csvReader[T]) read() (*T, error) {
+	if r.columns == nil {
+		header, err := r.reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("%w: csv header: %w", ErrInvalidRecord, err)
+		}
+		r.columns = append(make([]string, 0, len(header)), header...)
+	}
+
+	row, err := r.reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	document := make(map[string]json.RawMessage, len(row))
+	for i, cell := range row {
+		column := r.columns[i]
+		isString, known := r.fields.isString[column]
+
+		switch {
+		case isString:
+			document[column], _ = json.Marshal(cell)
+		case cell == "":
+			// null, omitted
+		case known:
+			if !json.Valid([]byte(cell)) {
+				return nil, fmt.Errorf("%w: field '%s' must be a JSON value, got '%s'", ErrInvalidRecord, column, cell)
+			}
+			document[column] = json.RawMessage(cell)
+		case json.Valid([]byte(cell)):
+			document[column] = json.RawMessage(cell)
+		default:
+			document[column], _ = json.Marshal(cell)
+		}
+	}
+
+	data, _ := json.Marshal(document)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var object T
+	if err = decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+	return &object, nil
+}
+
+//endregion
+
+// fields are the top-level JSON fields of a type
+type fields struct {
+	// names in the struct order
+	names []string
+	// isString tells if the field is encoded as a JSON string, maps, slices and interfaces are unknown
+	isString map[string]bool
+}
+
+// fieldsOf detects JSON fields of struct T, types with a custom json.Marshaler have no known fields
+func fieldsOf[T any]() fields {
+	result := fields{isString: make(map[string]bool)}
+
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct || typ.Implements(marshalerType) || reflect.PointerTo(typ).Implements(marshalerType) {
+		return result
+	}
+	collectFields(typ, &result)
+	return result
+}
+
+var marshalerType = reflect.TypeFor[json.Marshaler]()
+
+func collectFields(typ reflect.Type, result *fields) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			collectFields(field.Type, result)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		// pointers are allocated, so only maps, slices and interfaces are unknown
+		sample := reflect.New(field.Type).Elem()
+		if field.Type.Kind() == reflect.Pointer {
+			sample = reflect.New(field.Type.Elem())
+		}
+
+		result.names = append(result.names, name)
+		if data, err := json.Marshal(sample.Interface()); err == nil && string(data) != "null" {
+			result.isString[name] = data[0] == '"'
+		}
+	}
+}
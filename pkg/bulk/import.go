@@ -0,0 +1,182 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"io"
+)
+
+// ConflictMode tells Import what to do with objects whose ID already exists
+type ConflictMode string
+
+const (
+	// ConflictFail stops the import with genericports.ErrConflict
+	ConflictFail ConflictMode = "fail"
+	// ConflictSkip keeps the stored object
+	ConflictSkip ConflictMode = "skip"
+	// ConflictUpsert replaces the stored object with UpdateObject
+	ConflictUpsert ConflictMode = "upsert"
+)
+
+// ParseConflictMode returns ConflictMode by its name
+func ParseConflictMode(name string) (ConflictMode, error) {
+	switch mode := ConflictMode(name); mode {
+	case ConflictFail, ConflictSkip, ConflictUpsert:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown conflict mode '%s'", name)
+	}
+}
+
+// ImportOptions configure Import
+type ImportOptions struct {
+	// Format of the input, FormatNDJSON by default
+	Format Format
+	// StringColumns are FormatCSV columns whose cells are always strings, an empty cell is an empty string.
+	// Fields of structs are detected, set it for types with a custom json.Marshaler:
+	// cells of their unknown columns are JSON values if they parse as JSON, empty ones are null
+	StringColumns []string
+	// OnConflict is ConflictFail by default
+	OnConflict ConflictMode
+	// BatchSize is the amount of objects written between checkpoints, DefaultBatchSize by default
+	BatchSize int
+	// CheckpointPath is the checkpoint file, no checkpoints if empty. It's removed after a successful import
+	CheckpointPath string
+	// Transactor writes every batch in one transaction if set, so a checkpoint always matches stored data.
+	// Without it a failed batch is partially written and written again on resume: with ConflictFail
+	// the resume fails with genericports.ErrConflict on its first written record, resume with ConflictSkip then
+	Transactor genericports.Transactor
+}
+
+// ImportStats is the result of Import
+type ImportStats struct {
+	Created int
+	Updated int
+	Skipped int
+	// Resumed is the amount of records skipped because they were imported before the checkpoint
+	Resumed int
+}
+
+// Import reads objects from r and writes them into storage
+//
+// Existing IDs are looked up with genericports.WithDeleted, so soft-deleted objects count as conflicts
+func Import[I comparable, T genericports.ObjectWithIdentifier[I]](ctx context.Context, storage genericports.GenericStoragePort[I, T], r io.Reader, opts ImportOptions) (ImportStats, error) {
+	var stats ImportStats
+	if opts.Format == "" {
+		opts.Format = FormatNDJSON
+	}
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictFail
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	checkpoint, err := LoadCheckpoint(opts.CheckpointPath)
+	if err != nil {
+		return stats, err
+	}
+
+	reader, err := newRecordReader[T](opts.Format, r, opts.StringColumns)
+	if err != nil {
+		return stats, err
+	}
+
+	record := 0
+	for {
+		batch := make([]*T, 0, opts.BatchSize)
+		for len(batch) < opts.BatchSize {
+			object, err := reader.read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			record++
+			if err != nil {
+				return stats, fmt.Errorf("error reading record %d: %w", record, err)
+			}
+			if record <= checkpoint.Records {
+				stats.Resumed++
+				continue
+			}
+			batch = append(batch, object)
+		}
+
+		if len(batch) == 0 {
+			return stats, removeCheckpoint(opts.CheckpointPath)
+		}
+
+		var batchStats ImportStats
+		write := func(ctx context.Context) error {
+			batchStats = ImportStats{}
+			for i, object := range batch {
+				if err := importObject(ctx, storage, object, opts.OnConflict, &batchStats); err != nil {
+					return fmt.Errorf("error importing record %d: %w", record-len(batch)+i+1, err)
+				}
+			}
+			return nil
+		}
+
+		if opts.Transactor != nil {
+			err = opts.Transactor.WithinTransaction(ctx, write)
+		} else {
+			err = write(ctx)
+		}
+		// a failed transaction wrote nothing
+		if err == nil || opts.Transactor == nil {
+			stats.Created += batchStats.Created
+			stats.Updated += batchStats.Updated
+			stats.Skipped += batchStats.Skipped
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		checkpoint.Records = record
+		if err = saveCheckpoint(opts.CheckpointPath, checkpoint); err != nil {
+			return stats, err
+		}
+		if err = ctx.Err(); err != nil {
+			return stats, err
+		}
+	}
+}
+
+// importObject creates object or resolves the conflict by mode.
+//
+// Existence is checked before creating because a failed insert aborts a postgres transaction
+func importObject[I comparable, T genericports.ObjectWithIdentifier[I]](ctx context.Context, storage genericports.GenericStoragePort[I, T], object *T, mode ConflictMode, stats *ImportStats) error {
+	if mode != ConflictFail {
+		_, err := storage.GetObjectByID(genericports.WithDeleted(ctx), (*object).GetUniqueIdentifier())
+		switch {
+		case err == nil:
+			return resolveConflict(ctx, storage, object, mode, stats)
+		case !errors.Is(err, genericports.ErrNotFound):
+			return err
+		}
+	}
+
+	_, err := storage.CreateObject(ctx, object)
+	if errors.Is(err, genericports.ErrConflict) && mode != ConflictFail {
+		// created concurrently
+		return resolveConflict(ctx, storage, object, mode, stats)
+	}
+	if err != nil {
+		return err
+	}
+	stats.Created++
+	return nil
+}
+
+func resolveConflict[I comparable, T genericports.ObjectWithIdentifier[I]](ctx context.Context, storage genericports.GenericStoragePort[I, T], object *T, mode ConflictMode, stats *ImportStats) error {
+	if mode == ConflictSkip {
+		stats.Skipped++
+		return nil
+	}
+	if _, err := storage.UpdateObject(genericports.WithDeleted(ctx), object); err != nil {
+		return err
+	}
+	stats.Updated++
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package chaos wraps ports with injected latency, errors and timeouts to test resilience
+//
+//	injector := chaos.NewInjector(42, chaos.Faults{
+//	    Latency:   chaos.Normal(50*time.Millisecond, 20*time.Millisecond),
+//	    ErrorRate: 0.1,
+//	    Errors:    []error{genericports.ErrNotFound},
+//	})
+//	injector.SetOperationFaults("create_object", chaos.Faults{TimeoutRate: 0.5, Timeout: time.Second})
+//	var orders genericports.GenericStoragePort[string, models.Order] = chaos.NewStorage(ordersPostgres, injector)
+//
+//	injector.Disable() // and back to normal at runtime
+//
+// The same seed and the same sequence of calls give the same faults
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjected is wrapped by every injected error
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Latency is a distribution of injected delays
+type Latency interface {
+	Sample(rng *rand.Rand) time.Duration
+}
+
+// LatencyFunc - implement Latency with a function
+type LatencyFunc func(rng *rand.Rand) time.Duration
+
+// Sample - impl Latency.Sample
+func (f LatencyFunc) Sample(rng *rand.Rand) time.Duration {
+	return f(rng)
+}
+
+// Fixed is always the same delay
+func Fixed(delay time.Duration) Latency {
+	return LatencyFunc(func(*rand.Rand) time.Duration { return delay })
+}
+
+// Uniform is a delay uniformly distributed in [from, to)
+func Uniform(from, to time.Duration) Latency {
+	return LatencyFunc(func(rng *rand.Rand) time.Duration {
+		if to <= from {
+			return from
+		}
+		return from + time.Duration(rng.Int64N(int64(to-from)))
+	})
+}
+
+// Normal is a normally distributed delay, negative samples are 0
+func Normal(mean, stddev time.Duration) Latency {
+	return LatencyFunc(func(rng *rand.Rand) time.Duration {
+		return max(0, mean+time.Duration(rng.NormFloat64()*float64(stddev)))
+	})
+}
+
+// Exponential is an exponentially distributed delay with given mean, most calls are fast, some are very slow
+func Exponential(mean time.Duration) Latency {
+	return LatencyFunc(func(rng *rand.Rand) time.Duration {
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	})
+}
+
+// Faults describe what is injected into a call
+type Faults struct {
+	// Latency is added before the call, nil = no delay
+	Latency Latency
+	// TimeoutRate is the probability [0, 1] of a call that hangs until Timeout (or ctx is done)
+	// and returns context.DeadlineExceeded without calling the port
+	TimeoutRate float64
+	// Timeout of the hanging call, 0 = fail immediately
+	Timeout time.Duration
+	// ErrorRate is the probability [0, 1] of a call that returns an error without calling the port
+	ErrorRate float64
+	// Errors are chosen uniformly for a failed call, e.g. genericports.ErrNotFound, ErrInjected if empty.
+	// Injected errors wrap both ErrInjected and the chosen error
+	Errors []error
+}
+
+// Injector decides which faults to inject, it's safe to share between decorators and to change at runtime
+type Injector struct {
+	enabled atomic.Bool
+
+	mu         sync.Mutex
+	rng        *rand.Rand
+	faults     Faults
+	operations map[string]Faults
+	injected   map[string]int
+}
+
+// NewInjector creates a new enabled instance of Injector with a seeded RNG and faults for all operations
+func NewInjector(seed uint64, faults Faults) *Injector {
+	i := &Injector{
+		rng:        rand.New(rand.NewPCG(seed, seed)),
+		faults:     faults,
+		operations: make(map[string]Faults),
+		injected:   make(map[string]int),
+	}
+	i.enabled.Store(true)
+	return i
+}
+
+// Enable turns faults on
+func (i *Injector) Enable() {
+	i.enabled.Store(true)
+}
+
+// Disable turns faults off, calls go to the port as is
+func (i *Injector) Disable() {
+	i.enabled.Store(false)
+}
+
+// Enabled tells if faults are injected
+func (i *Injector) Enabled() bool {
+	return i.enabled.Load()
+}
+
+// SetFaults replaces faults of operations that have no own faults
+func (i *Injector) SetFaults(faults Faults) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = faults
+}
+
+// SetOperationFaults replaces faults of one operation, e.g. "get_object_by_id", "set", "consume".
+// Operation names are the snake_case method names of the port
+func (i *Injector) SetOperationFaults(operation string, faults Faults) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.operations[operation] = faults
+}
+
+// ResetOperationFaults makes operation use the common faults again
+func (i *Injector) ResetOperationFaults(operation string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.operations, operation)
+}
+
+// Injected returns the amount of injected errors and timeouts by operation
+func (i *Injector) Injected() map[string]int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	result := make(map[string]int, len(i.injected))
+	for operation, amount := range i.injected {
+		result[operation] = amount
+	}
+	return result
+}
+
+// decision is what to do with one call, drawn under the lock so the sequence is reproducible
+type decision struct {
+	delay   time.Duration
+	timeout bool
+	wait    time.Duration
+	err     error
+}
+
+func (i *Injector) decide(operation string) decision {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	faults, ok := i.operations[operation]
+	if !ok {
+		faults = i.faults
+	}
+
+	var d decision
+	if faults.Latency != nil {
+		d.delay = faults.Latency.Sample(i.rng)
+	}
+	if faults.TimeoutRate > 0 && i.rng.Float64() < faults.TimeoutRate {
+		d.timeout, d.wait = true, faults.Timeout
+		i.injected[operation]++
+		return d
+	}
+	if faults.ErrorRate > 0 && i.rng.Float64() < faults.ErrorRate {
+		d.err = ErrInjected
+		if len(faults.Errors) > 0 {
+			d.err = fmt.Errorf("%w: %w", ErrInjected, faults.Errors[i.rng.IntN(len(faults.Errors))])
+		}
+		i.injected[operation]++
+	}
+	return d
+}
+
+// inject delays the call and returns an error if the port mustn't be called
+func (i *Injector) inject(ctx context.Context, operation string) error {
+	if !i.Enabled() {
+		return nil
+	}
+
+	d := i.decide(operation)
+	if err := wait(ctx, d.delay); err != nil {
+		return err
+	}
+	if d.timeout {
+		if err := wait(ctx, d.wait); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrInjected, context.DeadlineExceeded)
+	}
+	return d.err
+}
+
+// wait sleeps for delay or until ctx is done
+func wait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,212 @@
+package chaos
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"time"
+)
+
+//region genericports.GenericStoragePort
+
+type storage[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	injector *Injector
+	next     genericports.GenericStoragePort[I, T]
+}
+
+// NewStorage wraps genericports.GenericStoragePort with faults of injector
+func NewStorage[I comparable, T genericports.ObjectWithIdentifier[I]](next genericports.GenericStoragePort[I, T], injector *Injector) genericports.GenericStoragePort[I, T] {
+	return &storage[I, T]{injector: injector, next: next}
+}
+
+func (s *storage[I, T]) GetObjects(ctx context.Context) ([]*T, error) {
+	if err := s.injector.inject(ctx, "get_objects"); err != nil {
+		return nil, err
+	}
+	return s.next.GetObjects(ctx)
+}
+
+func (s *storage[I, T]) GetObjectByID(ctx context.Context, id I) (*T, error) {
+	if err := s.injector.inject(ctx, "get_object_by_id"); err != nil {
+		return nil, err
+	}
+	return s.next.GetObjectByID(ctx, id)
+}
+
+func (s *storage[I, T]) CreateObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	if err := s.injector.inject(ctx, "create_object"); err != nil {
+		return nil, err
+	}
+	return s.next.CreateObject(ctx, fullyReadyObject)
+}
+
+func (s *storage[I, T]) UpdateObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	if err := s.injector.inject(ctx, "update_object"); err != nil {
+		return nil, err
+	}
+	return s.next.UpdateObject(ctx, fullyReadyObject)
+}
+
+func (s *storage[I, T]) DeleteObject(ctx context.Context, id I) error {
+	if err := s.injector.inject(ctx, "delete_object"); err != nil {
+		return err
+	}
+	return s.next.DeleteObject(ctx, id)
+}
+
+//endregion
+
+//region genericports.GenericCachePort
+
+type cachePort[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	injector *Injector
+	next     genericports.GenericCachePort[I, T]
+}
+
+// NewCachePort wraps genericports.GenericCachePort with faults of injector
+func NewCachePort[I comparable, T genericports.ObjectWithIdentifier[I]](next genericports.GenericCachePort[I, T], injector *Injector) genericports.GenericCachePort[I, T] {
+	return &cachePort[I, T]{injector: injector, next: next}
+}
+
+func (c *cachePort[I, T]) GetObjectByID(ctx context.Context, id I) (*T, error) {
+	if err := c.injector.inject(ctx, "get_object_by_id"); err != nil {
+		return nil, err
+	}
+	return c.next.GetObjectByID(ctx, id)
+}
+
+func (c *cachePort[I, T]) SaveObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	if err := c.injector.inject(ctx, "save_object"); err != nil {
+		return nil, err
+	}
+	return c.next.SaveObject(ctx, fullyReadyObject)
+}
+
+func (c *cachePort[I, T]) DeleteObject(ctx context.Context, id I) error {
+	if err := c.injector.inject(ctx, "delete_object"); err != nil {
+		return err
+	}
+	return c.next.DeleteObject(ctx, id)
+}
+
+//endregion
+
+//region pkgports.Cache
+
+type cache[K comparable, V any] struct {
+	injector *Injector
+	next     pkgports.Cache[K, V]
+}
+
+// NewCache wraps pkgports.Cache with faults of injector
+func NewCache[K comparable, V any](next pkgports.Cache[K, V], injector *Injector) pkgports.Cache[K, V] {
+	return &cache[K, V]{injector: injector, next: next}
+}
+
+func (c *cache[K, V]) Set(ctx context.Context, key K, value V) error {
+	if err := c.injector.inject(ctx, "set"); err != nil {
+		return err
+	}
+	return c.next.Set(ctx, key, value)
+}
+
+func (c *cache[K, V]) SetWithTTL(ctx context.Context, key K, value V, ttl time.Duration) error {
+	if err := c.injector.inject(ctx, "set_with_ttl"); err != nil {
+		return err
+	}
+	return c.next.SetWithTTL(ctx, key, value, ttl)
+}
+
+func (c *cache[K, V]) Get(ctx context.Context, key K) (V, bool, error) {
+	if err := c.injector.inject(ctx, "get"); err != nil {
+		var empty V
+		return empty, false, err
+	}
+	return c.next.Get(ctx, key)
+}
+
+func (c *cache[K, V]) Peek(ctx context.Context, key K) (V, bool, error) {
+	if err := c.injector.inject(ctx, "peek"); err != nil {
+		var empty V
+		return empty, false, err
+	}
+	return c.next.Peek(ctx, key)
+}
+
+func (c *cache[K, V]) Contains(ctx context.Context, key K) (bool, error) {
+	if err := c.injector.inject(ctx, "contains"); err != nil {
+		return false, err
+	}
+	return c.next.Contains(ctx, key)
+}
+
+func (c *cache[K, V]) Delete(ctx context.Context, key K) error {
+	if err := c.injector.inject(ctx, "delete"); err != nil {
+		return err
+	}
+	return c.next.Delete(ctx, key)
+}
+
+func (c *cache[K, V]) Clear(ctx context.Context) error {
+	if err := c.injector.inject(ctx, "clear"); err != nil {
+		return err
+	}
+	return c.next.Clear(ctx)
+}
+
+func (c *cache[K, V]) GetKeys(ctx context.Context) ([]K, error) {
+	if err := c.injector.inject(ctx, "get_keys"); err != nil {
+		return nil, err
+	}
+	return c.next.GetKeys(ctx)
+}
+
+func (c *cache[K, V]) GetKeysAmount(ctx context.Context) (int, error) {
+	if err := c.injector.inject(ctx, "get_keys_amount"); err != nil {
+		return 0, err
+	}
+	return c.next.GetKeysAmount(ctx)
+}
+
+//endregion
+
+//region pkgports.Receiver
+
+type receiver[V, M any] struct {
+	injector *Injector
+	next     pkgports.Receiver[V, M]
+}
+
+// NewReceiver wraps pkgports.Receiver with faults of injector
+//
+// A failed Consume doesn't read a message, so nothing is lost
+func NewReceiver[V, M any](next pkgports.Receiver[V, M], injector *Injector) pkgports.Receiver[V, M] {
+	return &receiver[V, M]{injector: injector, next: next}
+}
+
+func (r *receiver[V, M]) Consume(ctx context.Context) (V, M, error) {
+	if err := r.injector.inject(ctx, "consume"); err != nil {
+		var (
+			value   V
+			message M
+		)
+		return value, message, err
+	}
+	return r.next.Consume(ctx)
+}
+
+func (r *receiver[V, M]) OnSuccess(ctx context.Context, givenMessage M) error {
+	if err := r.injector.inject(ctx, "on_success"); err != nil {
+		return err
+	}
+	return r.next.OnSuccess(ctx, givenMessage)
+}
+
+func (r *receiver[V, M]) OnFail(ctx context.Context, shouldRetry bool, givenMessage M) error {
+	if err := r.injector.inject(ctx, "on_fail"); err != nil {
+		return err
+	}
+	return r.next.OnFail(ctx, shouldRetry, givenMessage)
+}
+
+//endregion
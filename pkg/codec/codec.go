@@ -0,0 +1,63 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Codec converts values of type T to bytes and back, used by cache and message adapters
+type Codec[T any] interface {
+	// Encode serializes the value
+	Encode(value T) ([]byte, error)
+	// Decode deserializes the value
+	Decode(data []byte) (T, error)
+}
+
+// JSON is the default Codec, encoding/json
+type JSON[T any] struct{}
+
+// NewJSON creates a new JSON codec
+func NewJSON[T any]() JSON[T] {
+	return JSON[T]{}
+}
+
+// Encode - impl Codec.Encode
+func (JSON[T]) Encode(value T) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling json: %w", err)
+	}
+	return data, nil
+}
+
+// Decode - impl Codec.Decode
+func (JSON[T]) Decode(data []byte) (T, error) {
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return *new(T), fmt.Errorf("error unmarshalling json: %w", err)
+	}
+	return value, nil
+}
+
+// NewKeyCodec returns the codec of keys that are saved as strings, e.g. Redis keys:
+// string keys are saved as they are, other keys as JSON
+func NewKeyCodec[K comparable]() Codec[K] {
+	if reflect.TypeFor[K]() == reflect.TypeFor[string]() {
+		return stringKey[K]{}
+	}
+	return NewJSON[K]()
+}
+
+// stringKey is the codec of string keys, K is always string
+type stringKey[K comparable] struct{}
+
+// Encode - impl Codec.Encode
+func (stringKey[K]) Encode(key K) ([]byte, error) {
+	return []byte(any(key).(string)), nil
+}
+
+// Decode - impl Codec.Decode
+func (stringKey[K]) Decode(data []byte) (K, error) {
+	return any(string(data)).(K), nil
+}
@@ -0,0 +1,34 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// Gob is a Codec of encoding/gob, compact for Go-only consumers.
+// Every value carries its type description, so it's the biggest for small values
+type Gob[T any] struct{}
+
+// NewGob creates a new Gob codec
+func NewGob[T any]() Gob[T] {
+	return Gob[T]{}
+}
+
+// Encode - impl Codec.Encode
+func (Gob[T]) Encode(value T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, fmt.Errorf("error encoding gob: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode - impl Codec.Decode
+func (Gob[T]) Decode(data []byte) (T, error) {
+	var value T
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return *new(T), fmt.Errorf("error decoding gob: %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,174 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"sync"
+)
+
+// Tag identifies a codec in the header byte of Header, 0..15
+type Tag byte
+
+// Tags of the codecs of this package, any other tag up to maxTag may be used for custom codecs
+const (
+	TagJSON    Tag = 0
+	TagGob     Tag = 1
+	TagMsgPack Tag = 2
+	TagProto   Tag = 3
+
+	maxTag Tag = 15
+)
+
+// Compression of data encoded by Header
+type Compression byte
+
+const (
+	// CompressionNone - data isn't compressed
+	CompressionNone Compression = 0
+	// CompressionSnappy - fast with a fair ratio, good for hot caches
+	CompressionSnappy Compression = 1
+	// CompressionZstd - a better ratio for some CPU, good for big values and messages
+	CompressionZstd Compression = 2
+)
+
+const (
+	// headerBit is set in every header, so a header never looks like the first byte of JSON
+	headerBit = 0x80
+	// compressionMask is the Compression bits of a header
+	compressionMask = 0x07
+)
+
+// ErrUnknownHeader describes an error when the header byte names a codec or a compression Header doesn't know
+var ErrUnknownHeader = errors.New("unknown codec header")
+
+// TaggedCodec is a codec and its tag in headers
+type TaggedCodec[T any] struct {
+	Tag   Tag
+	Codec Codec[T]
+}
+
+// Header - implement Codec over other codecs, the first byte of data tells how it was encoded:
+// 0x80 | Tag << 3 | Compression.
+//
+// Data is encoded with one codec and compression, and decoded with the codec and the compression of its header.
+// So switching, e.g. from JSON to MsgPack with zstd, doesn't need a flush of data written earlier:
+// keep the old codec in previous codecs until that data expires.
+//
+// Data without a header (the first byte is below 0x80, e.g. JSON written before Header was used)
+// is decoded by the codec of TagJSON
+type Header[T any] struct {
+	header byte
+	codec  Codec[T]
+	codecs map[Tag]Codec[T]
+}
+
+// NewHeader creates a new Header that encodes with codec and compression,
+// previous codecs decode data written by them earlier
+//
+//	c, err := codec.NewHeader(codec.TagMsgPack, codec.NewMsgPack[models.Order](), codec.CompressionZstd,
+//	    codec.TaggedCodec[models.Order]{Tag: codec.TagJSON, Codec: codec.NewJSON[models.Order]()},
+//	)
+func NewHeader[T any](tag Tag, codec Codec[T], compression Compression, previous ...TaggedCodec[T]) (*Header[T], error) {
+	if tag > maxTag {
+		return nil, fmt.Errorf("%w: tag %d, max is %d", ErrUnknownHeader, tag, maxTag)
+	}
+	if compression > CompressionZstd {
+		return nil, fmt.Errorf("%w: compression %d", ErrUnknownHeader, compression)
+	}
+
+	codecs := make(map[Tag]Codec[T], len(previous)+1)
+	for _, c := range previous {
+		if c.Tag > maxTag {
+			return nil, fmt.Errorf("%w: tag %d, max is %d", ErrUnknownHeader, c.Tag, maxTag)
+		}
+		codecs[c.Tag] = c.Codec
+	}
+	codecs[tag] = codec
+
+	return &Header[T]{
+		header: headerBit | byte(tag)<<3 | byte(compression),
+		codec:  codec,
+		codecs: codecs,
+	}, nil
+}
+
+// Encode - impl Codec.Encode
+func (h *Header[T]) Encode(value T) ([]byte, error) {
+	data, err := h.codec.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []byte{h.header}
+	switch Compression(h.header & compressionMask) {
+	case CompressionSnappy:
+		return append(result, snappy.Encode(nil, data)...), nil
+	case CompressionZstd:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(data, result), nil
+	default:
+		return append(result, data...), nil
+	}
+}
+
+// Decode - impl Codec.Decode
+func (h *Header[T]) Decode(data []byte) (T, error) {
+	if len(data) == 0 || data[0]&headerBit == 0 {
+		c, ok := h.codecs[TagJSON]
+		if !ok {
+			return *new(T), fmt.Errorf("%w: data has no header and there's no codec of TagJSON", ErrUnknownHeader)
+		}
+		return c.Decode(data)
+	}
+
+	header := data[0]
+	tag := Tag((header &^ headerBit) >> 3)
+	c, ok := h.codecs[tag]
+	if !ok {
+		return *new(T), fmt.Errorf("%w: codec tag %d", ErrUnknownHeader, tag)
+	}
+
+	data = data[1:]
+	var err error
+	switch compression := Compression(header & compressionMask); compression {
+	case CompressionNone:
+	case CompressionSnappy:
+		if data, err = snappy.Decode(nil, data); err != nil {
+			return *new(T), fmt.Errorf("error decompressing snappy: %w", err)
+		}
+	case CompressionZstd:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return *new(T), err
+		}
+		if data, err = decoder.DecodeAll(data, nil); err != nil {
+			return *new(T), fmt.Errorf("error decompressing zstd: %w", err)
+		}
+	default:
+		return *new(T), fmt.Errorf("%w: compression %d", ErrUnknownHeader, compression)
+	}
+	return c.Decode(data)
+}
+
+// zstdEncoder and zstdDecoder are shared: EncodeAll and DecodeAll are safe for concurrent use
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating zstd encoder: %w", err)
+		}
+		return encoder, nil
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+		if err != nil {
+			return nil, fmt.Errorf("error creating zstd decoder: %w", err)
+		}
+		return decoder, nil
+	})
+)
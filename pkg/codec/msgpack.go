@@ -0,0 +1,38 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPack is a Codec of MessagePack, smaller and faster than JSON.
+// Struct fields are named by `json` tags, so values keep the names they have in JSON
+type MsgPack[T any] struct{}
+
+// NewMsgPack creates a new MsgPack codec
+func NewMsgPack[T any]() MsgPack[T] {
+	return MsgPack[T]{}
+}
+
+// Encode - impl Codec.Encode
+func (MsgPack[T]) Encode(value T) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(value); err != nil {
+		return nil, fmt.Errorf("error encoding msgpack: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode - impl Codec.Decode
+func (MsgPack[T]) Decode(data []byte) (T, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	var value T
+	if err := dec.Decode(&value); err != nil {
+		return *new(T), fmt.Errorf("error decoding msgpack: %w", err)
+	}
+	return value, nil
+}
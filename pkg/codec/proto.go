@@ -0,0 +1,33 @@
+package codec
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// Proto is a Codec of protobuf messages, T is a generated message pointer, e.g. *pb.Order
+type Proto[T proto.Message] struct{}
+
+// NewProto creates a new Proto codec
+func NewProto[T proto.Message]() Proto[T] {
+	return Proto[T]{}
+}
+
+// Encode - impl Codec.Encode
+func (Proto[T]) Encode(value T) ([]byte, error) {
+	data, err := proto.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling protobuf: %w", err)
+	}
+	return data, nil
+}
+
+// Decode - impl Codec.Decode
+func (Proto[T]) Decode(data []byte) (T, error) {
+	// generated messages describe their type even through a nil pointer
+	value := (*new(T)).ProtoReflect().Type().New().Interface().(T)
+	if err := proto.Unmarshal(data, value); err != nil {
+		return *new(T), fmt.Errorf("error unmarshalling protobuf: %w", err)
+	}
+	return value, nil
+}
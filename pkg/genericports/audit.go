@@ -0,0 +1,124 @@
+package genericports
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotSoftDeletable describes an error when restoring an object that can't be soft-deleted
+var ErrNotSoftDeletable = errors.New("object is not soft-deletable")
+
+// SoftDeletable describes an object that is marked as deleted instead of being removed.
+//
+// Storage adapters check if *T implements it:
+// DeleteObject sets deleted_at, reads exclude deleted objects unless WithDeleted is used
+type SoftDeletable interface {
+	// GetDeletedAt returns deletion time, nil if object isn't deleted
+	GetDeletedAt() *time.Time
+	// SetDeletedAt sets deletion time, nil restores object
+	SetDeletedAt(deletedAt *time.Time)
+}
+
+// Timestamped describes an object with creation and last update time.
+//
+// Storage adapters check if *T implements it and set both values from their Clock
+type Timestamped interface {
+	SetCreatedAt(createdAt time.Time)
+	SetUpdatedAt(updatedAt time.Time)
+}
+
+// RestorableStoragePort is a GenericStoragePort that can undo soft deletes
+type RestorableStoragePort[I comparable, T ObjectWithIdentifier[I]] interface {
+	GenericStoragePort[I, T]
+	// RestoreObject clears deleted_at of a soft-deleted object, returns restored object
+	//
+	// ErrNotFound if there's no deleted object with given ID, ErrNotSoftDeletable if *T isn't SoftDeletable
+	RestoreObject(ctx context.Context, id I) (*T, error)
+}
+
+// Clock is the source of time for audit timestamps, inject a fixed one in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc - adapter to use ordinary functions as Clock
+type ClockFunc func() time.Time
+
+// Now - impl Clock.Now
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the default Clock, returns time.Now in UTC
+var SystemClock Clock = ClockFunc(func() time.Time { return time.Now().UTC() })
+
+type key string
+
+const (
+	keyForWithDeleted    key = "with_deleted"
+	keyForAuditPreserved key = "audit_preserved"
+	keyForWithHardDelete key = "with_hard_delete"
+)
+
+// WithDeleted returns a context that makes storage reads include soft-deleted objects
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keyForWithDeleted, true)
+}
+
+// IsWithDeleted returns if soft-deleted objects must be included, see WithDeleted
+func IsWithDeleted(ctx context.Context) bool {
+	withDeleted, _ := ctx.Value(keyForWithDeleted).(bool)
+	return withDeleted
+}
+
+// WithAuditPreserved returns a context that makes CreateObject keep creation, update and deletion time
+// of the object as given instead of stamping them, e.g. to copy objects between storages
+func WithAuditPreserved(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keyForAuditPreserved, true)
+}
+
+// IsAuditPreserved returns if audit fields of created objects must be kept, see WithAuditPreserved
+func IsAuditPreserved(ctx context.Context) bool {
+	preserved, _ := ctx.Value(keyForAuditPreserved).(bool)
+	return preserved
+}
+
+// WithHardDelete returns a context that makes DeleteObject remove SoftDeletable objects,
+// soft-deleted ones included, e.g. to move objects between storages
+func WithHardDelete(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keyForWithHardDelete, true)
+}
+
+// IsHardDelete returns if objects must be removed instead of being soft-deleted, see WithHardDelete
+func IsHardDelete(ctx context.Context) bool {
+	hardDelete, _ := ctx.Value(keyForWithHardDelete).(bool)
+	return hardDelete
+}
+
+// IsSoftDeletable returns if *T implements SoftDeletable
+func IsSoftDeletable[T any]() bool {
+	_, ok := any(new(T)).(SoftDeletable)
+	return ok
+}
+
+// IsTimestamped returns if *T implements Timestamped
+func IsTimestamped[T any]() bool {
+	_, ok := any(new(T)).(Timestamped)
+	return ok
+}
+
+// StampCreated sets both created and updated time if object is Timestamped
+func StampCreated[T any](object *T, now time.Time) {
+	if timestamped, ok := any(object).(Timestamped); ok {
+		timestamped.SetCreatedAt(now)
+		timestamped.SetUpdatedAt(now)
+	}
+}
+
+// StampUpdated sets updated time if object is Timestamped
+func StampUpdated[T any](object *T, now time.Time) {
+	if timestamped, ok := any(object).(Timestamped); ok {
+		timestamped.SetUpdatedAt(now)
+	}
+}
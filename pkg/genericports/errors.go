@@ -0,0 +1,16 @@
+package genericports
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound describes an error when object with given ID doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// ErrConflict describes an error when object with given ID already exists
+var ErrConflict = errors.New("object already exists")
+
+// ErrCachedNotFound describes an error when a cache remembers that object with given ID doesn't exist.
+// It wraps ErrNotFound, so errors.Is(err, ErrNotFound) is true for it too
+var ErrCachedNotFound = fmt.Errorf("%w: cached", ErrNotFound)
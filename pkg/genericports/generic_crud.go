@@ -1,6 +1,9 @@
 package genericports
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // ObjectWithIdentifier describes an object that has an ID.
 //
@@ -37,6 +40,15 @@ type GenericStoragePort[I comparable, T ObjectWithIdentifier[I]] interface {
 	DeleteObject(ctx context.Context, id I) error
 }
 
+// PagedStoragePort is a GenericStoragePort that reads objects page by page in the order of their IDs,
+// so all objects can be read without holding them in memory at once
+type PagedStoragePort[I comparable, T ObjectWithIdentifier[I]] interface {
+	GenericStoragePort[I, T]
+	// GetObjectsAfter gets up to limit objects with IDs greater than afterID ordered by ID,
+	// from the first object if afterID is nil. A page shorter than limit is the last one
+	GetObjectsAfter(ctx context.Context, afterID *I, limit int) ([]*T, error)
+}
+
 // GenericCachePort describes a temporary KV storage for object objects
 //
 // Supposed to be working along with GenericStoragePort
@@ -52,3 +64,12 @@ type GenericCachePort[I comparable, T ObjectWithIdentifier[I]] interface {
 	// DeleteObject deletes a object by given ID, if exists, else err
 	DeleteObject(ctx context.Context, id I) error
 }
+
+// NotFoundCachePort is a GenericCachePort that also remembers IDs known not to exist
+//
+// GetObjectByID of such an ID returns ErrCachedNotFound until ttl passes or SaveObject saves the object
+type NotFoundCachePort[I comparable, T ObjectWithIdentifier[I]] interface {
+	GenericCachePort[I, T]
+	// SaveNotFound remembers that object with given ID doesn't exist for ttl
+	SaveNotFound(ctx context.Context, id I, ttl time.Duration) error
+}
@@ -0,0 +1,109 @@
+package genericportstest
+
+import (
+	"context"
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"reflect"
+	"testing"
+)
+
+// CachePortSuite describes how to test a genericports.GenericCachePort implementation
+type CachePortSuite[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	// NewCache returns an empty cache, called for every subtest
+	NewCache func(t *testing.T) genericports.GenericCachePort[I, T]
+	// NewObject returns a new object, different n must give different IDs
+	NewObject func(n int) *T
+	// Modify changes non-ID fields of given object copy and returns it
+	Modify func(object *T) *T
+	// Equal compares objects, reflect.DeepEqual if nil
+	Equal func(a, b *T) bool
+}
+
+// RunCachePortSuite checks the contracts of genericports.GenericCachePort
+//
+// A miss is either (nil, nil) or genericports.ErrNotFound, deleting a missing ID is either nil or genericports.ErrNotFound
+func RunCachePortSuite[I comparable, T genericports.ObjectWithIdentifier[I]](t *testing.T, suite CachePortSuite[I, T]) {
+	t.Helper()
+	if suite.Equal == nil {
+		suite.Equal = func(a, b *T) bool { return reflect.DeepEqual(a, b) }
+	}
+
+	t.Run("save then get", func(t *testing.T) {
+		ctx := context.Background()
+		cache := suite.NewCache(t)
+		object := suite.NewObject(1)
+
+		saved, err := cache.SaveObject(ctx, object)
+		if err != nil {
+			t.Fatalf("SaveObject failed: %v", err)
+		}
+
+		found, err := cache.GetObjectByID(ctx, (*object).GetUniqueIdentifier())
+		if err != nil {
+			t.Fatalf("GetObjectByID failed: %v", err)
+		}
+		if !suite.Equal(found, saved) {
+			t.Errorf("GetObjectByID returned %v, expected %v", found, saved)
+		}
+	})
+
+	t.Run("save replaces", func(t *testing.T) {
+		ctx := context.Background()
+		cache := suite.NewCache(t)
+
+		if _, err := cache.SaveObject(ctx, suite.NewObject(1)); err != nil {
+			t.Fatalf("SaveObject failed: %v", err)
+		}
+		modified := suite.Modify(suite.NewObject(1))
+		saved, err := cache.SaveObject(ctx, modified)
+		if err != nil {
+			t.Fatalf("second SaveObject failed: %v", err)
+		}
+
+		found, err := cache.GetObjectByID(ctx, (*modified).GetUniqueIdentifier())
+		if err != nil {
+			t.Fatalf("GetObjectByID failed: %v", err)
+		}
+		if !suite.Equal(found, saved) {
+			t.Errorf("GetObjectByID returned %v after second save, expected %v", found, saved)
+		}
+	})
+
+	t.Run("get missing", func(t *testing.T) {
+		cache := suite.NewCache(t)
+		found, err := cache.GetObjectByID(context.Background(), (*suite.NewObject(1)).GetUniqueIdentifier())
+		checkMiss(t, found, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		ctx := context.Background()
+		cache := suite.NewCache(t)
+		object := suite.NewObject(1)
+		id := (*object).GetUniqueIdentifier()
+
+		if _, err := cache.SaveObject(ctx, object); err != nil {
+			t.Fatalf("SaveObject failed: %v", err)
+		}
+		if err := cache.DeleteObject(ctx, id); err != nil {
+			t.Fatalf("DeleteObject failed: %v", err)
+		}
+
+		found, err := cache.GetObjectByID(ctx, id)
+		checkMiss(t, found, err)
+
+		if err = cache.DeleteObject(ctx, id); err != nil && !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected nil or ErrNotFound on second delete, got %v", err)
+		}
+	})
+}
+
+func checkMiss[T any](t *testing.T, found *T, err error) {
+	t.Helper()
+	if err != nil && !errors.Is(err, genericports.ErrNotFound) {
+		t.Errorf("Expected nil error or ErrNotFound on miss, got %v", err)
+	}
+	if found != nil {
+		t.Errorf("Expected nil object on miss, got %v", found)
+	}
+}
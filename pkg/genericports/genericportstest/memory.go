@@ -0,0 +1,232 @@
+package genericportstest
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"slices"
+	"sync"
+	"time"
+)
+
+// InMemoryStorage - implement genericports.GenericStoragePort, genericports.RestorableStoragePort
+// and genericports.PatchableStoragePort in a map
+//
+// A fake for tests of code that depends on storage ports, it isn't a production adapter.
+// Objects are copied on every call, GetObjects keeps insertion order.
+//
+// Honours genericports.SoftDeletable and genericports.Timestamped like the other storage adapters
+type InMemoryStorage[K comparable, V genericports.ObjectWithIdentifier[K]] struct {
+	mu    sync.RWMutex
+	data  map[K]V
+	keys  []K
+	clock genericports.Clock
+
+	// createdAt keeps creation time of genericports.Timestamped objects, the interface has no getter
+	createdAt map[K]time.Time
+}
+
+// createdAtGetter lets InMemoryStorage read creation time of objects created with genericports.WithAuditPreserved
+type createdAtGetter interface {
+	GetCreatedAt() time.Time
+}
+
+// NewInMemoryStorage creates a new empty instance of InMemoryStorage
+func NewInMemoryStorage[K comparable, V genericports.ObjectWithIdentifier[K]]() *InMemoryStorage[K, V] {
+	return &InMemoryStorage[K, V]{
+		data:      make(map[K]V),
+		keys:      make([]K, 0),
+		clock:     genericports.SystemClock,
+		createdAt: make(map[K]time.Time),
+	}
+}
+
+// SetClock replaces the clock used for audit timestamps, genericports.SystemClock by default
+func (s *InMemoryStorage[K, V]) SetClock(clock genericports.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clock
+}
+
+// GetObjects - impl genericports.GenericStoragePort.GetObjects
+//
+// Soft-deleted objects are skipped unless ctx is genericports.WithDeleted
+func (s *InMemoryStorage[K, V]) GetObjects(ctx context.Context) ([]*V, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*V, 0, len(s.keys))
+	for _, key := range s.keys {
+		object := s.data[key]
+		if isDeleted(&object) && !genericports.IsWithDeleted(ctx) {
+			continue
+		}
+		result = append(result, &object)
+	}
+	return result, nil
+}
+
+// GetObjectByID - impl genericports.GenericStoragePort.GetObjectByID
+//
+// Soft-deleted object is not found unless ctx is genericports.WithDeleted
+func (s *InMemoryStorage[K, V]) GetObjectByID(ctx context.Context, id K) (*V, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	object, ok := s.get(ctx, id)
+	if !ok {
+		return nil, fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+	return &object, nil
+}
+
+// CreateObject - impl genericports.GenericStoragePort.CreateObject
+//
+// Audit fields are kept as given if ctx is genericports.WithAuditPreserved,
+// later updates keep the given creation time only if *V has GetCreatedAt
+func (s *InMemoryStorage[K, V]) CreateObject(ctx context.Context, fullyReadyObject *V) (*V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object := *fullyReadyObject
+	id := object.GetUniqueIdentifier()
+	if _, exists := s.data[id]; exists {
+		return nil, fmt.Errorf("%w: id '%v'", genericports.ErrConflict, id)
+	}
+
+	if genericports.IsAuditPreserved(ctx) {
+		if getter, ok := any(&object).(createdAtGetter); ok {
+			s.createdAt[id] = getter.GetCreatedAt()
+		}
+	} else {
+		now := s.clock.Now()
+		genericports.StampCreated(&object, now)
+		s.createdAt[id] = now
+	}
+	s.data[id] = object
+	s.keys = append(s.keys, id)
+	return &object, nil
+}
+
+// UpdateObject - impl genericports.GenericStoragePort.UpdateObject
+//
+// Creation and deletion time are kept as stored
+func (s *InMemoryStorage[K, V]) UpdateObject(ctx context.Context, fullyReadyObject *V) (*V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object := *fullyReadyObject
+	id := object.GetUniqueIdentifier()
+
+	stored, ok := s.get(ctx, id)
+	if !ok {
+		return nil, fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+
+	s.keepAuditFields(id, &stored, &object)
+	genericports.StampUpdated(&object, s.clock.Now())
+	s.data[id] = object
+	return &object, nil
+}
+
+// DeleteObject - impl genericports.GenericStoragePort.DeleteObject
+//
+// Sets deleted_at instead of deleting if *V is genericports.SoftDeletable, unless ctx is genericports.WithHardDelete
+func (s *InMemoryStorage[K, V]) DeleteObject(ctx context.Context, id K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hardDelete := genericports.IsHardDelete(ctx)
+	if hardDelete {
+		ctx = genericports.WithDeleted(ctx)
+	}
+	object, ok := s.get(ctx, id)
+	if !ok || (isDeleted(&object) && !hardDelete) {
+		return fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+
+	if softDeletable, isSoft := any(&object).(genericports.SoftDeletable); isSoft && !hardDelete {
+		now := s.clock.Now()
+		softDeletable.SetDeletedAt(&now)
+		genericports.StampUpdated(&object, now)
+		s.data[id] = object
+		return nil
+	}
+
+	delete(s.data, id)
+	delete(s.createdAt, id)
+	s.keys = slices.DeleteFunc(s.keys, func(key K) bool { return key == id })
+	return nil
+}
+
+// RestoreObject - impl genericports.RestorableStoragePort.RestoreObject
+func (s *InMemoryStorage[K, V]) RestoreObject(_ context.Context, id K) (*V, error) {
+	if !genericports.IsSoftDeletable[V]() {
+		return nil, genericports.ErrNotSoftDeletable
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object, ok := s.data[id]
+	if !ok || !isDeleted(&object) {
+		return nil, fmt.Errorf("%w: no deleted object with id '%v'", genericports.ErrNotFound, id)
+	}
+
+	any(&object).(genericports.SoftDeletable).SetDeletedAt(nil)
+	genericports.StampUpdated(&object, s.clock.Now())
+	s.data[id] = object
+	return &object, nil
+}
+
+// PatchObject - impl genericports.PatchableStoragePort.PatchObject
+func (s *InMemoryStorage[K, V]) PatchObject(ctx context.Context, id K, patch genericports.Patch) (*V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.get(ctx, id)
+	if !ok {
+		return nil, fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+
+	patched, err := genericports.ApplyPatch(&stored, patch)
+	if err != nil {
+		return nil, err
+	}
+	if (*patched).GetUniqueIdentifier() != id {
+		return nil, fmt.Errorf("%w: id can't be patched", genericports.ErrInvalidPatch)
+	}
+
+	s.keepAuditFields(id, &stored, patched)
+	genericports.StampUpdated(patched, s.clock.Now())
+	s.data[id] = *patched
+
+	result := *patched
+	return &result, nil
+}
+
+// get returns a copy of the stored object, soft-deleted ones are found only with genericports.WithDeleted
+func (s *InMemoryStorage[K, V]) get(ctx context.Context, id K) (V, bool) {
+	object, ok := s.data[id]
+	if !ok || (isDeleted(&object) && !genericports.IsWithDeleted(ctx)) {
+		return *new(V), false
+	}
+	return object, true
+}
+
+func isDeleted[V any](object *V) bool {
+	softDeletable, ok := any(object).(genericports.SoftDeletable)
+	return ok && softDeletable.GetDeletedAt() != nil
+}
+
+// keepAuditFields copies creation and deletion time of the stored object into object
+func (s *InMemoryStorage[K, V]) keepAuditFields(id K, stored *V, object *V) {
+	if softDeletable, ok := any(object).(genericports.SoftDeletable); ok {
+		softDeletable.SetDeletedAt(any(stored).(genericports.SoftDeletable).GetDeletedAt())
+	}
+	if timestamped, ok := any(object).(genericports.Timestamped); ok {
+		if createdAt, known := s.createdAt[id]; known {
+			timestamped.SetCreatedAt(createdAt)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package genericportstest is the conformance test kit for genericports implementations
+//
+//	func TestOrdersPostgres(t *testing.T) {
+//	    genericportstest.RunStorageSuite(t, genericportstest.StorageSuite[string, models.Order]{
+//	        NewStorage: func(t *testing.T) genericports.GenericStoragePort[string, models.Order] {
+//	            truncateOrders(t)
+//	            return ordersStorage
+//	        },
+//	        NewObject: func(n int) *models.Order { return &models.Order{OrderUID: fmt.Sprint(n)} },
+//	        Modify: func(o *models.Order) *models.Order { o.TrackNumber += "-updated"; return o },
+//	    })
+//	}
+package genericportstest
+
+import (
+	"context"
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"reflect"
+	"testing"
+)
+
+// StorageSuite describes how to test a genericports.GenericStoragePort implementation
+type StorageSuite[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	// NewStorage returns an empty storage, called for every subtest
+	NewStorage func(t *testing.T) genericports.GenericStoragePort[I, T]
+	// NewObject returns a new object, different n must give different IDs
+	NewObject func(n int) *T
+	// Modify changes non-ID fields of given object copy and returns it
+	Modify func(object *T) *T
+	// Equal compares objects, reflect.DeepEqual if nil.
+	// Set it if storage changes objects (e.g. timestamps, time precision)
+	Equal func(a, b *T) bool
+}
+
+// RunStorageSuite checks the documented contracts of genericports.GenericStoragePort:
+//
+// created objects are readable, duplicate create is genericports.ErrConflict,
+// reading, updating and deleting missing IDs is genericports.ErrNotFound.
+// Pages of genericports.PagedStoragePort are checked too if the storage implements it
+func RunStorageSuite[I comparable, T genericports.ObjectWithIdentifier[I]](t *testing.T, suite StorageSuite[I, T]) {
+	t.Helper()
+	if suite.Equal == nil {
+		suite.Equal = func(a, b *T) bool { return reflect.DeepEqual(a, b) }
+	}
+
+	t.Run("create then get", func(t *testing.T) {
+		ctx := context.Background()
+		storage := suite.NewStorage(t)
+		object := suite.NewObject(1)
+
+		created, err := storage.CreateObject(ctx, object)
+		if err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+		if created == nil || (*created).GetUniqueIdentifier() != (*object).GetUniqueIdentifier() {
+			t.Fatalf("CreateObject must return created object with given ID, got %v", created)
+		}
+
+		found, err := storage.GetObjectByID(ctx, (*object).GetUniqueIdentifier())
+		if err != nil {
+			t.Fatalf("GetObjectByID failed: %v", err)
+		}
+		if !suite.Equal(found, created) {
+			t.Errorf("GetObjectByID returned %v, expected %v", found, created)
+		}
+	})
+
+	t.Run("duplicate create is conflict", func(t *testing.T) {
+		ctx := context.Background()
+		storage := suite.NewStorage(t)
+		object := suite.NewObject(1)
+
+		if _, err := storage.CreateObject(ctx, object); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+		_, err := storage.CreateObject(ctx, suite.NewObject(1))
+		if !errors.Is(err, genericports.ErrConflict) {
+			t.Errorf("Expected ErrConflict on duplicate create, got %v", err)
+		}
+	})
+
+	t.Run("get missing is not found", func(t *testing.T) {
+		storage := suite.NewStorage(t)
+		found, err := storage.GetObjectByID(context.Background(), (*suite.NewObject(1)).GetUniqueIdentifier())
+		if !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if found != nil {
+			t.Errorf("Expected nil object on not found, got %v", found)
+		}
+	})
+
+	t.Run("get objects", func(t *testing.T) {
+		ctx := context.Background()
+		storage := suite.NewStorage(t)
+
+		objects, err := storage.GetObjects(ctx)
+		if err != nil {
+			t.Fatalf("GetObjects failed: %v", err)
+		}
+		if len(objects) != 0 {
+			t.Fatalf("Expected empty storage, got %d objects", len(objects))
+		}
+
+		ids := make(map[I]bool)
+		for n := 1; n <= 3; n++ {
+			object := suite.NewObject(n)
+			if _, err = storage.CreateObject(ctx, object); err != nil {
+				t.Fatalf("CreateObject failed: %v", err)
+			}
+			ids[(*object).GetUniqueIdentifier()] = true
+		}
+
+		objects, err = storage.GetObjects(ctx)
+		if err != nil {
+			t.Fatalf("GetObjects failed: %v", err)
+		}
+		if len(objects) != len(ids) {
+			t.Fatalf("Expected %d objects, got %d", len(ids), len(objects))
+		}
+		for _, object := range objects {
+			if !ids[(*object).GetUniqueIdentifier()] {
+				t.Errorf("Unexpected object %v", object)
+			}
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		ctx := context.Background()
+		storage := suite.NewStorage(t)
+		object := suite.NewObject(1)
+
+		if _, err := storage.CreateObject(ctx, object); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+
+		updated, err := storage.UpdateObject(ctx, suite.Modify(suite.NewObject(1)))
+		if err != nil {
+			t.Fatalf("UpdateObject failed: %v", err)
+		}
+
+		found, err := storage.GetObjectByID(ctx, (*object).GetUniqueIdentifier())
+		if err != nil {
+			t.Fatalf("GetObjectByID failed: %v", err)
+		}
+		if !suite.Equal(found, updated) {
+			t.Errorf("GetObjectByID returned %v after update, expected %v", found, updated)
+		}
+	})
+
+	t.Run("update missing is not found", func(t *testing.T) {
+		storage := suite.NewStorage(t)
+		_, err := storage.UpdateObject(context.Background(), suite.Modify(suite.NewObject(1)))
+		if !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound on update of missing ID, got %v", err)
+		}
+	})
+
+	t.Run("get objects after", func(t *testing.T) {
+		ctx := context.Background()
+		storage, ok := suite.NewStorage(t).(genericports.PagedStoragePort[I, T])
+		if !ok {
+			t.Skip("storage is not a genericports.PagedStoragePort")
+		}
+
+		ids := make(map[I]bool)
+		for n := 1; n <= 5; n++ {
+			object := suite.NewObject(n)
+			if _, err := storage.CreateObject(ctx, object); err != nil {
+				t.Fatalf("CreateObject failed: %v", err)
+			}
+			ids[(*object).GetUniqueIdentifier()] = true
+		}
+
+		// 5 objects by 2 are pages of 2, 2 and 1, each one starts after the last ID of the previous one
+		var afterID *I
+		for _, size := range []int{2, 2, 1} {
+			page, err := storage.GetObjectsAfter(ctx, afterID, 2)
+			if err != nil {
+				t.Fatalf("GetObjectsAfter failed: %v", err)
+			}
+			if len(page) != size {
+				t.Fatalf("Expected a page of %d objects, got %d", size, len(page))
+			}
+			for _, object := range page {
+				id := (*object).GetUniqueIdentifier()
+				if !ids[id] {
+					t.Errorf("Unexpected or repeated object %v", object)
+				}
+				delete(ids, id)
+				afterID = &id
+			}
+		}
+		if len(ids) != 0 {
+			t.Errorf("Expected all objects to be paged, missing %v", ids)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		ctx := context.Background()
+		storage := suite.NewStorage(t)
+		object := suite.NewObject(1)
+		id := (*object).GetUniqueIdentifier()
+
+		if _, err := storage.CreateObject(ctx, object); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+		if err := storage.DeleteObject(ctx, id); err != nil {
+			t.Fatalf("DeleteObject failed: %v", err)
+		}
+		if _, err := storage.GetObjectByID(ctx, id); !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound after delete, got %v", err)
+		}
+		if err := storage.DeleteObject(ctx, id); !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound on second delete, got %v", err)
+		}
+	})
+}
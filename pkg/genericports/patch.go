@@ -0,0 +1,194 @@
+package genericports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidPatch describes an error when patch is malformed or the patched object is invalid
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// Patch is a partial update of an object in the form of RFC 7386 JSON Merge Patch
+//
+// Members are JSON field names of the object, null removes (zeroes) a field, nested objects are merged
+type Patch struct {
+	doc map[string]any
+}
+
+// PatchableStoragePort is a GenericStoragePort that can update only given fields
+type PatchableStoragePort[I comparable, T ObjectWithIdentifier[I]] interface {
+	GenericStoragePort[I, T]
+	// PatchObject applies patch to the object by given ID atomically, returns patched object
+	//
+	// ErrNotFound if object doesn't exist, ErrInvalidPatch if patch or the result is invalid
+	PatchObject(ctx context.Context, id I, patch Patch) (*T, error)
+}
+
+// NewMergePatch creates a Patch from RFC 7386 JSON Merge Patch document, it must be a JSON object
+//
+//	patch, err := genericports.NewMergePatch([]byte(`{"track_number": "WB123", "comment": null}`))
+func NewMergePatch(data []byte) (Patch, error) {
+	var doc map[string]any
+	if err := decodeJSON(data, &doc, false); err != nil {
+		return Patch{}, fmt.Errorf("%w: merge patch must be a json object: %w", ErrInvalidPatch, err)
+	}
+	if doc == nil {
+		return Patch{}, fmt.Errorf("%w: merge patch must be a json object", ErrInvalidPatch)
+	}
+	return Patch{doc: doc}, nil
+}
+
+// NewFieldMaskPatch creates a Patch that copies masked fields from values, nested fields are separated by dots
+//
+//	patch, err := genericports.NewFieldMaskPatch([]string{"track_number", "delivery.city"}, &models.Order{...})
+func NewFieldMaskPatch[T any](mask []string, values *T) (Patch, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return Patch{}, fmt.Errorf("%w: error marshalling values: %w", ErrInvalidPatch, err)
+	}
+	var source map[string]any
+	if err = decodeJSON(data, &source, false); err != nil {
+		return Patch{}, fmt.Errorf("%w: values must be a json object: %w", ErrInvalidPatch, err)
+	}
+
+	doc := make(map[string]any)
+	for _, path := range mask {
+		parts := strings.Split(path, ".")
+
+		// find the value, missing one means null (remove)
+		var value any = source
+		for _, part := range parts {
+			object, ok := value.(map[string]any)
+			if !ok {
+				value = nil
+				break
+			}
+			value = object[part]
+		}
+
+		// build nested patch objects down to the masked field
+		target := doc
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := target[part].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = value
+	}
+	return Patch{doc: doc}, nil
+}
+
+// Fields returns top-level members of the patch
+func (p Patch) Fields() []string {
+	fields := make([]string, 0, len(p.doc))
+	for field := range p.doc {
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// Document returns the merge patch document, don't modify it
+func (p Patch) Document() map[string]any {
+	return p.doc
+}
+
+// ApplyPatch returns a patched copy of object
+//
+// Result is decoded from JSON with unknown fields disallowed, so fields of pkg/types are validated
+// by their constructors. Top-level nulls are passed to decoding as is: required value types reject them.
+//
+// Fields that JSON doesn't carry (`json:"-"` and unexported ones) are copied from object as is,
+// object itself isn't changed
+func ApplyPatch[T any](object *T, patch Patch) (*T, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling object: %w", err)
+	}
+	var doc map[string]any
+	if err = decodeJSON(data, &doc, false); err != nil {
+		return nil, fmt.Errorf("error unmarshalling object: %w", err)
+	}
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+
+	for field, value := range patch.doc {
+		if value == nil {
+			doc[field] = nil
+			continue
+		}
+		doc[field] = mergePatch(doc[field], value)
+	}
+
+	merged, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling patched object: %w", err)
+	}
+
+	result := *object
+	resetJSONFields(reflect.ValueOf(&result).Elem())
+	if err = decodeJSON(merged, &result, true); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	return &result, nil
+}
+
+// mergePatch is MergePatch(Target, Patch) from RFC 7386
+func mergePatch(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// resetJSONFields zeroes the fields of struct v that JSON carries, the other ones are kept.
+// Decoding then doesn't write into maps, slices and pointers shared with the original object
+func resetJSONFields(v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		v.SetZero()
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			// fields of embedded structs are promoted to the JSON object
+			resetJSONFields(value)
+			continue
+		}
+		if !field.IsExported() || field.Tag.Get("json") == "-" || !value.CanSet() {
+			continue
+		}
+		value.SetZero()
+	}
+}
+
+func decodeJSON(data []byte, target any, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(target)
+}
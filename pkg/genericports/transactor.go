@@ -0,0 +1,24 @@
+package genericports
+
+import "context"
+
+// Transactor describes a unit of work that spans several calls of storage ports
+//
+// The active transaction is put in the context given to fn, storage adapters pick it up from there,
+// so ports don't need any extra parameters:
+//
+//	err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+//	    if _, err := orders.CreateObject(ctx, order); err != nil {
+//	        return err
+//	    }
+//	    _, err := items.CreateObject(ctx, item)
+//	    return err
+//	})
+type Transactor interface {
+	// WithinTransaction runs fn in a transaction: commits if fn returns nil, else rolls back
+	//
+	// Nested calls create a nested unit of work (e.g. savepoint) if the implementation supports it
+	//
+	// Panic in fn rolls the transaction back and is re-raised
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
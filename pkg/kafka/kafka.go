@@ -23,6 +23,24 @@ type Config struct {
 
 	NumPartitions     int `yaml:"num_partitions" env:"NUM_PARTITIONS" env-default:"1"`
 	ReplicationFactor int `yaml:"replication_factor" env:"REPLICATION_FACTOR" env-default:"1"`
+
+	Writer WriterConfig `yaml:"writer" env-prefix:"WRITER_"`
+}
+
+// WriterConfig - producer settings for kafka.Config
+type WriterConfig struct {
+	// RequiredAcks - "all", "one" or "none"
+	RequiredAcks string `yaml:"required_acks" env:"REQUIRED_ACKS" env-default:"all"`
+	// Compression - "none", "gzip", "snappy", "lz4" or "zstd"
+	Compression string `yaml:"compression" env:"COMPRESSION" env-default:"none"`
+	// Balancer - "hash", "round_robin", "least_bytes", "crc32" or "murmur2".
+	// Keyed balancers ("hash", "crc32", "murmur2") keep the order of messages with same key
+	Balancer string `yaml:"balancer" env:"BALANCER" env-default:"hash"`
+
+	BatchSize      int   `yaml:"batch_size" env:"BATCH_SIZE" env-default:"100"`
+	BatchBytes     int64 `yaml:"batch_bytes" env:"BATCH_BYTES" env-default:"1048576"` // 1MB
+	BatchTimeoutMs int   `yaml:"batch_timeout_ms" env:"BATCH_TIMEOUT_MS" env-default:"10"`
+	MaxAttempts    int   `yaml:"max_attempts" env:"MAX_ATTEMPTS" env-default:"10"`
 }
 
 // NewReader creates a new kafka.Reader with given settings
@@ -45,6 +63,85 @@ func NewReader(ctx context.Context, cfg Config, topic, groupID string) *kafka.Re
 	return r
 }
 
+// MessageWriter is the part of *kafka.Writer that publishers need, a fake one can be used in tests
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// NewWriter creates a new kafka.Writer with given settings (cfg.Writer)
+//
+// Topic isn't fixed, set it in every kafka.Message.
+//
+// Unknown acks, compression or balancer names are logged and replaced with defaults ("all", "none", "hash")
+func NewWriter(ctx context.Context, cfg Config) *kafka.Writer {
+	l := logger.GetOrCreateLoggerFromCtx(ctx)
+
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		RequiredAcks: kafka.RequireAll,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    cfg.Writer.BatchSize,
+		BatchBytes:   cfg.Writer.BatchBytes,
+		BatchTimeout: time.Duration(cfg.Writer.BatchTimeoutMs) * time.Millisecond,
+		MaxAttempts:  cfg.Writer.MaxAttempts,
+	}
+
+	switch cfg.Writer.RequiredAcks {
+	case "all", "":
+	case "one":
+		w.RequiredAcks = kafka.RequireOne
+	case "none":
+		w.RequiredAcks = kafka.RequireNone
+	default:
+		l.Warn(ctx, "unknown kafka required acks, using 'all'", zap.String("required_acks", cfg.Writer.RequiredAcks))
+	}
+
+	switch cfg.Writer.Compression {
+	case "none", "":
+	case "gzip":
+		w.Compression = kafka.Gzip
+	case "snappy":
+		w.Compression = kafka.Snappy
+	case "lz4":
+		w.Compression = kafka.Lz4
+	case "zstd":
+		w.Compression = kafka.Zstd
+	default:
+		l.Warn(ctx, "unknown kafka compression, using 'none'", zap.String("compression", cfg.Writer.Compression))
+	}
+
+	switch cfg.Writer.Balancer {
+	case "hash", "":
+	case "round_robin":
+		w.Balancer = &kafka.RoundRobin{}
+	case "least_bytes":
+		w.Balancer = &kafka.LeastBytes{}
+	case "crc32":
+		w.Balancer = kafka.CRC32Balancer{}
+	case "murmur2":
+		w.Balancer = kafka.Murmur2Balancer{}
+	default:
+		l.Warn(ctx, "unknown kafka balancer, using 'hash'", zap.String("balancer", cfg.Writer.Balancer))
+	}
+
+	l.Info(ctx, "created Kafka writer",
+		zap.Strings("brokers", cfg.Brokers),
+		zap.String("required_acks", cfg.Writer.RequiredAcks),
+		zap.String("compression", cfg.Writer.Compression),
+		zap.String("balancer", cfg.Writer.Balancer),
+	)
+	return w
+}
+
+// HeadersFromMap converts a map into kafka.Message headers
+func HeadersFromMap(headers map[string]string) []kafka.Header {
+	result := make([]kafka.Header, 0, len(headers))
+	for k, v := range headers {
+		result = append(result, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return result
+}
+
 // CreateTopicIfNotExists safely creates a topic. Supposed to be called on startup to ensure that topic exists
 func CreateTopicIfNotExists(cfg Config, topic string, numPartitions, replicationFactor int) error {
 	if topic == "" {
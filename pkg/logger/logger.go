@@ -49,13 +49,21 @@ func TryAppendRequestIDFromContext(ctx context.Context, fields []zap.Field) []za
 }
 
 func GetOrCreateLoggerFromCtx(ctx context.Context) *Logger {
-	logger := GetLoggerFromCtx(ctx)
+	logger, _ := ctx.Value(KeyForLogger).(*Logger)
 	if logger == nil {
 		logger, _ = NewLogger()
 	}
 	return logger
 }
 
+// GetLoggerFromCtxOr returns the logger from ctx, or fallback if ctx has none
+func GetLoggerFromCtxOr(ctx context.Context, fallback *Logger) *Logger {
+	if logger, _ := ctx.Value(KeyForLogger).(*Logger); logger != nil {
+		return logger
+	}
+	return fallback
+}
+
 func (l *Logger) Debug(ctx context.Context, msg string, fields ...zap.Field) {
 	fields = TryAppendRequestIDFromContext(ctx, fields)
 	l.l.Debug(msg, fields...)
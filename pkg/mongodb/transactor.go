@@ -0,0 +1,80 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type key string
+
+const keyForTransaction key = "mongodb_transaction"
+
+// Transactor implements genericports.Transactor with MongoDB sessions
+//
+// The session is put in the context with mongo.NewSessionContext,
+// so every mongo.Collection call made with that context joins the transaction.
+//
+// MongoDB has no savepoints: nested WithinTransaction calls join the outer transaction,
+// an error from the nested fn aborts the whole transaction once it's returned to the outer one
+type Transactor struct {
+	client  *mongo.Client
+	options *options.TransactionOptionsBuilder
+}
+
+// NewTransactor creates a new Transactor, options may be nil
+func NewTransactor(client *mongo.Client, options *options.TransactionOptionsBuilder) *Transactor {
+	return &Transactor{client: client, options: options}
+}
+
+// InTransaction returns if ctx carries a transaction started by Transactor
+func InTransaction(ctx context.Context) bool {
+	active, _ := ctx.Value(keyForTransaction).(bool)
+	return active && mongo.SessionFromContext(ctx) != nil
+}
+
+// WithinTransaction - impl genericports.Transactor.WithinTransaction
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("error starting mongodb session: %w", err)
+	}
+
+	// abort and end must happen even if ctx is already cancelled
+	cleanupCtx := context.WithoutCancel(ctx)
+	defer session.EndSession(cleanupCtx)
+
+	if t.options != nil {
+		err = session.StartTransaction(t.options)
+	} else {
+		err = session.StartTransaction()
+	}
+	if err != nil {
+		return fmt.Errorf("error starting mongodb transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = session.AbortTransaction(cleanupCtx)
+			panic(p)
+		}
+	}()
+
+	txCtx := context.WithValue(mongo.NewSessionContext(ctx, session), keyForTransaction, true)
+	if err = fn(txCtx); err != nil {
+		if abortErr := session.AbortTransaction(cleanupCtx); abortErr != nil {
+			return fmt.Errorf("error aborting mongodb transaction: %w (cause: %w)", abortErr, err)
+		}
+		return err
+	}
+
+	if err = session.CommitTransaction(ctx); err != nil {
+		return fmt.Errorf("error committing mongodb transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package negative is a genericports.GenericStoragePort decorator that caches not-found results,
+// so lookups of deleted or never created objects don't reach the storage every time
+//
+//	cache := cachegenericport.NewRedisGenericCache[string, models.Order](addr, password, db, ttlMs)
+//	orders := negative.NewStorage[string, models.Order](ordersPostgres, cache, negative.Options{TTL: 30 * time.Second})
+//
+//	order, err := orders.GetObjectByID(ctx, orderUID)
+//	if errors.Is(err, genericports.ErrCachedNotFound) { ... } // answered by the cache
+//	if errors.Is(err, genericports.ErrNotFound) { ... }       // both cached and fresh not-found results
+package negative
+
+import (
+	"context"
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+// DefaultTTL is how long a not-found result is remembered if Options.TTL isn't set
+const DefaultTTL = 30 * time.Second
+
+// Options configure Storage
+type Options struct {
+	// TTL is how long a not-found result is remembered, DefaultTTL by default.
+	// Keep it shorter than the TTL of cached objects: it bounds how long a concurrently created object stays hidden
+	TTL time.Duration
+}
+
+// Storage - implement genericports.GenericStoragePort in front of another one
+//
+// GetObjectByID remembers genericports.ErrNotFound of the storage in cache and returns
+// genericports.ErrCachedNotFound for that ID until Options.TTL passes.
+// CreateObject and UpdateObject forget the record, DeleteObject saves it.
+// Reads with genericports.WithDeleted bypass the records, soft-deleted objects are found then.
+//
+// Objects found in cache are ignored, the storage stays the source of truth. If the cache fails, calls go to the storage
+type Storage[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	next  genericports.GenericStoragePort[I, T]
+	cache genericports.NotFoundCachePort[I, T]
+	ttl   time.Duration
+}
+
+// NewStorage creates a new instance of Storage
+func NewStorage[I comparable, T genericports.ObjectWithIdentifier[I]](next genericports.GenericStoragePort[I, T], cache genericports.NotFoundCachePort[I, T], opts Options) *Storage[I, T] {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	return &Storage[I, T]{next: next, cache: cache, ttl: opts.TTL}
+}
+
+// cachedNotFound returns genericports.ErrCachedNotFound if id is remembered as missing, cache errors are logged
+func (s *Storage[I, T]) cachedNotFound(ctx context.Context, id I) error {
+	if genericports.IsWithDeleted(ctx) {
+		return nil
+	}
+	_, err := s.cache.GetObjectByID(ctx, id)
+	switch {
+	case errors.Is(err, genericports.ErrCachedNotFound):
+		return err
+	case err != nil:
+		logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "not-found cache read failed, asking storage",
+			zap.Any("id", id), zap.Error(err))
+	}
+	return nil
+}
+
+// saveNotFound remembers that id is missing, cache errors are logged
+func (s *Storage[I, T]) saveNotFound(ctx context.Context, id I) {
+	if err := s.cache.SaveNotFound(ctx, id, s.ttl); err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "error caching not-found result",
+			zap.Any("id", id), zap.Error(err))
+	}
+}
+
+// forget drops the record of id, cache errors are logged: the record stays until Options.TTL passes
+func (s *Storage[I, T]) forget(ctx context.Context, id I) {
+	if err := s.cache.DeleteObject(ctx, id); err != nil && !errors.Is(err, genericports.ErrNotFound) {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error clearing not-found result",
+			zap.Any("id", id), zap.Error(err))
+	}
+}
+
+// GetObjects - impl genericports.GenericStoragePort.GetObjects
+func (s *Storage[I, T]) GetObjects(ctx context.Context) ([]*T, error) {
+	return s.next.GetObjects(ctx)
+}
+
+// GetObjectByID - impl genericports.GenericStoragePort.GetObjectByID
+func (s *Storage[I, T]) GetObjectByID(ctx context.Context, id I) (*T, error) {
+	if err := s.cachedNotFound(ctx, id); err != nil {
+		return nil, err
+	}
+
+	object, err := s.next.GetObjectByID(ctx, id)
+	if errors.Is(err, genericports.ErrNotFound) && !genericports.IsWithDeleted(ctx) {
+		s.saveNotFound(ctx, id)
+	}
+	return object, err
+}
+
+// CreateObject - impl genericports.GenericStoragePort.CreateObject
+func (s *Storage[I, T]) CreateObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	created, err := s.next.CreateObject(ctx, fullyReadyObject)
+	if err != nil {
+		return nil, err
+	}
+	s.forget(ctx, (*fullyReadyObject).GetUniqueIdentifier())
+	return created, nil
+}
+
+// UpdateObject - impl genericports.GenericStoragePort.UpdateObject
+func (s *Storage[I, T]) UpdateObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	updated, err := s.next.UpdateObject(ctx, fullyReadyObject)
+	if err != nil {
+		return nil, err
+	}
+	s.forget(ctx, (*fullyReadyObject).GetUniqueIdentifier())
+	return updated, nil
+}
+
+// DeleteObject - impl genericports.GenericStoragePort.DeleteObject
+func (s *Storage[I, T]) DeleteObject(ctx context.Context, id I) error {
+	if err := s.next.DeleteObject(ctx, id); err != nil {
+		return err
+	}
+	s.saveNotFound(ctx, id)
+	return nil
+}
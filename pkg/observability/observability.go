@@ -0,0 +1,193 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Error kinds returned by DefaultClassifier
+const (
+	KindNotFound = "not_found"
+	KindConflict = "conflict"
+	KindCanceled = "canceled"
+	KindTimeout  = "timeout"
+	KindOther    = "other"
+)
+
+// Classifier maps an error to its kind for metrics, e.g. sentinel error -> "not_found"
+type Classifier func(err error) string
+
+// DefaultClassifier knows genericports and context sentinel errors
+func DefaultClassifier(err error) string {
+	switch {
+	case errors.Is(err, genericports.ErrNotFound):
+		return KindNotFound
+	case errors.Is(err, genericports.ErrConflict):
+		return KindConflict
+	case errors.Is(err, context.Canceled):
+		return KindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindTimeout
+	default:
+		return KindOther
+	}
+}
+
+// Recorder receives every observed call, implement it to export metrics (e.g. prometheus)
+//
+// errKind is empty on success
+type Recorder interface {
+	Record(port string, operation string, latency time.Duration, errKind string, slow bool)
+}
+
+// Options configure decorators of this package
+type Options struct {
+	// Name of the wrapped port in logs and metrics, e.g. "orders_storage"
+	Name string
+	// SlowThreshold - calls that take longer are logged as warnings, 0 disables
+	SlowThreshold time.Duration
+	// Logger is used if set, else logger from ctx
+	Logger *logger.Logger
+	// Recorder receives metrics, may be nil
+	Recorder Recorder
+	// Classify maps errors to kinds, DefaultClassifier if nil
+	Classify Classifier
+	// QuietKinds are error kinds that are logged with debug level instead of error, e.g. KindNotFound
+	QuietKinds []string
+}
+
+// observer is the shared part of all decorators
+type observer struct {
+	opts Options
+	// fallback logs calls whose ctx has no logger, it's created once instead of on every call
+	fallback *logger.Logger
+}
+
+func newObserver(opts Options) observer {
+	if opts.Classify == nil {
+		opts.Classify = DefaultClassifier
+	}
+	o := observer{opts: opts}
+	if opts.Logger == nil {
+		o.fallback = logger.GetOrCreateLoggerFromCtx(context.Background())
+	}
+	return o
+}
+
+// observe logs and records a finished call, checkSlow is false for blocking calls (e.g. Receiver.Consume)
+func (o observer) observe(ctx context.Context, operation string, start time.Time, err error, checkSlow bool) {
+	latency := time.Since(start)
+	slow := checkSlow && o.opts.SlowThreshold > 0 && latency > o.opts.SlowThreshold
+
+	errKind := ""
+	if err != nil {
+		errKind = o.opts.Classify(err)
+	}
+
+	if o.opts.Recorder != nil {
+		o.opts.Recorder.Record(o.opts.Name, operation, latency, errKind, slow)
+	}
+
+	l := o.opts.Logger
+	if l == nil {
+		l = logger.GetLoggerFromCtxOr(ctx, o.fallback)
+	}
+
+	fields := []zap.Field{
+		zap.String("port", o.opts.Name),
+		zap.String("operation", operation),
+		zap.Duration("latency", latency),
+	}
+
+	switch {
+	case err != nil && !o.isQuiet(errKind):
+		l.Error(ctx, "port call failed", append(fields, zap.String("error_kind", errKind), zap.Error(err))...)
+	case slow:
+		l.Warn(ctx, "slow port call", append(fields, zap.Duration("threshold", o.opts.SlowThreshold))...)
+	case err != nil:
+		l.Debug(ctx, "port call failed", append(fields, zap.String("error_kind", errKind), zap.Error(err))...)
+	default:
+		l.Debug(ctx, "port call", fields...)
+	}
+}
+
+func (o observer) isQuiet(errKind string) bool {
+	for _, kind := range o.opts.QuietKinds {
+		if kind == errKind {
+			return true
+		}
+	}
+	return false
+}
+
+// OperationStats are counters of one operation of one port
+type OperationStats struct {
+	Calls        int64
+	Slow         int64
+	Errors       map[string]int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// Counters is the in-memory Recorder, useful for tests and debug endpoints
+type Counters struct {
+	mu    sync.Mutex
+	stats map[string]*OperationStats
+}
+
+// NewCounters creates new empty Counters
+func NewCounters() *Counters {
+	return &Counters{stats: make(map[string]*OperationStats)}
+}
+
+// Record - impl Recorder.Record
+func (c *Counters) Record(port string, operation string, latency time.Duration, errKind string, slow bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := port + "." + operation
+	stats, ok := c.stats[key]
+	if !ok {
+		stats = &OperationStats{Errors: make(map[string]int64)}
+		c.stats[key] = stats
+	}
+
+	stats.Calls++
+	stats.TotalLatency += latency
+	stats.MaxLatency = max(stats.MaxLatency, latency)
+	if slow {
+		stats.Slow++
+	}
+	if errKind != "" {
+		stats.Errors[errKind]++
+	}
+}
+
+// Snapshot returns a copy of counters, keys are "port.operation"
+func (c *Counters) Snapshot() map[string]OperationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make(map[string]OperationStats, len(c.stats))
+	for key, stats := range c.stats {
+		statsCopy := *stats
+		statsCopy.Errors = make(map[string]int64, len(stats.Errors))
+		for kind, count := range stats.Errors {
+			statsCopy.Errors[kind] = count
+		}
+		result[key] = statsCopy
+	}
+	return result
+}
+
+// Reset clears all counters
+func (c *Counters) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats = make(map[string]*OperationStats)
+}
@@ -0,0 +1,170 @@
+package observability
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"time"
+)
+
+//region genericports.GenericStoragePort
+
+type storage[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	observer
+	next genericports.GenericStoragePort[I, T]
+}
+
+// NewStorage wraps genericports.GenericStoragePort with logging, timing and error classification
+//
+//	var orders genericports.GenericStoragePort[string, models.Order] = observability.NewStorage(
+//	    ordersPostgres, observability.Options{Name: "orders_storage", SlowThreshold: 100 * time.Millisecond},
+//	)
+func NewStorage[I comparable, T genericports.ObjectWithIdentifier[I]](next genericports.GenericStoragePort[I, T], opts Options) genericports.GenericStoragePort[I, T] {
+	return &storage[I, T]{observer: newObserver(opts), next: next}
+}
+
+func (s *storage[I, T]) GetObjects(ctx context.Context) (result []*T, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_objects", start, err, true) }(time.Now())
+	return s.next.GetObjects(ctx)
+}
+
+func (s *storage[I, T]) GetObjectByID(ctx context.Context, id I) (result *T, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_object_by_id", start, err, true) }(time.Now())
+	return s.next.GetObjectByID(ctx, id)
+}
+
+func (s *storage[I, T]) CreateObject(ctx context.Context, fullyReadyObject *T) (result *T, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_object", start, err, true) }(time.Now())
+	return s.next.CreateObject(ctx, fullyReadyObject)
+}
+
+func (s *storage[I, T]) UpdateObject(ctx context.Context, fullyReadyObject *T) (result *T, err error) {
+	defer func(start time.Time) { s.observe(ctx, "update_object", start, err, true) }(time.Now())
+	return s.next.UpdateObject(ctx, fullyReadyObject)
+}
+
+func (s *storage[I, T]) DeleteObject(ctx context.Context, id I) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "delete_object", start, err, true) }(time.Now())
+	return s.next.DeleteObject(ctx, id)
+}
+
+//endregion
+
+//region genericports.GenericCachePort
+
+type cachePort[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	observer
+	next genericports.GenericCachePort[I, T]
+}
+
+// NewCachePort wraps genericports.GenericCachePort with logging, timing and error classification
+func NewCachePort[I comparable, T genericports.ObjectWithIdentifier[I]](next genericports.GenericCachePort[I, T], opts Options) genericports.GenericCachePort[I, T] {
+	return &cachePort[I, T]{observer: newObserver(opts), next: next}
+}
+
+func (c *cachePort[I, T]) GetObjectByID(ctx context.Context, id I) (result *T, err error) {
+	defer func(start time.Time) { c.observe(ctx, "get_object_by_id", start, err, true) }(time.Now())
+	return c.next.GetObjectByID(ctx, id)
+}
+
+func (c *cachePort[I, T]) SaveObject(ctx context.Context, fullyReadyObject *T) (result *T, err error) {
+	defer func(start time.Time) { c.observe(ctx, "save_object", start, err, true) }(time.Now())
+	return c.next.SaveObject(ctx, fullyReadyObject)
+}
+
+func (c *cachePort[I, T]) DeleteObject(ctx context.Context, id I) (err error) {
+	defer func(start time.Time) { c.observe(ctx, "delete_object", start, err, true) }(time.Now())
+	return c.next.DeleteObject(ctx, id)
+}
+
+//endregion
+
+//region pkgports.Cache
+
+type cache[K comparable, V any] struct {
+	observer
+	next pkgports.Cache[K, V]
+}
+
+// NewCache wraps pkgports.Cache with logging, timing and error classification
+func NewCache[K comparable, V any](next pkgports.Cache[K, V], opts Options) pkgports.Cache[K, V] {
+	return &cache[K, V]{observer: newObserver(opts), next: next}
+}
+
+func (c *cache[K, V]) Set(ctx context.Context, key K, value V) (err error) {
+	defer func(start time.Time) { c.observe(ctx, "set", start, err, true) }(time.Now())
+	return c.next.Set(ctx, key, value)
+}
+
+func (c *cache[K, V]) SetWithTTL(ctx context.Context, key K, value V, ttl time.Duration) (err error) {
+	defer func(start time.Time) { c.observe(ctx, "set_with_ttl", start, err, true) }(time.Now())
+	return c.next.SetWithTTL(ctx, key, value, ttl)
+}
+
+func (c *cache[K, V]) Get(ctx context.Context, key K) (value V, found bool, err error) {
+	defer func(start time.Time) { c.observe(ctx, "get", start, err, true) }(time.Now())
+	return c.next.Get(ctx, key)
+}
+
+func (c *cache[K, V]) Peek(ctx context.Context, key K) (value V, found bool, err error) {
+	defer func(start time.Time) { c.observe(ctx, "peek", start, err, true) }(time.Now())
+	return c.next.Peek(ctx, key)
+}
+
+func (c *cache[K, V]) Contains(ctx context.Context, key K) (contains bool, err error) {
+	defer func(start time.Time) { c.observe(ctx, "contains", start, err, true) }(time.Now())
+	return c.next.Contains(ctx, key)
+}
+
+func (c *cache[K, V]) Delete(ctx context.Context, key K) (err error) {
+	defer func(start time.Time) { c.observe(ctx, "delete", start, err, true) }(time.Now())
+	return c.next.Delete(ctx, key)
+}
+
+func (c *cache[K, V]) Clear(ctx context.Context) (err error) {
+	defer func(start time.Time) { c.observe(ctx, "clear", start, err, true) }(time.Now())
+	return c.next.Clear(ctx)
+}
+
+func (c *cache[K, V]) GetKeys(ctx context.Context) (keys []K, err error) {
+	defer func(start time.Time) { c.observe(ctx, "get_keys", start, err, true) }(time.Now())
+	return c.next.GetKeys(ctx)
+}
+
+func (c *cache[K, V]) GetKeysAmount(ctx context.Context) (amount int, err error) {
+	defer func(start time.Time) { c.observe(ctx, "get_keys_amount", start, err, true) }(time.Now())
+	return c.next.GetKeysAmount(ctx)
+}
+
+//endregion
+
+//region pkgports.Receiver
+
+type receiver[V, M any] struct {
+	observer
+	next pkgports.Receiver[V, M]
+}
+
+// NewReceiver wraps pkgports.Receiver with logging, timing and error classification
+//
+// Consume waits for messages, so it's never reported as slow
+func NewReceiver[V, M any](next pkgports.Receiver[V, M], opts Options) pkgports.Receiver[V, M] {
+	return &receiver[V, M]{observer: newObserver(opts), next: next}
+}
+
+func (r *receiver[V, M]) Consume(ctx context.Context) (value V, message M, err error) {
+	defer func(start time.Time) { r.observe(ctx, "consume", start, err, false) }(time.Now())
+	return r.next.Consume(ctx)
+}
+
+func (r *receiver[V, M]) OnSuccess(ctx context.Context, givenMessage M) (err error) {
+	defer func(start time.Time) { r.observe(ctx, "on_success", start, err, true) }(time.Now())
+	return r.next.OnSuccess(ctx, givenMessage)
+}
+
+func (r *receiver[V, M]) OnFail(ctx context.Context, shouldRetry bool, givenMessage M) (err error) {
+	defer func(start time.Time) { r.observe(ctx, "on_fail", start, err, true) }(time.Now())
+	return r.next.OnFail(ctx, shouldRetry, givenMessage)
+}
+
+//endregion
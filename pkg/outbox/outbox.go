@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/postgres"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// ErrNoTransaction describes an error when events are added outside postgres.Transactor,
+// they'd be saved regardless of the business data then
+var ErrNoTransaction = errors.New("outbox events must be added within a transaction")
+
+// Schema is the outbox table, put it in your migrations with your table name instead of %[1]s
+//
+//	fmt.Sprintf(outbox.Schema, "outbox")
+const Schema = `CREATE TABLE IF NOT EXISTS %[1]s (
+    id              BIGSERIAL PRIMARY KEY,
+    aggregate_key   TEXT        NOT NULL,
+    topic           TEXT        NOT NULL,
+    payload         BYTEA       NOT NULL,
+    headers         JSONB       NOT NULL DEFAULT '{}',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      TEXT,
+    sent_at         TIMESTAMPTZ,
+    failed_at       TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (aggregate_key, id) WHERE sent_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS %[1]s_sent_idx ON %[1]s (sent_at) WHERE sent_at IS NOT NULL;`
+
+// Config is the outbox config, supposed to be used with an env-prefix of "OUTBOX_"
+type Config struct {
+	Table string `yaml:"table" env:"TABLE" env-default:"outbox"`
+
+	BatchSize      int `yaml:"batch_size" env:"BATCH_SIZE" env-default:"100"`
+	PollIntervalMs int `yaml:"poll_interval_ms" env:"POLL_INTERVAL_MS" env-default:"500"`
+
+	// MaxAttempts - after that many failures the event is marked as failed and skipped, 0 = retry forever
+	MaxAttempts       int `yaml:"max_attempts" env:"MAX_ATTEMPTS" env-default:"0"`
+	RetryBackoffMs    int `yaml:"retry_backoff_ms" env:"RETRY_BACKOFF_MS" env-default:"1000"`
+	MaxRetryBackoffMs int `yaml:"max_retry_backoff_ms" env:"MAX_RETRY_BACKOFF_MS" env-default:"60000"`
+
+	RetentionHours    int `yaml:"retention_hours" env:"RETENTION_HOURS" env-default:"72"`
+	CleanupIntervalMs int `yaml:"cleanup_interval_ms" env:"CLEANUP_INTERVAL_MS" env-default:"60000"`
+	CleanupBatchSize  int `yaml:"cleanup_batch_size" env:"CLEANUP_BATCH_SIZE" env-default:"1000"`
+}
+
+// withDefaults replaces zero and negative values with the env defaults,
+// a zero interval would panic the tickers of Relay.Run and a zero batch size would never publish anything
+func (c Config) withDefaults() Config {
+	if c.Table == "" {
+		c.Table = "outbox"
+	}
+	c.BatchSize = positiveOr(c.BatchSize, 100)
+	c.PollIntervalMs = positiveOr(c.PollIntervalMs, 500)
+	c.MaxAttempts = max(c.MaxAttempts, 0)
+	c.RetryBackoffMs = positiveOr(c.RetryBackoffMs, 1000)
+	c.MaxRetryBackoffMs = max(positiveOr(c.MaxRetryBackoffMs, 60000), c.RetryBackoffMs)
+	c.RetentionHours = positiveOr(c.RetentionHours, 72)
+	c.CleanupIntervalMs = positiveOr(c.CleanupIntervalMs, 60000)
+	c.CleanupBatchSize = positiveOr(c.CleanupBatchSize, 1000)
+	return c
+}
+
+// RetryBackoff is the delay before the next attempt after that many failed ones:
+// RetryBackoffMs * 2^(attempts-1), up to MaxRetryBackoffMs
+func (c Config) RetryBackoff(attempts int) time.Duration {
+	c = c.withDefaults()
+	backoff := time.Duration(c.RetryBackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(c.MaxRetryBackoffMs) * time.Millisecond
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+func positiveOr(value, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Event is a message that's published to kafka after the transaction commits
+//
+// AggregateKey is the kafka message key, events with same key are published in insertion order
+type Event struct {
+	AggregateKey string
+	Topic        string
+	Payload      []byte
+	Headers      map[string]string
+}
+
+// Outbox saves events in the outbox table
+//
+//	err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+//	    if _, err := orders.CreateObject(ctx, order); err != nil {
+//	        return err
+//	    }
+//	    return outbox.Add(ctx, outbox.Event{AggregateKey: order.OrderUID, Topic: "orders", Payload: payload})
+//	})
+type Outbox struct {
+	insertQuery string
+}
+
+// New creates a new Outbox for the table from config
+func New(cfg Config) *Outbox {
+	cfg = cfg.withDefaults()
+	return &Outbox{
+		insertQuery: fmt.Sprintf("INSERT INTO %s (aggregate_key, topic, payload, headers) VALUES ($1, $2, $3, $4)",
+			pgx.Identifier{cfg.Table}.Sanitize()),
+	}
+}
+
+// Add saves events in the transaction from ctx, returns ErrNoTransaction if there's none
+func (o *Outbox) Add(ctx context.Context, events ...Event) error {
+	tx, ok := postgres.TxFromContext(ctx)
+	if !ok {
+		return ErrNoTransaction
+	}
+
+	for _, event := range events {
+		headers := event.Headers
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headersJSON, err := json.Marshal(headers)
+		if err != nil {
+			return fmt.Errorf("error marshalling outbox event headers: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, o.insertQuery, event.AggregateKey, event.Topic, event.Payload, headersJSON)
+		if err != nil {
+			return fmt.Errorf("error inserting outbox event: %w", err)
+		}
+	}
+	return nil
+}
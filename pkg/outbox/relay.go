@@ -0,0 +1,323 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	pkgkafka "github.com/chempik1234/super-danis-library-golang/v2/pkg/kafka"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/postgres"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"time"
+)
+
+// MessageWriter is the part of *kafka.Writer that Relay needs, same as publisher.NewKafkaPublisher accepts
+type MessageWriter = pkgkafka.MessageWriter
+
+// record is a claimed outbox row
+type record struct {
+	id       int64
+	key      string
+	topic    string
+	payload  []byte
+	headers  map[string]string
+	attempts int
+}
+
+// Relay publishes outbox events to kafka
+//
+// Safe to run on several replicas:
+//
+// 1. aggregate keys are claimed with transaction-level advisory locks, so one key is served by one replica at a time
+//
+// 2. rows of claimed keys are locked with FOR UPDATE SKIP LOCKED
+//
+// 3. events of each key are published one by one in id order (keys are batched together),
+// the first failure stops the key until retry, so the order per key is kept
+//
+//	writer := kafka.NewWriter(ctx, config.Kafka)
+//	defer writer.Close()
+//
+//	relay := outbox.NewRelay(pool, writer, config.Outbox)
+//	wg.Add(1)
+//	go func() {
+//	    defer wg.Done()
+//	    relay.Run(ctx)
+//	}()
+type Relay struct {
+	pool       *pgxpool.Pool
+	transactor *postgres.Transactor
+	writer     MessageWriter
+	cfg        Config
+
+	claimQuery    string
+	selectQuery   string
+	markSentQuery string
+	markFailQuery string
+	cleanupQuery  string
+}
+
+// NewRelay creates a new Relay, writer is usually created with kafka.NewWriter.
+// Zero and negative values of cfg are replaced with the env defaults
+//
+// writer must use a keyed balancer (e.g. "hash", the default one), otherwise the order per key is lost in kafka
+func NewRelay(pool *pgxpool.Pool, writer MessageWriter, cfg Config) *Relay {
+	cfg = cfg.withDefaults()
+	table := pgx.Identifier{cfg.Table}.Sanitize()
+
+	return &Relay{
+		pool:       pool,
+		transactor: postgres.NewTransactor(pool, pgx.TxOptions{}),
+		writer:     writer,
+		cfg:        cfg,
+
+		// first pending event of every key decides if the key is ready,
+		// so later events never overtake a postponed one.
+		// Locks are taken on the limited candidates only: a subquery with LIMIT isn't flattened,
+		// so the volatile lock function isn't called for every pending key.
+		// The lock namespace is the table name passed as $2, keys of different outboxes don't collide
+		claimQuery: fmt.Sprintf(`SELECT aggregate_key FROM (
+    SELECT aggregate_key, id FROM (
+        SELECT DISTINCT ON (aggregate_key) aggregate_key, id, next_attempt_at
+        FROM %s
+        WHERE sent_at IS NULL AND failed_at IS NULL
+        ORDER BY aggregate_key, id
+    ) heads
+    WHERE next_attempt_at <= now()
+    ORDER BY id
+    LIMIT $1
+) candidates
+WHERE pg_try_advisory_xact_lock(hashtextextended($2::text || ':' || aggregate_key, 0))
+ORDER BY id`, table),
+		selectQuery: fmt.Sprintf(`SELECT id, aggregate_key, topic, payload, headers, attempts
+FROM %s
+WHERE sent_at IS NULL AND failed_at IS NULL AND aggregate_key = ANY($1)
+ORDER BY id
+LIMIT $2
+FOR UPDATE SKIP LOCKED`, table),
+		markSentQuery: fmt.Sprintf("UPDATE %s SET sent_at = now(), last_error = NULL WHERE id = ANY($1)", table),
+		markFailQuery: fmt.Sprintf(`UPDATE %s SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3,
+    failed_at = CASE WHEN $4 THEN now() END
+WHERE id = $1`, table),
+		cleanupQuery: fmt.Sprintf(`DELETE FROM %[1]s WHERE id IN (
+    SELECT id FROM %[1]s WHERE sent_at < $1 LIMIT $2
+)`, table),
+	}
+}
+
+// Run polls the outbox and cleans old rows until ctx is done, blocks
+func (r *Relay) Run(ctx context.Context) {
+	l := logger.GetOrCreateLoggerFromCtx(ctx)
+
+	pollTicker := time.NewTicker(time.Duration(r.cfg.PollIntervalMs) * time.Millisecond)
+	defer pollTicker.Stop()
+	cleanupTicker := time.NewTicker(time.Duration(r.cfg.CleanupIntervalMs) * time.Millisecond)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.Info(ctx, "outbox relay stopped")
+			return
+		case <-pollTicker.C:
+			// drain the backlog without waiting for the next tick
+			for {
+				published, err := r.Poll(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						l.Error(ctx, "outbox poll failed", zap.Error(err))
+					}
+					break
+				}
+				if published < r.cfg.BatchSize {
+					break
+				}
+			}
+		case <-cleanupTicker.C:
+			deleted, err := r.Cleanup(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					l.Error(ctx, "outbox cleanup failed", zap.Error(err))
+				}
+				continue
+			}
+			if deleted > 0 {
+				l.Debug(ctx, "outbox cleanup", zap.Int64("deleted", deleted))
+			}
+		}
+	}
+}
+
+// Poll publishes one batch of events, returns the amount of processed (sent or failed) events
+func (r *Relay) Poll(ctx context.Context) (int, error) {
+	processed := 0
+
+	err := r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		tx, _ := postgres.TxFromContext(ctx)
+
+		keys, err := r.claimKeys(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		records, err := r.selectRecords(ctx, tx, keys)
+		if err != nil {
+			return err
+		}
+
+		sentIDs, failed := r.publish(ctx, records)
+		processed = len(sentIDs) + len(failed)
+
+		if len(sentIDs) > 0 {
+			if _, err = tx.Exec(ctx, r.markSentQuery, sentIDs); err != nil {
+				return fmt.Errorf("error marking outbox events as sent: %w", err)
+			}
+		}
+		for rec, publishErr := range failed {
+			if err = r.markFailed(ctx, tx, rec, publishErr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error polling outbox: %w", err)
+	}
+	return processed, nil
+}
+
+// Cleanup deletes up to CleanupBatchSize events that were sent more than RetentionHours ago
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-time.Duration(r.cfg.RetentionHours) * time.Hour)
+	tag, err := r.pool.Exec(ctx, r.cleanupQuery, before, r.cfg.CleanupBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error cleaning outbox: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *Relay) claimKeys(ctx context.Context, tx pgx.Tx) ([]string, error) {
+	rows, err := tx.Query(ctx, r.claimQuery, r.cfg.BatchSize, r.cfg.Table)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming outbox keys: %w", err)
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("error reading outbox keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *Relay) selectRecords(ctx context.Context, tx pgx.Tx, keys []string) ([]*record, error) {
+	rows, err := tx.Query(ctx, r.selectQuery, keys, r.cfg.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]*record, 0)
+	for rows.Next() {
+		rec := &record{}
+		var headersJSON []byte
+		if err = rows.Scan(&rec.id, &rec.key, &rec.topic, &rec.payload, &headersJSON, &rec.attempts); err != nil {
+			return nil, fmt.Errorf("error scanning outbox event: %w", err)
+		}
+		if err = json.Unmarshal(headersJSON, &rec.headers); err != nil {
+			return nil, fmt.Errorf("error unmarshalling outbox event %d headers: %w", rec.id, err)
+		}
+		records = append(records, rec)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading outbox events: %w", err)
+	}
+	return records, nil
+}
+
+// publish sends records in rounds: round N contains the N-th event of every key that hasn't failed yet
+//
+// returns ids of sent records and errors of failed ones (one per failed key)
+func (r *Relay) publish(ctx context.Context, records []*record) ([]int64, map[*record]error) {
+	queues := make(map[string][]*record)
+	keysOrder := make([]string, 0)
+	for _, rec := range records {
+		if _, ok := queues[rec.key]; !ok {
+			keysOrder = append(keysOrder, rec.key)
+		}
+		queues[rec.key] = append(queues[rec.key], rec)
+	}
+
+	sentIDs := make([]int64, 0, len(records))
+	failed := make(map[*record]error)
+
+	for round := 0; ; round++ {
+		batch := make([]*record, 0, len(keysOrder))
+		for _, key := range keysOrder {
+			if queue := queues[key]; round < len(queue) {
+				batch = append(batch, queue[round])
+			}
+		}
+		if len(batch) == 0 {
+			return sentIDs, failed
+		}
+
+		messages := make([]kafka.Message, len(batch))
+		for i, rec := range batch {
+			messages[i] = rec.message()
+		}
+
+		err := r.writer.WriteMessages(ctx, messages...)
+
+		var writeErrors kafka.WriteErrors
+		isPartial := errors.As(err, &writeErrors) && len(writeErrors) == len(batch)
+
+		for i, rec := range batch {
+			recErr := err
+			if isPartial {
+				recErr = writeErrors[i]
+			}
+			if recErr == nil {
+				sentIDs = append(sentIDs, rec.id)
+				continue
+			}
+			// the key stops here, its later events wait for this one
+			failed[rec] = recErr
+			queues[rec.key] = queues[rec.key][:round]
+		}
+	}
+}
+
+func (r *Relay) markFailed(ctx context.Context, tx pgx.Tx, rec *record, publishErr error) error {
+	attempts := rec.attempts + 1
+	giveUp := r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts
+
+	if giveUp {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "outbox event failed, max attempts reached",
+			zap.Int64("id", rec.id), zap.String("key", rec.key), zap.Int("attempts", attempts), zap.Error(publishErr))
+	} else {
+		logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "outbox event publish failed, will retry",
+			zap.Int64("id", rec.id), zap.String("key", rec.key), zap.Int("attempts", attempts), zap.Error(publishErr))
+	}
+
+	nextAttempt := time.Now().Add(r.cfg.RetryBackoff(attempts))
+	_, err := tx.Exec(ctx, r.markFailQuery, rec.id, publishErr.Error(), nextAttempt, giveUp)
+	if err != nil {
+		return fmt.Errorf("error marking outbox event %d as failed: %w", rec.id, err)
+	}
+	return nil
+}
+
+func (rec *record) message() kafka.Message {
+	return kafka.Message{
+		Topic:   rec.topic,
+		Key:     []byte(rec.key),
+		Value:   rec.payload,
+		Headers: pkgkafka.HeadersFromMap(rec.headers),
+	}
+}
@@ -2,52 +2,77 @@ package genericport
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
 	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/stats"
 	"github.com/go-redis/redis/v8"
 	"time"
 )
 
-// RedisGenericCache - implement genericports.GenericCachePort
+// notFoundMarker is saved instead of an object known not to exist,
+// it's neither valid JSON nor data of codec.Header
+const notFoundMarker = "\x00not_found"
+
+// RedisGenericCache - implement genericports.NotFoundCachePort
+//
+// It's a pkgports.CacheStatsReporter: every GetObjectByID round trip is a load, a not-found record is a hit
 type RedisGenericCache[K comparable, V genericports.ObjectWithIdentifier[K]] struct {
 	client *redis.Client
 	ttl    time.Duration
+	codec  codec.Codec[V]
+
+	stats stats.Counters
 }
 
-// NewRedisGenericCache creates a new instance of RedisGenericCache
+// NewRedisGenericCache creates a new instance of RedisGenericCache that saves objects as JSON
 func NewRedisGenericCache[K comparable, V genericports.ObjectWithIdentifier[K]](addr string, password string, db int, ttlMs int) *RedisGenericCache[K, V] {
+	return NewRedisGenericCacheWithCodec[K, V](addr, password, db, ttlMs, codec.NewJSON[V]())
+}
+
+// NewRedisGenericCacheWithCodec creates a new instance of RedisGenericCache that saves objects with given codec.
+// Use codec.Header to switch from JSON without a flush of saved objects
+func NewRedisGenericCacheWithCodec[K comparable, V genericports.ObjectWithIdentifier[K]](addr string, password string, db int, ttlMs int, codec codec.Codec[V]) *RedisGenericCache[K, V] {
 	client := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       db,
 	})
-	return &RedisGenericCache[K, V]{client: client, ttl: time.Duration(ttlMs) * time.Millisecond}
+	return &RedisGenericCache[K, V]{client: client, ttl: time.Duration(ttlMs) * time.Millisecond, codec: codec}
 }
 
-// GetObjectByID - impl genericports.GenericCachePort.GetObjectByID
+// GetObjectByID - impl genericports.GenericCachePort.GetObjectByID,
+// genericports.ErrCachedNotFound if the ID is saved with SaveNotFound
 func (s *RedisGenericCache[K, V]) GetObjectByID(ctx context.Context, id K) (*V, error) {
 	key := generateKey(id)
+	start := time.Now()
 	data, err := s.client.Get(ctx, key).Result()
+	s.stats.Load(time.Since(start))
 	if err != nil {
 		if err == redis.Nil {
+			s.stats.Miss()
 			return nil, nil // Not found
 		}
 		return nil, err // Other errors
 	}
+	s.stats.Hit()
+	if data == notFoundMarker {
+		return nil, fmt.Errorf("%w: id '%v'", genericports.ErrCachedNotFound, id)
+	}
 
-	var obj V
-	if err := json.Unmarshal([]byte(data), &obj); err != nil {
+	obj, err := s.codec.Decode([]byte(data))
+	if err != nil {
 		return nil, err
 	}
 
 	return &obj, nil
 }
 
-// SaveObject - impl genericports.GenericCachePort.SaveObject
+// SaveObject - impl genericports.GenericCachePort.SaveObject, replaces a not-found record of the ID
 func (s *RedisGenericCache[K, V]) SaveObject(ctx context.Context, fullyReadyObject *V) (*V, error) {
 	key := generateKey((*fullyReadyObject).GetUniqueIdentifier())
-	data, err := json.Marshal(fullyReadyObject)
+	data, err := s.codec.Encode(*fullyReadyObject)
 	if err != nil {
 		return nil, err
 	}
@@ -55,14 +80,44 @@ func (s *RedisGenericCache[K, V]) SaveObject(ctx context.Context, fullyReadyObje
 	if err := s.client.Set(ctx, key, data, s.ttl).Err(); err != nil {
 		return nil, err
 	}
+	s.stats.Set()
 
 	return fullyReadyObject, nil
 }
 
+// SaveNotFound - impl genericports.NotFoundCachePort.SaveNotFound, the record is kept under the key of the object
+func (s *RedisGenericCache[K, V]) SaveNotFound(ctx context.Context, id K, ttl time.Duration) error {
+	if err := s.client.Set(ctx, generateKey(id), notFoundMarker, ttl).Err(); err != nil {
+		return err
+	}
+	s.stats.Set()
+	return nil
+}
+
 // DeleteObject - impl genericports.GenericCachePort.DeleteObject
 func (s *RedisGenericCache[K, V]) DeleteObject(ctx context.Context, id K) error {
 	key := generateKey(id)
-	return s.client.Del(ctx, key).Err()
+	deleted, err := s.client.Del(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		s.stats.Evict("deleted")
+	}
+	return nil
+}
+
+// Stats - impl pkgports.CacheStatsReporter.Stats
+//
+// Size is always 0: Redis can't count keys of one prefix without a scan.
+// Values expired by the TTL aren't seen, they're counted as misses
+func (s *RedisGenericCache[K, V]) Stats() pkgports.CacheStats {
+	return s.stats.Snapshot(0)
+}
+
+// ResetStats - impl pkgports.CacheStatsReporter.ResetStats
+func (s *RedisGenericCache[K, V]) ResetStats() {
+	s.stats.Reset()
 }
 
 // generateKey generates a Redis key based on the ID
@@ -0,0 +1,55 @@
+package loading
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrLoaderPanic is the error of a load whose loader panicked
+var ErrLoaderPanic = errors.New("loader panicked")
+
+// call is a load shared by all callers of one key
+type call[Value any] struct {
+	done  chan struct{}
+	value Value
+	err   error
+}
+
+// flight collapses concurrent loads of one key into one call
+type flight[Key comparable, Value any] struct {
+	mu    sync.Mutex
+	calls map[Key]*call[Value]
+}
+
+// start runs load in a new goroutine unless a load of the key is running, returns the running call then.
+// started tells if load was started by this call. A panic of load is the ErrLoaderPanic error of the call
+// instead of a crash of the process, waiters of the call get it too
+func (f *flight[Key, Value]) start(key Key, load func() (Value, error)) (c *call[Value], started bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.calls[key]; ok {
+		return c, false
+	}
+	if f.calls == nil {
+		f.calls = make(map[Key]*call[Value])
+	}
+
+	c = &call[Value]{done: make(chan struct{})}
+	f.calls[key] = c
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.value, c.err = *new(Value), fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+			}
+
+			f.mu.Lock()
+			delete(f.calls, key)
+			f.mu.Unlock()
+			close(c.done)
+		}()
+		c.value, c.err = load()
+	}()
+	return c, true
+}
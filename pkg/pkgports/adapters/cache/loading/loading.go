@@ -0,0 +1,206 @@
+// Package loading is a pkgports.Cache that loads missing values itself, so a hot key that expires
+// is loaded from storage once instead of by every concurrent request
+//
+//	orders := loading.New(lru.NewCacheLRUInMemory[string, loading.Entry[models.Order]](10_000), loading.Options{
+//	    TTL:     10 * time.Minute,
+//	    SoftTTL: time.Minute, // older values are returned while a refresh runs in the background
+//	})
+//
+//	order, err := orders.GetOrLoad(ctx, orderUID, func(ctx context.Context, orderUID string) (models.Order, error) {
+//	    return s.storage.GetOrder(ctx, orderUID)
+//	})
+package loading
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/memory"
+	"go.uber.org/zap"
+	"time"
+)
+
+// maxCachedErrors is the capacity of cached loader errors, the oldest ones are forgotten first
+const maxCachedErrors = 10_000
+
+// Loader loads the value of a missing key, e.g. from storage
+type Loader[Key comparable, Value any] func(ctx context.Context, key Key) (Value, error)
+
+// Entry is a value saved in the underlying cache with the time it was loaded.
+// Fields are exported, so codecs of Redis caches can encode it
+type Entry[Value any] struct {
+	Value    Value     `json:"value"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// Options configure Cache
+type Options struct {
+	// TTL is the expiration of loaded values, 0 means the underlying cache decides with its Set
+	TTL time.Duration
+	// SoftTTL is the age after which GetOrLoad returns the saved value and refreshes it in the background,
+	// 0 means values are used until they expire
+	SoftTTL time.Duration
+	// ErrorTTL is how long a loader error is returned without calling the loader again.
+	// 0 means errors aren't cached. Cached errors are kept in process memory, they aren't shared by replicas
+	ErrorTTL time.Duration
+	// LoadTimeout limits a load, 0 means the load runs as long as the loader does.
+	// Loads are shared, so they don't stop when ctx of one caller is done
+	LoadTimeout time.Duration
+}
+
+// Cache - implement pkgports.Cache over a cache of Entry with GetOrLoad
+type Cache[Key comparable, Value any] struct {
+	cache  pkgports.Cache[Key, Entry[Value]]
+	opts   Options
+	flight flight[Key, Value]
+	// errors are cached loader errors, nil if Options.ErrorTTL is 0
+	errors *memory.Cache[Key, error]
+}
+
+// New creates a new instance of Cache over cache
+func New[Key comparable, Value any](cache pkgports.Cache[Key, Entry[Value]], opts Options) *Cache[Key, Value] {
+	opts.TTL, opts.SoftTTL = max(0, opts.TTL), max(0, opts.SoftTTL)
+
+	c := &Cache[Key, Value]{cache: cache, opts: opts}
+	if opts.ErrorTTL > 0 {
+		// PolicyLRU is always supported
+		c.errors, _ = memory.New[Key, error](memory.Options{Capacity: maxCachedErrors, TTL: opts.ErrorTTL})
+	}
+	return c
+}
+
+// GetOrLoad returns the saved value or loads it with loader, concurrent calls for one key share one load.
+//
+// A value older than Options.SoftTTL is returned as is and refreshed in the background.
+// Loader errors are returned to every caller that shares the load and are cached only with Options.ErrorTTL,
+// a saved value is returned before a cached error. Errors of background refreshes are logged and aren't cached.
+// A panic of loader is returned as ErrLoaderPanic.
+// Errors of the underlying cache are logged, the value is loaded then
+func (c *Cache[Key, Value]) GetOrLoad(ctx context.Context, key Key, loader Loader[Key, Value]) (Value, error) {
+	entry, found, err := c.cache.Get(ctx, key)
+	if err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error getting value from loading cache, loading it",
+			zap.Any("key", key), zap.Error(err))
+	}
+	if found {
+		if c.opts.SoftTTL > 0 && time.Since(entry.LoadedAt) >= c.opts.SoftTTL {
+			c.refresh(ctx, key, loader)
+		}
+		return entry.Value, nil
+	}
+
+	if c.errors != nil {
+		if err, found, _ := c.errors.Get(ctx, key); found {
+			return *new(Value), err
+		}
+	}
+
+	call, _ := c.flight.start(key, func() (Value, error) {
+		return c.load(ctx, key, loader, true)
+	})
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		return *new(Value), ctx.Err()
+	}
+}
+
+// refresh loads a stale value in the background unless it's already loading.
+// Its error isn't cached: the stale value is still served until it expires
+func (c *Cache[Key, Value]) refresh(ctx context.Context, key Key, loader Loader[Key, Value]) {
+	c.flight.start(key, func() (Value, error) {
+		value, err := c.load(ctx, key, loader, false)
+		if err != nil {
+			logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error refreshing stale cache value, keeping it",
+				zap.Any("key", key), zap.Error(err))
+		}
+		return value, err
+	})
+}
+
+// load calls loader detached from the caller and saves the result, cacheErr tells if an error is cached with Options.ErrorTTL
+func (c *Cache[Key, Value]) load(ctx context.Context, key Key, loader Loader[Key, Value], cacheErr bool) (Value, error) {
+	ctx = context.WithoutCancel(ctx)
+	if c.opts.LoadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.LoadTimeout)
+		defer cancel()
+	}
+
+	value, err := loader(ctx, key)
+	if err != nil {
+		if c.errors != nil && cacheErr {
+			_ = c.errors.Set(ctx, key, err)
+		}
+		return *new(Value), err
+	}
+
+	if err = c.Set(ctx, key, value); err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error saving loaded value",
+			zap.Any("key", key), zap.Error(err))
+	}
+	return value, nil
+}
+
+// Get - impl pkgports.Cache.Get, a stale value isn't refreshed
+func (c *Cache[Key, Value]) Get(ctx context.Context, key Key) (Value, bool, error) {
+	entry, found, err := c.cache.Get(ctx, key)
+	return entry.Value, found, err
+}
+
+// Set - impl pkgports.Cache.Set, the value expires after Options.TTL
+func (c *Cache[Key, Value]) Set(ctx context.Context, key Key, value Value) error {
+	if c.opts.TTL > 0 {
+		return c.SetWithTTL(ctx, key, value, c.opts.TTL)
+	}
+	if c.errors != nil {
+		_ = c.errors.Delete(ctx, key)
+	}
+	return c.cache.Set(ctx, key, Entry[Value]{Value: value, LoadedAt: time.Now()})
+}
+
+// SetWithTTL - impl pkgports.Cache.SetWithTTL, a cached loader error of the key is forgotten
+func (c *Cache[Key, Value]) SetWithTTL(ctx context.Context, key Key, value Value, ttl time.Duration) error {
+	if c.errors != nil {
+		_ = c.errors.Delete(ctx, key)
+	}
+	return c.cache.SetWithTTL(ctx, key, Entry[Value]{Value: value, LoadedAt: time.Now()}, ttl)
+}
+
+// Peek - impl pkgports.Cache.Peek
+func (c *Cache[Key, Value]) Peek(ctx context.Context, key Key) (Value, bool, error) {
+	entry, found, err := c.cache.Peek(ctx, key)
+	return entry.Value, found, err
+}
+
+// Contains - impl pkgports.Cache.Contains
+func (c *Cache[Key, _]) Contains(ctx context.Context, key Key) (bool, error) {
+	return c.cache.Contains(ctx, key)
+}
+
+// Delete - impl pkgports.Cache.Delete, a cached loader error of the key is forgotten too
+func (c *Cache[Key, _]) Delete(ctx context.Context, key Key) error {
+	if c.errors != nil {
+		_ = c.errors.Delete(ctx, key)
+	}
+	return c.cache.Delete(ctx, key)
+}
+
+// Clear - impl pkgports.Cache.Clear, cached loader errors are forgotten too
+func (c *Cache[_, _]) Clear(ctx context.Context) error {
+	if c.errors != nil {
+		_ = c.errors.Clear(ctx)
+	}
+	return c.cache.Clear(ctx)
+}
+
+// GetKeys - impl pkgports.Cache.GetKeys
+func (c *Cache[Key, _]) GetKeys(ctx context.Context) ([]Key, error) {
+	return c.cache.GetKeys(ctx)
+}
+
+// GetKeysAmount - impl pkgports.Cache.GetKeysAmount
+func (c *Cache[_, _]) GetKeysAmount(ctx context.Context) (int, error) {
+	return c.cache.GetKeysAmount(ctx)
+}
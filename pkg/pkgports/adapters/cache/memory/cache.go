@@ -0,0 +1,443 @@
+// Package memory is an in-process pkgports.Cache with a choice of eviction policy
+//
+//	reports, err := memory.New[string, models.Report](memory.Options{Capacity: 10_000, Policy: memory.PolicyTinyLFU})
+//
+// Plain LRU is flushed by one scan of keys that are never read again (e.g. a batch report),
+// LFU, ARC, 2Q and W-TinyLFU keep entries that are used often. lru.CacheLRUInMemory is this cache with PolicyLRU
+//
+// Entries may expire: Options.TTL for all values saved with Set, or SetWithTTL for one value.
+// Expired entries are never returned and are removed on read, on overflow and by the janitor:
+//
+//	go reports.Run(ctx, time.Minute) // stops when ctx is done
+//
+// With Options.MaxCost the capacity is a budget of costs instead of an amount of entries,
+// e.g. bytes measured by DefaultCost, so a few big values can't take all the memory
+//
+// Snapshots keep the cache warm between restarts:
+//
+//	_, err = reports.LoadSnapshot(ctx, "reports.snapshot", memory.SnapshotOptions[string, models.Report]{})
+//	go reports.RunSnapshots(ctx, "reports.snapshot", time.Minute, memory.SnapshotOptions[string, models.Report]{})
+package memory
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/linkedlist"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/stats"
+	"go.uber.org/zap"
+	"math"
+	"sync"
+	"time"
+)
+
+// Options configure Cache
+type Options struct {
+	// Capacity is the max amount of entries, unlimited if it's <= 0 and MaxCost is set
+	Capacity int
+	// MaxCost is the max total cost of entries, 0 means every entry costs 1 and only Capacity counts.
+	// Policies balance their segments by cost then
+	MaxCost int64
+	// Cost measures values when MaxCost is set, DefaultCost by default
+	Cost CostFunc
+	// Policy decides which entry is evicted on overflow, PolicyLRU by default
+	Policy Policy
+	// TTL is the expiration of values saved with Set, 0 means they never expire. SetWithTTL overrides it
+	TTL time.Duration
+}
+
+// Cache - implement pkgports.Cache in process memory
+//
+// Every key is stored in a map pointing to its node in the policy lists, so hits, inserts and evictions are O(1).
+// Get reorders entries, so it takes the write lock; Peek, Contains and GetKeys only read and share sync.RWMutex
+type Cache[Key comparable, Value any] struct {
+	mu      sync.RWMutex
+	items   map[Key]*entry[Key, Value]
+	policy  policy[Key, Value]
+	cap     int
+	kind    Policy
+	maxCost int64
+	costOf  CostFunc
+	// cost is the total cost of entries
+	cost     int64
+	ttl      time.Duration
+	expiries expiryHeap[Key, Value]
+
+	hooks     []EvictHook[Key, Value]
+	evictions []eviction[Key, Value]
+
+	stats stats.Counters
+}
+
+// New creates a new instance of Cache, returns ErrUnknownPolicy if opts.Policy isn't supported
+func New[Key comparable, Value any](opts Options) (*Cache[Key, Value], error) {
+	if opts.Policy == "" {
+		opts.Policy = PolicyLRU
+	}
+	opts.Capacity = max(0, opts.Capacity)
+	opts.MaxCost = max(0, opts.MaxCost)
+	if opts.Cost == nil {
+		opts.Cost = DefaultCost
+	}
+
+	budget := int64(opts.Capacity)
+	if opts.MaxCost > 0 {
+		budget = opts.MaxCost
+		if opts.Capacity == 0 {
+			opts.Capacity = math.MaxInt
+		}
+	}
+
+	p, err := newPolicy[Key, Value](opts.Policy, budget)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache[Key, Value]{
+		items:   make(map[Key]*entry[Key, Value]),
+		policy:  p,
+		cap:     opts.Capacity,
+		kind:    opts.Policy,
+		maxCost: opts.MaxCost,
+		costOf:  opts.Cost,
+		ttl:     max(0, opts.TTL),
+	}, nil
+}
+
+// GetCapacity returns read-only value of Cache capacity, math.MaxInt if only MaxCost limits it
+func (c *Cache[Key, Value]) GetCapacity() int {
+	return c.cap
+}
+
+// GetMaxCost returns Options.MaxCost, 0 if entries aren't weighted
+func (c *Cache[_, _]) GetMaxCost() int64 {
+	return c.maxCost
+}
+
+// Cost returns the total cost of saved entries, their amount if there's no Options.MaxCost
+func (c *Cache[_, _]) Cost() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cost
+}
+
+// Policy returns the eviction policy
+func (c *Cache[_, _]) Policy() Policy {
+	return c.kind
+}
+
+// Get tries to get an item by key, logs on miss
+//
+// It's counted as a use, an expired item is removed
+func (c *Cache[Key, Value]) Get(ctx context.Context, key Key) (Value, bool, error) {
+	c.mu.Lock()
+	defer c.unlock(ctx)
+
+	now := time.Now()
+	e, ok := c.items[key]
+	if ok && e.expired(now) {
+		c.remove(e, EvictExpired)
+		ok = false
+	}
+	if !ok {
+		c.stats.Miss()
+		logger.GetOrCreateLoggerFromCtx(ctx).Debug(ctx, "in-memory cache miss", zap.Any("key", key))
+		return *new(Value), false, nil
+	}
+
+	c.stats.Hit()
+	e.usedAt = now
+	c.policy.hit(e)
+	return e.value, true, nil
+}
+
+// Set saves the value with Options.TTL, it's counted as a use
+func (c *Cache[Key, Value]) Set(ctx context.Context, key Key, value Value) error {
+	return c.SetWithTTL(ctx, key, value, c.ttl)
+}
+
+// SetWithTTL saves the value like Set, it expires after ttl (never if ttl <= 0)
+//
+// A value that costs more than Options.MaxCost isn't saved, ErrCostExceeded is returned
+// and the previous value of the key is removed as replaced
+func (c *Cache[Key, Value]) SetWithTTL(ctx context.Context, key Key, value Value, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock(ctx)
+
+	cost := int64(1)
+	if c.maxCost > 0 {
+		cost = max(1, c.costOf(value))
+	}
+	if cost > c.maxCost && c.maxCost > 0 {
+		if e, ok := c.items[key]; ok {
+			c.remove(e, EvictReplaced)
+		}
+		return fmt.Errorf("%w: key '%v' costs %d, max cost is %d", ErrCostExceeded, key, cost, c.maxCost)
+	}
+
+	c.stats.Set()
+	now := time.Now()
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.evicted(key, e.value, EvictReplaced)
+		e.value, e.usedAt = value, now
+		c.setCost(e, cost)
+		c.setExpiry(e, expiresAt)
+		c.policy.hit(e)
+	} else {
+		e = &entry[Key, Value]{key: key, value: value, usedAt: now, expiryIndex: -1, cost: cost}
+		c.items[key] = e
+		c.cost += cost
+		c.setExpiry(e, expiresAt)
+		c.policy.add(e)
+	}
+
+	// expired values go first, then remove values while we're out of space
+	if c.overflowed() {
+		c.removeExpired(now)
+	}
+	for c.overflowed() {
+		evicted := c.policy.victim()
+		c.remove(evicted, EvictCapacity)
+
+		logger.GetOrCreateLoggerFromCtx(ctx).Debug(ctx, "cache overflow, erased a value",
+			zap.Any("key", evicted.key), zap.Int("length", len(c.items)),
+			zap.Int("capacity", c.GetCapacity()), zap.Int64("cost", c.cost))
+	}
+
+	return nil
+}
+
+// Peek returns the value without counting it as a use
+func (c *Cache[Key, Value]) Peek(_ context.Context, key Key) (Value, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.items[key]
+	if !ok || e.expired(time.Now()) {
+		return *new(Value), false, nil
+	}
+	return e.value, true, nil
+}
+
+// Contains tells if the key is saved without counting it as a use
+func (c *Cache[Key, Value]) Contains(_ context.Context, key Key) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.items[key]
+	return ok && !e.expired(time.Now()), nil
+}
+
+// Delete removes the key, missing key is ignored
+func (c *Cache[Key, Value]) Delete(ctx context.Context, key Key) error {
+	c.mu.Lock()
+	defer c.unlock(ctx)
+
+	if e, ok := c.items[key]; ok {
+		c.remove(e, EvictDeleted)
+	}
+	return nil
+}
+
+// Clear removes all keys and the policy history, hooks get every entry
+func (c *Cache[Key, Value]) Clear(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock(ctx)
+
+	for _, e := range c.items {
+		c.evicted(e.key, e.value, EvictDeleted)
+	}
+	c.items = make(map[Key]*entry[Key, Value])
+	c.policy.clear()
+	c.expiries = nil
+	c.cost = 0
+	return nil
+}
+
+// GetKeysAmount returns the amount of not expired keys
+func (c *Cache[_, _]) GetKeysAmount(_ context.Context) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	amount := 0
+	now := time.Now()
+	for _, e := range c.items {
+		if !e.expired(now) {
+			amount++
+		}
+	}
+	return amount, nil
+}
+
+// GetKeys returns a snapshot of not expired keys in order from the Most to the least valuable for the policy,
+// from the most to the least recently used for PolicyLRU
+func (c *Cache[Key, Value]) GetKeys(_ context.Context) ([]Key, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]Key, 0, len(c.items))
+	now := time.Now()
+	c.policy.walk(func(e *entry[Key, Value]) bool {
+		if !e.expired(now) {
+			result = append(result, e.key)
+		}
+		return true
+	})
+	return result, nil
+}
+
+// MostUsedKey returns the first key of GetKeys, linkedlist.ErrEmptyList if there are no keys that aren't expired
+func (c *Cache[Key, Value]) MostUsedKey() (Key, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var first *entry[Key, Value]
+	now := time.Now()
+	c.policy.walk(func(e *entry[Key, Value]) bool {
+		if e.expired(now) {
+			return true
+		}
+		first = e
+		return false
+	})
+	if first == nil {
+		return *new(Key), linkedlist.ErrEmptyList
+	}
+	return first.key, nil
+}
+
+// LeastUsedKey returns the next key to be evicted, linkedlist.ErrEmptyList if there are no keys that aren't expired
+func (c *Cache[Key, _]) LeastUsedKey() (Key, error) {
+	key, _, err := c.LeastUsed()
+	return key, err
+}
+
+// Len returns the amount of saved entries in O(1), expired ones that aren't removed yet included
+func (c *Cache[_, _]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.items)
+}
+
+// LeastUsed returns the next key to be evicted and the time of its last Get or Set,
+// linkedlist.ErrEmptyList if there are no keys that aren't expired. Expired entries are removed first
+func (c *Cache[Key, _]) LeastUsed() (Key, time.Time, error) {
+	c.mu.Lock()
+	defer c.unlock(context.Background())
+
+	c.removeExpired(time.Now())
+	victim := c.policy.victim()
+	if victim == nil {
+		return *new(Key), time.Time{}, linkedlist.ErrEmptyList
+	}
+	return victim.key, victim.usedAt, nil
+}
+
+// EvictLeastUsed removes expired entries and the next key to be evicted as if the cache overflowed,
+// false if there are no keys that aren't expired
+func (c *Cache[Key, _]) EvictLeastUsed() (Key, bool) {
+	c.mu.Lock()
+	defer c.unlock(context.Background())
+
+	c.removeExpired(time.Now())
+	victim := c.policy.victim()
+	if victim == nil {
+		return *new(Key), false
+	}
+	c.remove(victim, EvictCapacity)
+	return victim.key, true
+}
+
+// Stats - impl pkgports.CacheStatsReporter.Stats, Size is Len. Peek and Contains aren't counted
+func (c *Cache[_, _]) Stats() pkgports.CacheStats {
+	return c.stats.Snapshot(c.Len())
+}
+
+// ResetStats - impl pkgports.CacheStatsReporter.ResetStats
+func (c *Cache[_, _]) ResetStats() {
+	c.stats.Reset()
+}
+
+// RemoveExpired removes all expired entries, returns their amount
+func (c *Cache[_, _]) RemoveExpired(ctx context.Context) int {
+	c.mu.Lock()
+	defer c.unlock(ctx)
+
+	removed := c.removeExpired(time.Now())
+	if removed > 0 {
+		logger.GetOrCreateLoggerFromCtx(ctx).Debug(ctx, "removed expired cache values",
+			zap.Int("removed", removed), zap.Int("length", len(c.items)))
+	}
+	return removed
+}
+
+// Run is the janitor: it removes expired entries every interval until ctx is done
+func (c *Cache[_, _]) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.GetOrCreateLoggerFromCtx(ctx).Info(ctx, "cache janitor stopped")
+			return
+		case <-ticker.C:
+			c.RemoveExpired(ctx)
+		}
+	}
+}
+
+// removeExpired pops expired entries from the expiry heap, must be called under write lock
+func (c *Cache[_, _]) removeExpired(now time.Time) int {
+	removed := 0
+	for len(c.expiries) > 0 && c.expiries[0].expired(now) {
+		c.remove(c.expiries[0], EvictExpired)
+		removed++
+	}
+	return removed
+}
+
+// overflowed tells if there are too many entries or their cost is over the budget, must be called under lock
+func (c *Cache[_, _]) overflowed() bool {
+	return len(c.items) > c.cap || (c.maxCost > 0 && c.cost > c.maxCost)
+}
+
+// setCost changes the cost of a saved entry and the size of its policy list, must be called under write lock
+func (c *Cache[Key, Value]) setCost(e *entry[Key, Value], cost int64) {
+	c.cost += cost - e.cost
+	e.list.size += cost - e.cost
+	e.cost = cost
+}
+
+// setExpiry changes the expiration of the entry and its place in the expiry heap, must be called under write lock
+func (c *Cache[Key, Value]) setExpiry(e *entry[Key, Value], expiresAt time.Time) {
+	e.expiresAt = expiresAt
+	switch {
+	case expiresAt.IsZero() && e.expiryIndex >= 0:
+		heap.Remove(&c.expiries, e.expiryIndex)
+	case expiresAt.IsZero():
+	case e.expiryIndex >= 0:
+		heap.Fix(&c.expiries, e.expiryIndex)
+	default:
+		heap.Push(&c.expiries, e)
+	}
+}
+
+// remove deletes the entry from the policy, the expiry heap and the map, must be called under write lock
+func (c *Cache[Key, Value]) remove(e *entry[Key, Value], reason EvictReason) {
+	c.evicted(e.key, e.value, reason)
+	c.policy.remove(e, reason == EvictCapacity)
+	if e.expiryIndex >= 0 {
+		heap.Remove(&c.expiries, e.expiryIndex)
+	}
+	delete(c.items, e.key)
+	c.cost -= e.cost
+}
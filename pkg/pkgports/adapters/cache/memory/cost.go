@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrCostExceeded describes an error when the cost of one value is over Options.MaxCost, the value isn't saved
+var ErrCostExceeded = errors.New("value cost exceeds cache max cost")
+
+// CostFunc returns the cost of a value, e.g. its approximate size in bytes. Costs below 1 are counted as 1
+type CostFunc func(value any) int64
+
+// maxCostDepth limits how deep DefaultCost follows pointers, so cycles don't hang it
+const maxCostDepth = 16
+
+// DefaultCost approximates the memory taken by the value in bytes:
+// its own size plus contents of strings, slices, maps and pointers it refers to.
+// Shared and cyclic references are counted on every path up to a limited depth
+func DefaultCost(value any) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(reflect.TypeFor[string]().Size()) + int64(len(v))
+	case []byte:
+		return int64(reflect.TypeFor[[]byte]().Size()) + int64(len(v))
+	}
+	return sizeOf(reflect.ValueOf(value), 0)
+}
+
+// sizeOf returns the inline size of v plus the size of everything it refers to
+func sizeOf(v reflect.Value, depth int) int64 {
+	t := v.Type()
+	size := int64(t.Size())
+	if depth >= maxCostDepth || isFlat(t) {
+		return size
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		size += int64(v.Len())
+	case reflect.Slice:
+		if isFlat(t.Elem()) {
+			return size + int64(v.Len())*int64(t.Elem().Size())
+		}
+		for i := range v.Len() {
+			size += sizeOf(v.Index(i), depth+1)
+		}
+	case reflect.Array:
+		for i := range v.Len() {
+			size += sizeOf(v.Index(i), depth+1) - int64(t.Elem().Size())
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			field := v.Field(i)
+			size += sizeOf(field, depth+1) - int64(field.Type().Size())
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			size += sizeOf(iter.Key(), depth+1) + sizeOf(iter.Value(), depth+1)
+		}
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			size += sizeOf(v.Elem(), depth+1)
+		}
+	}
+	return size
+}
+
+// isFlat tells if values of the type don't refer to other memory
+func isFlat(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return isFlat(t.Elem())
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if !isFlat(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
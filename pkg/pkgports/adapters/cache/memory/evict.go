@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// EvictReason tells why an entry left the cache
+type EvictReason string
+
+const (
+	// EvictCapacity - the entry was the policy victim on overflow
+	EvictCapacity EvictReason = "capacity"
+	// EvictExpired - the TTL of the entry has passed
+	EvictExpired EvictReason = "expired"
+	// EvictDeleted - the entry was removed with Delete or Clear
+	EvictDeleted EvictReason = "deleted"
+	// EvictReplaced - Set saved a new value of the key, the hook gets the old one
+	EvictReplaced EvictReason = "replaced"
+)
+
+// EvictHook is called for every entry that leaves the cache
+type EvictHook[Key comparable, Value any] func(key Key, value Value, reason EvictReason)
+
+// eviction is an entry removed under the lock, hooks get it after the lock is released
+type eviction[Key comparable, Value any] struct {
+	key    Key
+	value  Value
+	reason EvictReason
+}
+
+// OnEvict adds a hook, e.g. to write back a dirty value, release resources or count evictions
+//
+// Hooks run in the goroutine of the call that removed the entry (Set, Get, Delete, the janitor...)
+// after the cache lock is released, so they may use the cache. A slow hook slows that call down.
+// A panic in a hook is recovered and logged, the other hooks still run
+func (c *Cache[Key, Value]) OnEvict(hook EvictHook[Key, Value]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks = append(c.hooks, hook)
+}
+
+// evicted counts the eviction and records the entry for hooks, must be called under write lock
+func (c *Cache[Key, Value]) evicted(key Key, value Value, reason EvictReason) {
+	c.stats.Evict(string(reason))
+	if len(c.hooks) > 0 {
+		c.evictions = append(c.evictions, eviction[Key, Value]{key: key, value: value, reason: reason})
+	}
+}
+
+// unlock releases the write lock and runs hooks for entries removed under it
+func (c *Cache[Key, Value]) unlock(ctx context.Context) {
+	evictions, hooks := c.evictions, c.hooks
+	c.evictions = nil
+	c.mu.Unlock()
+
+	for _, e := range evictions {
+		for _, hook := range hooks {
+			runHook(ctx, hook, e)
+		}
+	}
+}
+
+func runHook[Key comparable, Value any](ctx context.Context, hook EvictHook[Key, Value], e eviction[Key, Value]) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "cache evict hook panicked",
+				zap.Any("key", e.key), zap.String("reason", string(e.reason)), zap.Any("panic", r))
+		}
+	}()
+	hook(e.key, e.value, e.reason)
+}
@@ -0,0 +1,33 @@
+package memory
+
+// expiryHeap is a min-heap of entries with a TTL by expiration time, so expired entries are found without a scan.
+// It implements container/heap.Interface
+type expiryHeap[Key comparable, Value any] []*entry[Key, Value]
+
+func (h expiryHeap[_, _]) Len() int {
+	return len(h)
+}
+
+func (h expiryHeap[_, _]) Less(i, j int) bool {
+	return h[i].expiresAt.Before(h[j].expiresAt)
+}
+
+func (h expiryHeap[_, _]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].expiryIndex, h[j].expiryIndex = i, j
+}
+
+func (h *expiryHeap[Key, Value]) Push(x any) {
+	e := x.(*entry[Key, Value])
+	e.expiryIndex = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap[Key, Value]) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	e.expiryIndex = -1
+	return e
+}
@@ -0,0 +1,92 @@
+package memory
+
+import "time"
+
+// entry is a saved value and a node of one of the policy lists
+type entry[Key comparable, Value any] struct {
+	key   Key
+	value Value
+	// expiresAt is zero for values that never expire
+	expiresAt time.Time
+	// usedAt is the time of the last Get or Set
+	usedAt time.Time
+	// hash of the key, only set by policies that need it
+	hash uint64
+	// expiryIndex is the index in expiryHeap, -1 if the entry never expires
+	expiryIndex int
+	// cost of the entry, 1 if the cache has no Options.MaxCost
+	cost int64
+
+	list       *list[Key, Value]
+	prev, next *entry[Key, Value]
+}
+
+// expired tells if the entry was saved with a TTL that has passed
+func (e *entry[_, _]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// list is a doubly linked list of entries from the most (head) to the least (tail) recent,
+// size is the total cost of its entries
+//
+// freq, prev and next link lists into frequency buckets of LFU, other policies don't use them
+type list[Key comparable, Value any] struct {
+	head, tail *entry[Key, Value]
+	size       int64
+
+	freq       int
+	prev, next *list[Key, Value]
+}
+
+// pushFront links the entry as the head
+func (l *list[Key, Value]) pushFront(e *entry[Key, Value]) {
+	e.list, e.prev, e.next = l, nil, l.head
+	if l.head != nil {
+		l.head.prev = e
+	}
+	l.head = e
+	if l.tail == nil {
+		l.tail = e
+	}
+	l.size += e.cost
+}
+
+// remove unlinks the entry
+func (l *list[Key, Value]) remove(e *entry[Key, Value]) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		l.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		l.tail = e.prev
+	}
+	e.list, e.prev, e.next = nil, nil, nil
+	l.size -= e.cost
+}
+
+// moveToFront makes the entry the head
+func (l *list[Key, Value]) moveToFront(e *entry[Key, Value]) {
+	if l.head == e {
+		return
+	}
+	l.remove(e)
+	l.pushFront(e)
+}
+
+// walk calls f from the head to the tail until f returns false, tells if f never returned false
+func (l *list[Key, Value]) walk(f func(e *entry[Key, Value]) bool) bool {
+	for e := l.head; e != nil; e = e.next {
+		if !f(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// clear forgets all entries
+func (l *list[Key, Value]) clear() {
+	l.head, l.tail, l.size = nil, nil, 0
+}
@@ -0,0 +1,368 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownPolicy describes an error when Options.Policy isn't one of the Policy constants
+var ErrUnknownPolicy = errors.New("unknown cache eviction policy")
+
+// Policy decides which entry is evicted when the cache is full
+type Policy string
+
+const (
+	// PolicyLRU evicts the least recently used entry
+	PolicyLRU Policy = "lru"
+	// PolicyLFU evicts the least frequently used entry, the least recently used of them on a tie.
+	// Counts are never aged, so keys that were popular once stay until they're deleted
+	PolicyLFU Policy = "lfu"
+	// PolicyARC is the Adaptive Replacement Cache: it balances recently and frequently used entries
+	// by remembering keys evicted from both
+	PolicyARC Policy = "arc"
+	// Policy2Q keeps entries used once in a small queue (25%), so a scan can't flush entries used many times.
+	// Keys evicted from that queue are remembered and go straight to the main queue if they come back
+	Policy2Q Policy = "2q"
+	// PolicyTinyLFU is W-TinyLFU: new entries go to a small LRU window (1%), then they must beat
+	// the main space victim by an approximate frequency (count-min sketch) to stay
+	PolicyTinyLFU Policy = "tinylfu"
+)
+
+// ParsePolicy parses a Policy, e.g. from a config
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyLRU, PolicyLFU, PolicyARC, Policy2Q, PolicyTinyLFU:
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: '%s'", ErrUnknownPolicy, s)
+}
+
+// policy orders entries of a Cache, its methods are called under the cache write lock (walk and victim under read lock)
+//
+// Sizes of policy lists are total costs of entries, so the capacity of a policy is the max total cost
+type policy[Key comparable, Value any] interface {
+	// add places a new entry
+	add(e *entry[Key, Value])
+	// hit records a use of a saved entry
+	hit(e *entry[Key, Value])
+	// victim returns the entry to evict when the cache is over capacity, nil if there are no entries.
+	// It must not change anything
+	victim() *entry[Key, Value]
+	// remove unlinks an entry, evicted tells it was the victim, so it may be remembered
+	remove(e *entry[Key, Value], evicted bool)
+	// walk calls f for entries from the most to the least valuable until f returns false
+	walk(f func(e *entry[Key, Value]) bool)
+	// clear forgets all entries and history
+	clear()
+}
+
+func newPolicy[Key comparable, Value any](p Policy, capacity int64) (policy[Key, Value], error) {
+	switch p {
+	case PolicyLRU:
+		return &lru[Key, Value]{}, nil
+	case PolicyLFU:
+		return &lfu[Key, Value]{}, nil
+	case PolicyARC:
+		return newARC[Key, Value](capacity), nil
+	case Policy2Q:
+		return newTwoQueue[Key, Value](capacity), nil
+	case PolicyTinyLFU:
+		return newTinyLFU[Key, Value](capacity), nil
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrUnknownPolicy, p)
+}
+
+//region LRU
+
+type lru[Key comparable, Value any] struct {
+	entries list[Key, Value]
+}
+
+func (p *lru[Key, Value]) add(e *entry[Key, Value]) {
+	p.entries.pushFront(e)
+}
+
+func (p *lru[Key, Value]) hit(e *entry[Key, Value]) {
+	p.entries.moveToFront(e)
+}
+
+func (p *lru[Key, Value]) victim() *entry[Key, Value] {
+	return p.entries.tail
+}
+
+func (p *lru[Key, Value]) remove(e *entry[Key, Value], _ bool) {
+	p.entries.remove(e)
+}
+
+func (p *lru[Key, Value]) walk(f func(e *entry[Key, Value]) bool) {
+	p.entries.walk(f)
+}
+
+func (p *lru[Key, Value]) clear() {
+	p.entries.clear()
+}
+
+//endregion
+
+//region LFU
+
+// lfu keeps entries in frequency buckets sorted from the lowest (head) to the highest (tail) frequency,
+// so every operation is O(1)
+type lfu[Key comparable, Value any] struct {
+	head, tail *list[Key, Value]
+}
+
+// insertBucketAfter creates an empty bucket after prev, at the head if prev is nil
+func (p *lfu[Key, Value]) insertBucketAfter(prev *list[Key, Value], freq int) *list[Key, Value] {
+	b := &list[Key, Value]{freq: freq, prev: prev}
+	if prev != nil {
+		b.next, prev.next = prev.next, b
+	} else {
+		b.next, p.head = p.head, b
+	}
+	if b.next != nil {
+		b.next.prev = b
+	} else {
+		p.tail = b
+	}
+	return b
+}
+
+// removeFromBucket unlinks the entry and drops its bucket if it's empty
+func (p *lfu[Key, Value]) removeFromBucket(e *entry[Key, Value]) {
+	b := e.list
+	b.remove(e)
+	if b.size > 0 {
+		return
+	}
+	if b.prev != nil {
+		b.prev.next = b.next
+	} else {
+		p.head = b.next
+	}
+	if b.next != nil {
+		b.next.prev = b.prev
+	} else {
+		p.tail = b.prev
+	}
+}
+
+func (p *lfu[Key, Value]) add(e *entry[Key, Value]) {
+	b := p.head
+	if b == nil || b.freq != 1 {
+		b = p.insertBucketAfter(nil, 1)
+	}
+	b.pushFront(e)
+}
+
+func (p *lfu[Key, Value]) hit(e *entry[Key, Value]) {
+	current := e.list
+	next := current.next
+	if next == nil || next.freq != current.freq+1 {
+		next = p.insertBucketAfter(current, current.freq+1)
+	}
+	p.removeFromBucket(e)
+	next.pushFront(e)
+}
+
+func (p *lfu[Key, Value]) victim() *entry[Key, Value] {
+	if p.head == nil {
+		return nil
+	}
+	return p.head.tail
+}
+
+func (p *lfu[Key, Value]) remove(e *entry[Key, Value], _ bool) {
+	p.removeFromBucket(e)
+}
+
+func (p *lfu[Key, Value]) walk(f func(e *entry[Key, Value]) bool) {
+	for b := p.tail; b != nil; b = b.prev {
+		if !b.walk(f) {
+			return
+		}
+	}
+}
+
+func (p *lfu[Key, Value]) clear() {
+	p.head, p.tail = nil, nil
+}
+
+//endregion
+
+//region ARC
+
+// arc keeps entries used once in t1 and entries used more in t2, b1 and b2 are keys evicted from them.
+// A key that comes back from b1 means t1 was too small, from b2 - t2 was, so the target size of t1 moves
+type arc[Key comparable, Value any] struct {
+	capacity int64
+	// target is the desired size of t1
+	target int64
+
+	t1, t2, b1, b2 list[Key, Value]
+	ghosts         map[Key]*entry[Key, Value]
+}
+
+func newARC[Key comparable, Value any](capacity int64) *arc[Key, Value] {
+	return &arc[Key, Value]{capacity: capacity, ghosts: make(map[Key]*entry[Key, Value])}
+}
+
+func (p *arc[Key, Value]) add(e *entry[Key, Value]) {
+	ghost, ok := p.ghosts[e.key]
+	if !ok {
+		p.t1.pushFront(e)
+		return
+	}
+
+	if ghost.list == &p.b1 {
+		p.target = min(p.capacity, p.target+max(1, p.b2.size/p.b1.size))
+	} else {
+		p.target = max(0, p.target-max(1, p.b1.size/p.b2.size))
+	}
+	ghost.list.remove(ghost)
+	delete(p.ghosts, e.key)
+	p.t2.pushFront(e)
+}
+
+func (p *arc[Key, Value]) hit(e *entry[Key, Value]) {
+	if e.list == &p.t1 {
+		p.t1.remove(e)
+		p.t2.pushFront(e)
+		return
+	}
+	p.t2.moveToFront(e)
+}
+
+func (p *arc[Key, Value]) victim() *entry[Key, Value] {
+	if p.t1.size > 0 && (p.t1.size > p.target || p.t2.size == 0) {
+		return p.t1.tail
+	}
+	return p.t2.tail
+}
+
+func (p *arc[Key, Value]) remove(e *entry[Key, Value], evicted bool) {
+	from := e.list
+	from.remove(e)
+	if !evicted {
+		return
+	}
+
+	ghost := &entry[Key, Value]{key: e.key, cost: e.cost}
+	if from == &p.t1 {
+		p.b1.pushFront(ghost)
+	} else {
+		p.b2.pushFront(ghost)
+	}
+	p.ghosts[e.key] = ghost
+
+	// remember up to capacity keys, b1 is kept close to the target size of t1
+	for p.b1.size+p.b2.size > p.capacity {
+		if p.b1.size == 0 || (p.b2.size > 0 && p.b1.size <= p.target) {
+			p.dropGhost(&p.b2)
+		} else {
+			p.dropGhost(&p.b1)
+		}
+	}
+}
+
+func (p *arc[Key, Value]) dropGhost(from *list[Key, Value]) {
+	ghost := from.tail
+	from.remove(ghost)
+	delete(p.ghosts, ghost.key)
+}
+
+func (p *arc[Key, Value]) walk(f func(e *entry[Key, Value]) bool) {
+	if p.t2.walk(f) {
+		p.t1.walk(f)
+	}
+}
+
+func (p *arc[Key, Value]) clear() {
+	p.target = 0
+	p.t1.clear()
+	p.t2.clear()
+	p.b1.clear()
+	p.b2.clear()
+	p.ghosts = make(map[Key]*entry[Key, Value])
+}
+
+//endregion
+
+//region 2Q
+
+// twoQueue keeps new entries in in, entries used again in main, out are keys evicted from in
+type twoQueue[Key comparable, Value any] struct {
+	// inSize is the size of in that is evicted before main, outSize is the max size of out
+	inSize, outSize int64
+
+	in, main, out list[Key, Value]
+	ghosts        map[Key]*entry[Key, Value]
+}
+
+func newTwoQueue[Key comparable, Value any](capacity int64) *twoQueue[Key, Value] {
+	return &twoQueue[Key, Value]{
+		inSize:  max(1, capacity/4),
+		outSize: max(1, capacity/2),
+		ghosts:  make(map[Key]*entry[Key, Value]),
+	}
+}
+
+func (p *twoQueue[Key, Value]) add(e *entry[Key, Value]) {
+	ghost, ok := p.ghosts[e.key]
+	if !ok {
+		p.in.pushFront(e)
+		return
+	}
+
+	p.out.remove(ghost)
+	delete(p.ghosts, e.key)
+	p.main.pushFront(e)
+}
+
+func (p *twoQueue[Key, Value]) hit(e *entry[Key, Value]) {
+	if e.list == &p.in {
+		p.in.remove(e)
+		p.main.pushFront(e)
+		return
+	}
+	p.main.moveToFront(e)
+}
+
+func (p *twoQueue[Key, Value]) victim() *entry[Key, Value] {
+	if p.in.size > 0 && (p.in.size > p.inSize || p.main.size == 0) {
+		return p.in.tail
+	}
+	return p.main.tail
+}
+
+func (p *twoQueue[Key, Value]) remove(e *entry[Key, Value], evicted bool) {
+	from := e.list
+	from.remove(e)
+	if !evicted || from != &p.in {
+		return
+	}
+
+	ghost := &entry[Key, Value]{key: e.key, cost: e.cost}
+	p.out.pushFront(ghost)
+	p.ghosts[e.key] = ghost
+	for p.out.size > p.outSize {
+		oldest := p.out.tail
+		p.out.remove(oldest)
+		delete(p.ghosts, oldest.key)
+	}
+}
+
+func (p *twoQueue[Key, Value]) walk(f func(e *entry[Key, Value]) bool) {
+	if p.main.walk(f) {
+		p.in.walk(f)
+	}
+}
+
+func (p *twoQueue[Key, Value]) clear() {
+	p.in.clear()
+	p.main.clear()
+	p.out.clear()
+	p.ghosts = make(map[Key]*entry[Key, Value])
+}
+
+//endregion
@@ -0,0 +1,280 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotMagic starts every snapshot, snapshotVersion follows it as uint16
+const (
+	snapshotMagic   = "SDLCACHE"
+	snapshotVersion = 1
+	// maxSnapshotField limits a key or a value read from a snapshot, a bigger length means the file is broken
+	maxSnapshotField = 1 << 30
+)
+
+// ErrSnapshotFormat describes an error when a file isn't a cache snapshot or is broken
+var ErrSnapshotFormat = errors.New("invalid cache snapshot")
+
+// ErrSnapshotVersion describes an error when a snapshot was written by an unsupported format version
+var ErrSnapshotVersion = errors.New("unsupported cache snapshot version")
+
+// SnapshotOptions configure WriteSnapshot and ReadSnapshot, use the same codecs for both
+type SnapshotOptions[Key comparable, Value any] struct {
+	// KeyCodec encodes keys, codec.NewKeyCodec by default
+	KeyCodec codec.Codec[Key]
+	// Codec encodes values, codec.JSON by default
+	Codec codec.Codec[Value]
+}
+
+// withDefaults fills codecs that aren't set
+func (o SnapshotOptions[Key, Value]) withDefaults() SnapshotOptions[Key, Value] {
+	if o.KeyCodec == nil {
+		o.KeyCodec = codec.NewKeyCodec[Key]()
+	}
+	if o.Codec == nil {
+		o.Codec = codec.NewJSON[Value]()
+	}
+	return o
+}
+
+// snapshotRecord is an entry copied under the lock, it's encoded after the lock is released
+type snapshotRecord[Key comparable, Value any] struct {
+	key       Key
+	value     Value
+	expiresAt time.Time
+}
+
+// WriteSnapshot writes entries that aren't expired to w from the least to the most valuable for the policy.
+//
+// The format is snapshotMagic, the version as uint16 and records of
+// uvarint key length, key, uvarint value length, value and varint expiration in unix nanoseconds (0 for never)
+func (c *Cache[Key, Value]) WriteSnapshot(w io.Writer, opts SnapshotOptions[Key, Value]) (int, error) {
+	opts = opts.withDefaults()
+
+	c.mu.RLock()
+	records := make([]snapshotRecord[Key, Value], 0, len(c.items))
+	now := time.Now()
+	c.policy.walk(func(e *entry[Key, Value]) bool {
+		if !e.expired(now) {
+			records = append(records, snapshotRecord[Key, Value]{key: e.key, value: e.value, expiresAt: e.expiresAt})
+		}
+		return true
+	})
+	c.mu.RUnlock()
+
+	buf := bufio.NewWriter(w)
+	header := binary.BigEndian.AppendUint16([]byte(snapshotMagic), snapshotVersion)
+	if _, err := buf.Write(header); err != nil {
+		return 0, err
+	}
+
+	var data []byte
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		key, err := opts.KeyCodec.Encode(record.key)
+		if err != nil {
+			return 0, fmt.Errorf("error encoding snapshot key '%v': %w", record.key, err)
+		}
+		value, err := opts.Codec.Encode(record.value)
+		if err != nil {
+			return 0, fmt.Errorf("error encoding snapshot value of key '%v': %w", record.key, err)
+		}
+
+		var expiresAt int64
+		if !record.expiresAt.IsZero() {
+			expiresAt = record.expiresAt.UnixNano()
+		}
+		data = binary.AppendUvarint(data[:0], uint64(len(key)))
+		data = append(data, key...)
+		data = binary.AppendUvarint(data, uint64(len(value)))
+		data = append(data, value...)
+		data = binary.AppendVarint(data, expiresAt)
+		if _, err = buf.Write(data); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), buf.Flush()
+}
+
+// ReadSnapshot saves entries written by WriteSnapshot, returns the amount of restored ones.
+//
+// Entries are saved in the written order, so the most valuable ones are the most recent.
+// If the snapshot is bigger than the capacity, the least valuable entries are evicted as usual.
+// Expired entries are skipped, the others keep their expiration time.
+// Values that cost more than Options.MaxCost are skipped too
+func (c *Cache[Key, Value]) ReadSnapshot(ctx context.Context, r io.Reader, opts SnapshotOptions[Key, Value]) (int, error) {
+	opts = opts.withDefaults()
+	buf := bufio.NewReader(r)
+
+	header := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(buf, header); err != nil {
+		return 0, fmt.Errorf("%w: error reading header: %w", ErrSnapshotFormat, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return 0, fmt.Errorf("%w: no header", ErrSnapshotFormat)
+	}
+	if version := binary.BigEndian.Uint16(header[len(snapshotMagic):]); version != snapshotVersion {
+		return 0, fmt.Errorf("%w: %d, expected %d", ErrSnapshotVersion, version, snapshotVersion)
+	}
+
+	restored := 0
+	for {
+		keyData, err := readSnapshotField(buf)
+		if errors.Is(err, io.EOF) {
+			return restored, nil
+		}
+		if err != nil {
+			return restored, err
+		}
+		valueData, err := readSnapshotField(buf)
+		if errors.Is(err, io.EOF) {
+			err = fmt.Errorf("%w: no value of the last key: %w", ErrSnapshotFormat, io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return restored, err
+		}
+		expiresAt, err := binary.ReadVarint(buf)
+		if err != nil {
+			return restored, fmt.Errorf("%w: error reading expiration: %w", ErrSnapshotFormat, unexpectedEOF(err))
+		}
+
+		var ttl time.Duration
+		if expiresAt != 0 {
+			if ttl = time.Until(time.Unix(0, expiresAt)); ttl <= 0 {
+				continue
+			}
+		}
+
+		key, err := opts.KeyCodec.Decode(keyData)
+		if err != nil {
+			return restored, fmt.Errorf("error decoding snapshot key: %w", err)
+		}
+		value, err := opts.Codec.Decode(valueData)
+		if err != nil {
+			return restored, fmt.Errorf("error decoding snapshot value of key '%v': %w", key, err)
+		}
+
+		err = c.SetWithTTL(ctx, key, value, ttl)
+		if errors.Is(err, ErrCostExceeded) {
+			continue
+		}
+		if err != nil {
+			return restored, err
+		}
+		restored++
+	}
+}
+
+// readSnapshotField reads a length-prefixed key or value, io.EOF only if there's nothing left at all
+func readSnapshotField(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading length: %w", ErrSnapshotFormat, unexpectedEOF(err))
+	}
+	if length > maxSnapshotField {
+		return nil, fmt.Errorf("%w: length %d is too big", ErrSnapshotFormat, length)
+	}
+
+	data := make([]byte, length)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("%w: error reading data: %w", ErrSnapshotFormat, unexpectedEOF(err))
+	}
+	return data, nil
+}
+
+// unexpectedEOF turns io.EOF in the middle of a record into io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// SaveSnapshot writes the snapshot to path atomically: into a temp file in the same directory that is renamed then
+func (c *Cache[Key, Value]) SaveSnapshot(ctx context.Context, path string, opts SnapshotOptions[Key, Value]) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating cache snapshot: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	saved, err := c.WriteSnapshot(tmp, opts)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing cache snapshot: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing cache snapshot: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("error writing cache snapshot: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error saving cache snapshot: %w", err)
+	}
+
+	logger.GetOrCreateLoggerFromCtx(ctx).Debug(ctx, "saved cache snapshot",
+		zap.String("path", path), zap.Int("entries", saved))
+	return nil
+}
+
+// LoadSnapshot restores the snapshot saved to path with SaveSnapshot, see ReadSnapshot.
+// Nothing is restored if there's no file, e.g. on the first start
+func (c *Cache[Key, Value]) LoadSnapshot(ctx context.Context, path string, opts SnapshotOptions[Key, Value]) (int, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error opening cache snapshot: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	restored, err := c.ReadSnapshot(ctx, file, opts)
+	if err != nil {
+		return restored, fmt.Errorf("error restoring cache snapshot '%s': %w", path, err)
+	}
+
+	logger.GetOrCreateLoggerFromCtx(ctx).Info(ctx, "restored cache snapshot",
+		zap.String("path", path), zap.Int("entries", restored))
+	return restored, nil
+}
+
+// RunSnapshots saves the snapshot to path every interval until ctx is done, then saves it the last time,
+// so a graceful shutdown that cancels ctx and waits for RunSnapshots leaves a fresh snapshot
+func (c *Cache[Key, Value]) RunSnapshots(ctx context.Context, path string, interval time.Duration, opts SnapshotOptions[Key, Value]) {
+	l := logger.GetOrCreateLoggerFromCtx(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.SaveSnapshot(context.WithoutCancel(ctx), path, opts); err != nil {
+				l.Error(ctx, "error saving cache snapshot on shutdown", zap.Error(err))
+			}
+			l.Info(ctx, "cache snapshots stopped")
+			return
+		case <-ticker.C:
+			if err := c.SaveSnapshot(ctx, path, opts); err != nil {
+				l.Error(ctx, "error saving cache snapshot", zap.Error(err))
+			}
+		}
+	}
+}
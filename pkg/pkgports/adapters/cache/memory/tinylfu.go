@@ -0,0 +1,171 @@
+package memory
+
+import "hash/maphash"
+
+// tinyLFU is W-TinyLFU: new entries go to an LRU window, entries leaving the window
+// go to the probation segment of a segmented LRU, entries used there are protected.
+//
+// When the cache is full the oldest window entry competes with the probation victim,
+// the one with the lower sketch frequency is evicted
+type tinyLFU[Key comparable, Value any] struct {
+	seed   maphash.Seed
+	sketch *sketch
+
+	capacity, windowSize, protectedSize int64
+
+	window, probation, protected list[Key, Value]
+}
+
+func newTinyLFU[Key comparable, Value any](capacity int64) *tinyLFU[Key, Value] {
+	windowSize := max(1, capacity/100)
+	return &tinyLFU[Key, Value]{
+		seed:          maphash.MakeSeed(),
+		sketch:        newSketch(capacity),
+		capacity:      capacity,
+		windowSize:    windowSize,
+		protectedSize: max(0, capacity-windowSize) * 8 / 10,
+	}
+}
+
+func (p *tinyLFU[Key, Value]) size() int64 {
+	return p.window.size + p.probation.size + p.protected.size
+}
+
+// rebalance moves entries from an overflowed window to probation while there's space
+func (p *tinyLFU[Key, Value]) rebalance() {
+	for p.window.size > p.windowSize && p.size() <= p.capacity {
+		e := p.window.tail
+		p.window.remove(e)
+		p.probation.pushFront(e)
+	}
+}
+
+func (p *tinyLFU[Key, Value]) add(e *entry[Key, Value]) {
+	e.hash = maphash.Comparable(p.seed, e.key)
+	p.sketch.increment(e.hash)
+	p.window.pushFront(e)
+	p.rebalance()
+}
+
+func (p *tinyLFU[Key, Value]) hit(e *entry[Key, Value]) {
+	p.sketch.increment(e.hash)
+
+	switch e.list {
+	case &p.probation:
+		p.probation.remove(e)
+		p.protected.pushFront(e)
+		for p.protected.size > p.protectedSize {
+			demoted := p.protected.tail
+			p.protected.remove(demoted)
+			p.probation.pushFront(demoted)
+		}
+	default:
+		e.list.moveToFront(e)
+	}
+}
+
+func (p *tinyLFU[Key, Value]) victim() *entry[Key, Value] {
+	mainVictim := p.probation.tail
+	if mainVictim == nil {
+		mainVictim = p.protected.tail
+	}
+
+	candidate := p.window.tail
+	switch {
+	case candidate == nil:
+		return mainVictim
+	case mainVictim == nil:
+		return candidate
+	case p.window.size <= p.windowSize:
+		return mainVictim
+	case p.sketch.estimate(candidate.hash) > p.sketch.estimate(mainVictim.hash):
+		// the candidate is admitted, rebalance moves it to probation after the eviction
+		return mainVictim
+	default:
+		return candidate
+	}
+}
+
+func (p *tinyLFU[Key, Value]) remove(e *entry[Key, Value], _ bool) {
+	e.list.remove(e)
+	p.rebalance()
+}
+
+func (p *tinyLFU[Key, Value]) walk(f func(e *entry[Key, Value]) bool) {
+	if p.protected.walk(f) && p.probation.walk(f) {
+		p.window.walk(f)
+	}
+}
+
+func (p *tinyLFU[Key, Value]) clear() {
+	p.window.clear()
+	p.probation.clear()
+	p.protected.clear()
+	p.sketch.clear()
+}
+
+// sketchDepth is the amount of count-min sketch rows
+const sketchDepth = 4
+
+// maxSketchWidth limits the sketch when the capacity is a big cost, e.g. bytes
+const maxSketchWidth = 1 << 20
+
+// sketch is a count-min sketch of key frequencies with counters up to 15,
+// all counters are halved after 10 * width increments so old popularity fades
+type sketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	increments int
+	resetAt    int
+}
+
+func newSketch(capacity int64) *sketch {
+	width := 16
+	for int64(width) < capacity && width < maxSketchWidth {
+		width <<= 1
+	}
+
+	s := &sketch{mask: uint64(width - 1), resetAt: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index of the counter of hash in row i, double hashing
+func (s *sketch) index(hash uint64, i int) uint64 {
+	return (hash + uint64(i)*(hash>>32|1)) & s.mask
+}
+
+func (s *sketch) increment(hash uint64) {
+	for i := range s.rows {
+		if counter := &s.rows[i][s.index(hash, i)]; *counter < 15 {
+			*counter++
+		}
+	}
+
+	s.increments++
+	if s.increments >= s.resetAt {
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] >>= 1
+			}
+		}
+		s.increments /= 2
+	}
+}
+
+func (s *sketch) estimate(hash uint64) uint8 {
+	result := uint8(15)
+	for i := range s.rows {
+		result = min(result, s.rows[i][s.index(hash, i)])
+	}
+	return result
+}
+
+func (s *sketch) clear() {
+	for i := range s.rows {
+		clear(s.rows[i])
+	}
+	s.increments = 0
+}
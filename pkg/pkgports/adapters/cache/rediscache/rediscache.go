@@ -0,0 +1,403 @@
+// Package rediscache is a pkgports.Cache in Redis, so replicas of a service share one cache
+//
+//	client, err := redis.New(ctx, cfg.Redis)
+//	orders := rediscache.New(client, rediscache.Options[string, models.Order]{Prefix: "orders", TTL: cfg.Redis.TTL()})
+//
+// Values are saved as "{<prefix>}:v:<key>", the keys of the namespace are also members of the sorted set "{<prefix>}:keys"
+// scored by expiration time, so GetKeys reads the set with ZSCAN instead of KEYS over the whole database.
+// The "{<prefix>}" hash tag puts all keys of a namespace into one Redis Cluster slot, so transactions and scripts
+// over a value and the key set work in a cluster.
+// Redis has no capacity, values leave the cache by TTL, Delete, Clear or the Redis maxmemory policy
+package rediscache
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/stats"
+	"github.com/go-redis/redis/v8"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPrefix is the namespace if Options.Prefix is empty
+const DefaultPrefix = "cache"
+
+// DefaultScanBatch is the ZSCAN count if Options.ScanBatch <= 0
+const DefaultScanBatch = 100
+
+// statsTimeout limits the size query of Stats, so a stalled Redis doesn't block metric scrapes
+const statsTimeout = time.Second
+
+var (
+	// setScript saves the value, scores its member by expiration time and removes
+	// at most ARGV[5] members that expired by ARGV[4], so the key set doesn't grow without GetKeys
+	//
+	// KEYS[1] - value key, KEYS[2] - key set,
+	// ARGV[1] - value, ARGV[2] - TTL in milliseconds, 0 means no expiration, ARGV[3] - member,
+	// ARGV[4] - expiration score, ARGV[5] - now in unix milliseconds, ARGV[6] - prune limit
+	setScript = redis.NewScript(`
+if tonumber(ARGV[2]) > 0 then
+    redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+else
+    redis.call('SET', KEYS[1], ARGV[1])
+end
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[3])
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[5], 'LIMIT', 0, ARGV[6])
+if #expired > 0 then
+    redis.call('ZREM', KEYS[2], unpack(expired))
+end
+return 1`)
+
+	// existsScript returns 1 for value keys that exist, 0 for the rest
+	//
+	// KEYS - value keys
+	existsScript = redis.NewScript(`
+local result = {}
+for i = 1, #KEYS do
+    result[i] = redis.call('EXISTS', KEYS[i])
+end
+return result`)
+
+	// pruneScript removes members of values that don't exist from the key set,
+	// it runs in Redis so a Set between the check and ZREM can't lose a key
+	//
+	// KEYS[1] - key set, KEYS[2:] - value keys, ARGV - members of the value keys
+	pruneScript = redis.NewScript(`
+for i = 2, #KEYS do
+    if redis.call('EXISTS', KEYS[i]) == 0 then
+        redis.call('ZREM', KEYS[1], ARGV[i - 1])
+    end
+end
+return 1`)
+)
+
+// Options configure Cache
+type Options[Key comparable, Value any] struct {
+	// Prefix is the namespace of keys, DefaultPrefix by default. Caches with one prefix share values
+	Prefix string
+	// TTL is the expiration of values saved with Set, 0 means they never expire, e.g. redis.Config.TTL()
+	TTL time.Duration
+	// Codec encodes values, codec.JSON by default
+	Codec codec.Codec[Value]
+	// KeyCodec encodes keys, string keys are saved as they are and other keys as JSON by default
+	KeyCodec codec.Codec[Key]
+	// ScanBatch is the ZSCAN count of GetKeys and Clear and the max amount of expired members
+	// that a write removes from the key set, DefaultScanBatch by default
+	ScanBatch int64
+}
+
+// Cache - implement pkgports.Cache in Redis
+//
+// Get and Peek are the same: Redis doesn't track uses. Members of values expired by TTL are removed from the key set
+// by the next writes in batches of Options.ScanBatch or by GetKeys, GetKeysAmount and Clear.
+// Expiration scores use the clock of the writer, GetKeys also checks that values exist
+type Cache[Key comparable, Value any] struct {
+	client    redis.Cmdable
+	ttl       time.Duration
+	codec     codec.Codec[Value]
+	keyCodec  codec.Codec[Key]
+	scanBatch int64
+
+	// valuePrefix is "{<prefix>}:v:", index is "{<prefix>}:keys"
+	valuePrefix string
+	index       string
+
+	stats stats.Counters
+	// size is the last size read by Stats
+	size atomic.Int64
+}
+
+// New creates a new instance of Cache on a client from redis.New
+func New[Key comparable, Value any](client redis.Cmdable, opts Options[Key, Value]) *Cache[Key, Value] {
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	if opts.Codec == nil {
+		opts.Codec = codec.NewJSON[Value]()
+	}
+	if opts.KeyCodec == nil {
+		opts.KeyCodec = codec.NewKeyCodec[Key]()
+	}
+	if opts.ScanBatch <= 0 {
+		opts.ScanBatch = DefaultScanBatch
+	}
+
+	return &Cache[Key, Value]{
+		client:      client,
+		ttl:         max(0, opts.TTL),
+		codec:       opts.Codec,
+		keyCodec:    opts.KeyCodec,
+		scanBatch:   opts.ScanBatch,
+		valuePrefix: "{" + opts.Prefix + "}:v:",
+		index:       "{" + opts.Prefix + "}:keys",
+	}
+}
+
+// Get - impl pkgports.Cache.Get
+func (c *Cache[Key, Value]) Get(ctx context.Context, key Key) (Value, bool, error) {
+	member, err := c.member(key)
+	if err != nil {
+		return *new(Value), false, err
+	}
+
+	start := time.Now()
+	data, err := c.client.Get(ctx, c.valuePrefix+member).Bytes()
+	c.stats.Load(time.Since(start))
+	if err == redis.Nil {
+		c.stats.Miss()
+		return *new(Value), false, nil
+	}
+	if err != nil {
+		return *new(Value), false, fmt.Errorf("error getting value from redis: %w", err)
+	}
+	c.stats.Hit()
+
+	value, err := c.codec.Decode(data)
+	if err != nil {
+		return *new(Value), false, fmt.Errorf("error decoding value of key '%v': %w", key, err)
+	}
+	return value, true, nil
+}
+
+// Set - impl pkgports.Cache.Set, the value expires after Options.TTL
+func (c *Cache[Key, Value]) Set(ctx context.Context, key Key, value Value) error {
+	return c.SetWithTTL(ctx, key, value, c.ttl)
+}
+
+// SetWithTTL - impl pkgports.Cache.SetWithTTL, the value and its key set member are written in one script
+func (c *Cache[Key, Value]) SetWithTTL(ctx context.Context, key Key, value Value, ttl time.Duration) error {
+	member, err := c.member(key)
+	if err != nil {
+		return err
+	}
+	data, err := c.codec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("error encoding value of key '%v': %w", key, err)
+	}
+
+	now := time.Now()
+	// milliseconds are rounded up, so a short TTL doesn't become no expiration
+	ttlMillis := (max(0, ttl) + time.Millisecond - 1).Milliseconds()
+	score := "+inf"
+	if ttlMillis > 0 {
+		score = strconv.FormatInt(now.UnixMilli()+ttlMillis, 10)
+	}
+
+	err = setScript.Run(ctx, c.client, []string{c.valuePrefix + member, c.index},
+		data, ttlMillis, member, score, now.UnixMilli(), c.scanBatch).Err()
+	if err != nil {
+		return fmt.Errorf("error saving value to redis: %w", err)
+	}
+	c.stats.Set()
+	return nil
+}
+
+// Peek - impl pkgports.Cache.Peek, same as Get
+func (c *Cache[Key, Value]) Peek(ctx context.Context, key Key) (Value, bool, error) {
+	return c.Get(ctx, key)
+}
+
+// Contains - impl pkgports.Cache.Contains
+func (c *Cache[Key, _]) Contains(ctx context.Context, key Key) (bool, error) {
+	member, err := c.member(key)
+	if err != nil {
+		return false, err
+	}
+
+	exists, err := c.client.Exists(ctx, c.valuePrefix+member).Result()
+	if err != nil {
+		return false, fmt.Errorf("error checking key in redis: %w", err)
+	}
+	return exists > 0, nil
+}
+
+// Delete - impl pkgports.Cache.Delete
+func (c *Cache[Key, _]) Delete(ctx context.Context, key Key) error {
+	member, err := c.member(key)
+	if err != nil {
+		return err
+	}
+
+	var deleted *redis.IntCmd
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(ctx, c.valuePrefix+member)
+		pipe.ZRem(ctx, c.index, member)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting value from redis: %w", err)
+	}
+	if deleted.Val() > 0 {
+		c.stats.Evict("deleted")
+	}
+	return nil
+}
+
+// Clear - impl pkgports.Cache.Clear, reads the key set and deletes keys of the namespace batch by batch.
+// Values saved while it runs may stay
+func (c *Cache[_, _]) Clear(ctx context.Context) error {
+	var all []string
+	err := c.scan(ctx, func(members []string) error {
+		all = append(all, members...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for members := range slices.Chunk(all, int(c.scanBatch)) {
+		valueKeys := c.valueKeys(members)
+
+		var deleted *redis.IntCmd
+		_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			deleted = pipe.Del(ctx, valueKeys...)
+			pipe.ZRem(ctx, c.index, toAny(members)...)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error clearing values in redis: %w", err)
+		}
+		for range deleted.Val() {
+			c.stats.Evict("deleted")
+		}
+	}
+	return nil
+}
+
+// GetKeys - impl pkgports.Cache.GetKeys, keys aren't ordered
+func (c *Cache[Key, _]) GetKeys(ctx context.Context) ([]Key, error) {
+	alive, err := c.aliveMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Key, 0, len(alive))
+	for _, member := range alive {
+		key, err := c.keyCodec.Decode([]byte(member))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding key '%s': %w", member, err)
+		}
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+// GetKeysAmount - impl pkgports.Cache.GetKeysAmount, it scans the key set like GetKeys
+func (c *Cache[_, _]) GetKeysAmount(ctx context.Context) (int, error) {
+	alive, err := c.aliveMembers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(alive), nil
+}
+
+// Stats - impl pkgports.CacheStatsReporter.Stats, counters are of this instance only.
+// Size is the amount of key set members that aren't expired by their score, best-effort:
+// if Redis doesn't answer within statsTimeout, the last known size is reported
+func (c *Cache[_, _]) Stats() pkgports.CacheStats {
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if size, err := c.client.ZCount(ctx, c.index, "("+now, "+inf").Result(); err == nil {
+		c.size.Store(size)
+	}
+	return c.stats.Snapshot(int(c.size.Load()))
+}
+
+// ResetStats - impl pkgports.CacheStatsReporter.ResetStats
+func (c *Cache[_, _]) ResetStats() {
+	c.stats.Reset()
+}
+
+// member returns the encoded key
+func (c *Cache[Key, _]) member(key Key) (string, error) {
+	data, err := c.keyCodec.Encode(key)
+	if err != nil {
+		return "", fmt.Errorf("error encoding key '%v': %w", key, err)
+	}
+	return string(data), nil
+}
+
+// scan calls f for batches of key set members until the set is read or f fails.
+// ZSCAN may return a member twice, f gets every member once
+func (c *Cache[_, _]) scan(ctx context.Context, f func(members []string) error) error {
+	seen := make(map[string]struct{})
+	var cursor uint64
+	for {
+		pairs, next, err := c.client.ZScan(ctx, c.index, cursor, "", c.scanBatch).Result()
+		if err != nil {
+			return fmt.Errorf("error scanning keys in redis: %w", err)
+		}
+
+		// ZSCAN returns members and their scores one after another
+		members := make([]string, 0, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			if _, ok := seen[pairs[i]]; !ok {
+				seen[pairs[i]] = struct{}{}
+				members = append(members, pairs[i])
+			}
+		}
+		if len(members) > 0 {
+			if err = f(members); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// aliveMembers returns key set members whose values exist.
+// Members of expired values are removed from the key set after the scan, so the scan doesn't skip members
+func (c *Cache[_, _]) aliveMembers(ctx context.Context) ([]string, error) {
+	var alive, stale []string
+	err := c.scan(ctx, func(members []string) error {
+		exists, err := existsScript.Run(ctx, c.client, c.valueKeys(members)).Int64Slice()
+		if err != nil {
+			return fmt.Errorf("error checking keys in redis: %w", err)
+		}
+		for i, member := range members {
+			if exists[i] == 1 {
+				alive = append(alive, member)
+			} else {
+				stale = append(stale, member)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for batch := range slices.Chunk(stale, int(c.scanBatch)) {
+		keys := append([]string{c.index}, c.valueKeys(batch)...)
+		if err = pruneScript.Run(ctx, c.client, keys, toAny(batch)...).Err(); err != nil {
+			return nil, fmt.Errorf("error pruning expired keys in redis: %w", err)
+		}
+	}
+	return alive, nil
+}
+
+// valueKeys returns value keys of members
+func (c *Cache[_, _]) valueKeys(members []string) []string {
+	result := make([]string, len(members))
+	for i, member := range members {
+		result[i] = c.valuePrefix + member
+	}
+	return result
+}
+
+func toAny(values []string) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
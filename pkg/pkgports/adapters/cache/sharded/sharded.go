@@ -0,0 +1,357 @@
+// Package sharded is a pkgports.Cache that spreads keys over independently locked LRU segments,
+// so concurrent handlers don't wait on one mutex
+//
+//	orders := sharded.NewCache[string, models.Order](sharded.Options{Shards: 32, Capacity: 100_000})
+//
+// By default the capacity is split between shards and every shard evicts its own least used key.
+// With GlobalLRU all shards share the capacity, and the evicted key is the least used one of a few sampled shards
+package sharded
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/memory"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/stats"
+	"go.uber.org/zap"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultShards is the amount of shards if Options.Shards <= 0
+const DefaultShards = 16
+
+// DefaultSamples is the amount of shards compared on a global eviction if Options.Samples <= 0
+const DefaultSamples = 4
+
+// Options configure Cache
+type Options struct {
+	// Shards is the amount of segments, rounded up to a power of 2, DefaultShards by default.
+	// Without GlobalLRU it's reduced until every shard gets at least 1 of Capacity and MaxCost
+	Shards int
+	// Capacity is the max amount of keys in all shards
+	Capacity int
+	// GlobalLRU makes shards share Capacity instead of splitting it:
+	// a shard with hot keys may grow while others are empty.
+	// On overflow the least used keys of Samples random shards are compared and the oldest one is evicted,
+	// so eviction is an approximate LRU over the whole cache
+	GlobalLRU bool
+	// Samples is the amount of shards compared on a global eviction, DefaultSamples by default
+	Samples int
+	// TTL is the expiration of values saved with Set, 0 means they never expire, see memory.Options.TTL
+	TTL time.Duration
+	// MaxCost is the max total cost of entries in all shards, see memory.Options.MaxCost.
+	// It's split between shards like Capacity, so one value may cost up to the budget of its shard
+	MaxCost int64
+	// Cost measures values when MaxCost is set, memory.DefaultCost by default
+	Cost memory.CostFunc
+}
+
+// Cache - implement pkgports.Cache with LRU memory.Cache segments
+//
+// A key always goes to the same shard by its hash, operations on different shards don't block each other
+type Cache[Key comparable, Value any] struct {
+	seed   maphash.Seed
+	shards []*memory.Cache[Key, Value]
+	mask   uint64
+
+	capacity  int
+	maxCost   int64
+	globalLRU bool
+	samples   int
+	// lengths are the last known amounts of keys in every shard, their sum is the global size
+	lengths []atomic.Int64
+	// costs are the last known total costs of every shard
+	costs []atomic.Int64
+}
+
+// NewCache creates a new instance of Cache
+func NewCache[Key comparable, Value any](opts Options) *Cache[Key, Value] {
+	if opts.Shards <= 0 {
+		opts.Shards = DefaultShards
+	}
+	if opts.Samples <= 0 {
+		opts.Samples = DefaultSamples
+	}
+	shardsAmount := 1 << bits.Len(uint(opts.Shards-1))
+
+	opts.MaxCost = max(0, opts.MaxCost)
+	if opts.MaxCost > 0 && opts.Capacity <= 0 {
+		opts.Capacity = math.MaxInt
+	}
+
+	// a shard with no capacity would keep nothing and one with no cost budget would be unbounded,
+	// so there are no more shards than Capacity and MaxCost when they're split
+	for !opts.GlobalLRU && shardsAmount > 1 &&
+		((opts.Capacity > 0 && opts.Capacity < shardsAmount) || (opts.MaxCost > 0 && opts.MaxCost < int64(shardsAmount))) {
+		shardsAmount >>= 1
+	}
+	opts.Samples = min(opts.Samples, shardsAmount)
+
+	c := &Cache[Key, Value]{
+		seed:      maphash.MakeSeed(),
+		shards:    make([]*memory.Cache[Key, Value], shardsAmount),
+		mask:      uint64(shardsAmount - 1),
+		capacity:  opts.Capacity,
+		maxCost:   opts.MaxCost,
+		globalLRU: opts.GlobalLRU,
+		samples:   opts.Samples,
+		lengths:   make([]atomic.Int64, shardsAmount),
+		costs:     make([]atomic.Int64, shardsAmount),
+	}
+
+	for i := range c.shards {
+		capacity, maxCost := opts.Capacity, opts.MaxCost
+		if !opts.GlobalLRU {
+			// the first shards get the remainder
+			capacity = opts.Capacity / shardsAmount
+			if i < opts.Capacity%shardsAmount {
+				capacity++
+			}
+			maxCost = opts.MaxCost / int64(shardsAmount)
+			if int64(i) < opts.MaxCost%int64(shardsAmount) {
+				maxCost++
+			}
+		}
+		// PolicyLRU is always supported
+		c.shards[i], _ = memory.New[Key, Value](memory.Options{
+			Capacity: capacity, Policy: memory.PolicyLRU, TTL: opts.TTL, MaxCost: maxCost, Cost: opts.Cost,
+		})
+	}
+	return c
+}
+
+// GetCapacity returns the max amount of keys in all shards, math.MaxInt if only MaxCost limits it
+func (c *Cache[_, _]) GetCapacity() int {
+	return c.capacity
+}
+
+// GetMaxCost returns the max total cost of entries in all shards, 0 if entries aren't weighted
+func (c *Cache[_, _]) GetMaxCost() int64 {
+	return c.maxCost
+}
+
+// ShardsAmount returns the amount of segments
+func (c *Cache[_, _]) ShardsAmount() int {
+	return len(c.shards)
+}
+
+func (c *Cache[Key, _]) shardIndex(key Key) int {
+	return int(maphash.Comparable(c.seed, key) & c.mask)
+}
+
+// Get - impl pkgports.Cache.Get
+func (c *Cache[Key, Value]) Get(ctx context.Context, key Key) (Value, bool, error) {
+	return c.shards[c.shardIndex(key)].Get(ctx, key)
+}
+
+// Set - impl pkgports.Cache.Set, the value expires after Options.TTL
+func (c *Cache[Key, Value]) Set(ctx context.Context, key Key, value Value) error {
+	index := c.shardIndex(key)
+	if err := c.shards[index].Set(ctx, key, value); err != nil {
+		return err
+	}
+	c.afterSet(ctx, index)
+	return nil
+}
+
+// SetWithTTL - impl pkgports.Cache.SetWithTTL
+func (c *Cache[Key, Value]) SetWithTTL(ctx context.Context, key Key, value Value, ttl time.Duration) error {
+	index := c.shardIndex(key)
+	if err := c.shards[index].SetWithTTL(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	c.afterSet(ctx, index)
+	return nil
+}
+
+// afterSet keeps the global capacity
+func (c *Cache[_, _]) afterSet(ctx context.Context, index int) {
+	if c.globalLRU {
+		c.refresh(index)
+		c.evictGlobal(ctx)
+	}
+}
+
+// Peek - impl pkgports.Cache.Peek
+func (c *Cache[Key, Value]) Peek(ctx context.Context, key Key) (Value, bool, error) {
+	return c.shards[c.shardIndex(key)].Peek(ctx, key)
+}
+
+// Contains - impl pkgports.Cache.Contains
+func (c *Cache[Key, _]) Contains(ctx context.Context, key Key) (bool, error) {
+	return c.shards[c.shardIndex(key)].Contains(ctx, key)
+}
+
+// Delete - impl pkgports.Cache.Delete
+func (c *Cache[Key, _]) Delete(ctx context.Context, key Key) error {
+	index := c.shardIndex(key)
+	if err := c.shards[index].Delete(ctx, key); err != nil {
+		return err
+	}
+	c.refresh(index)
+	return nil
+}
+
+// Clear - impl pkgports.Cache.Clear
+func (c *Cache[_, _]) Clear(ctx context.Context) error {
+	for i, shard := range c.shards {
+		if err := shard.Clear(ctx); err != nil {
+			return err
+		}
+		c.lengths[i].Store(0)
+		c.costs[i].Store(0)
+	}
+	return nil
+}
+
+// GetKeys - impl pkgports.Cache.GetKeys
+//
+// Keys are grouped by shard, every group is ordered from the most to the least used.
+// Shards are read one by one, so it's not an atomic snapshot of the whole cache
+func (c *Cache[Key, _]) GetKeys(ctx context.Context) ([]Key, error) {
+	var result []Key
+	for _, shard := range c.shards {
+		keys, err := shard.GetKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, keys...)
+	}
+	return result, nil
+}
+
+// GetKeysAmount - impl pkgports.Cache.GetKeysAmount
+func (c *Cache[_, _]) GetKeysAmount(ctx context.Context) (int, error) {
+	total := 0
+	for _, shard := range c.shards {
+		amount, err := shard.GetKeysAmount(ctx)
+		if err != nil {
+			return 0, err
+		}
+		total += amount
+	}
+	return total, nil
+}
+
+// OnEvict adds a hook to all shards, see memory.Cache.OnEvict
+func (c *Cache[Key, Value]) OnEvict(hook memory.EvictHook[Key, Value]) {
+	for _, shard := range c.shards {
+		shard.OnEvict(hook)
+	}
+}
+
+// Stats - impl pkgports.CacheStatsReporter.Stats, the sum of all shards
+func (c *Cache[_, _]) Stats() pkgports.CacheStats {
+	parts := make([]pkgports.CacheStats, len(c.shards))
+	for i, shard := range c.shards {
+		parts[i] = shard.Stats()
+	}
+	return stats.Merge(parts...)
+}
+
+// ResetStats - impl pkgports.CacheStatsReporter.ResetStats
+func (c *Cache[_, _]) ResetStats() {
+	for _, shard := range c.shards {
+		shard.ResetStats()
+	}
+}
+
+// RemoveExpired removes expired entries of all shards, returns their amount
+func (c *Cache[_, _]) RemoveExpired(ctx context.Context) int {
+	removed := 0
+	for i, shard := range c.shards {
+		removed += shard.RemoveExpired(ctx)
+		c.refresh(i)
+	}
+	return removed
+}
+
+// Run is the janitor: it removes expired entries of all shards every interval until ctx is done
+func (c *Cache[_, _]) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.GetOrCreateLoggerFromCtx(ctx).Info(ctx, "sharded cache janitor stopped")
+			return
+		case <-ticker.C:
+			c.RemoveExpired(ctx)
+		}
+	}
+}
+
+// refresh saves the current length and cost of the shard
+func (c *Cache[_, _]) refresh(index int) {
+	c.lengths[index].Store(int64(c.shards[index].Len()))
+	c.costs[index].Store(c.shards[index].Cost())
+}
+
+// size is the approximate global amount of keys, expired ones that aren't removed yet included
+func (c *Cache[_, _]) size() int {
+	total := int64(0)
+	for i := range c.lengths {
+		total += c.lengths[i].Load()
+	}
+	return int(total)
+}
+
+// cost is the approximate global cost of keys
+func (c *Cache[_, _]) cost() int64 {
+	total := int64(0)
+	for i := range c.costs {
+		total += c.costs[i].Load()
+	}
+	return total
+}
+
+// overflowed tells if the approximate global size or cost is over the limits
+func (c *Cache[_, _]) overflowed() bool {
+	return c.size() > c.capacity || (c.maxCost > 0 && c.cost() > c.maxCost)
+}
+
+// evictGlobal evicts the oldest of the least used keys of sampled shards until the cache fits its capacity
+func (c *Cache[Key, _]) evictGlobal(ctx context.Context) {
+	for c.overflowed() {
+		victim := c.sampleVictim()
+		if victim == -1 {
+			return
+		}
+
+		key, evicted := c.shards[victim].EvictLeastUsed()
+		c.refresh(victim)
+		if evicted {
+			logger.GetOrCreateLoggerFromCtx(ctx).Debug(ctx, "sharded cache overflow, erased a value",
+				zap.Any("key", key), zap.Int("shard", victim), zap.Int("capacity", c.capacity))
+		}
+	}
+}
+
+// sampleVictim returns the index of the shard with the oldest least used key
+// among Samples non-empty shards starting at a random one, -1 if all shards are empty
+func (c *Cache[_, _]) sampleVictim() int {
+	victim, oldest := -1, time.Time{}
+	start := rand.IntN(len(c.shards))
+
+	for i, sampled := 0, 0; i < len(c.shards) && sampled < c.samples; i++ {
+		index := (start + i) & int(c.mask)
+		_, usedAt, err := c.shards[index].LeastUsed()
+		if err != nil {
+			// empty shard, its length might be stale
+			c.lengths[index].Store(0)
+			c.costs[index].Store(0)
+			continue
+		}
+		sampled++
+		if victim == -1 || usedAt.Before(oldest) {
+			victim, oldest = index, usedAt
+		}
+	}
+	return victim
+}
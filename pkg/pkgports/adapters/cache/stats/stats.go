@@ -0,0 +1,116 @@
+// Package stats has atomic counters behind pkgports.CacheStatsReporter of cache adapters
+//
+//	type Cache struct {
+//	    stats stats.Counters
+//	}
+//
+//	func (c *Cache) Stats() pkgports.CacheStats { return c.stats.Snapshot(c.Len()) }
+//	func (c *Cache) ResetStats()               { c.stats.Reset() }
+package stats
+
+import (
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters are cache counters safe for concurrent use without locks, the zero value is ready
+type Counters struct {
+	hits, misses, sets atomic.Int64
+
+	// evictions maps a reason to *atomic.Int64
+	evictions sync.Map
+
+	loads, totalLoadLatency, maxLoadLatency atomic.Int64
+}
+
+// Hit counts a found value
+func (c *Counters) Hit() {
+	c.hits.Add(1)
+}
+
+// Miss counts a missing value
+func (c *Counters) Miss() {
+	c.misses.Add(1)
+}
+
+// Set counts a saved value
+func (c *Counters) Set() {
+	c.sets.Add(1)
+}
+
+// Evict counts a value that left the cache
+func (c *Counters) Evict(reason string) {
+	counter, ok := c.evictions.Load(reason)
+	if !ok {
+		counter, _ = c.evictions.LoadOrStore(reason, new(atomic.Int64))
+	}
+	counter.(*atomic.Int64).Add(1)
+}
+
+// Load counts a value loaded from the backing storage in latency
+func (c *Counters) Load(latency time.Duration) {
+	c.loads.Add(1)
+	c.totalLoadLatency.Add(int64(latency))
+	for {
+		current := c.maxLoadLatency.Load()
+		if int64(latency) <= current || c.maxLoadLatency.CompareAndSwap(current, int64(latency)) {
+			return
+		}
+	}
+}
+
+// Snapshot returns current counters with the given size.
+// Counters are read one by one, so they may be off by concurrent calls
+func (c *Counters) Snapshot(size int) pkgports.CacheStats {
+	result := pkgports.CacheStats{
+		Hits:             c.hits.Load(),
+		Misses:           c.misses.Load(),
+		Sets:             c.sets.Load(),
+		Evictions:        make(map[string]int64),
+		Size:             size,
+		Loads:            c.loads.Load(),
+		TotalLoadLatency: time.Duration(c.totalLoadLatency.Load()),
+		MaxLoadLatency:   time.Duration(c.maxLoadLatency.Load()),
+	}
+	c.evictions.Range(func(reason, counter any) bool {
+		if amount := counter.(*atomic.Int64).Load(); amount > 0 {
+			result.Evictions[reason.(string)] = amount
+		}
+		return true
+	})
+	return result
+}
+
+// Reset sets all counters to zero
+func (c *Counters) Reset() {
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.sets.Store(0)
+	c.evictions.Range(func(_, counter any) bool {
+		counter.(*atomic.Int64).Store(0)
+		return true
+	})
+	c.loads.Store(0)
+	c.totalLoadLatency.Store(0)
+	c.maxLoadLatency.Store(0)
+}
+
+// Merge sums snapshots of parts of one cache, e.g. shards, max latencies are the max of all
+func Merge(parts ...pkgports.CacheStats) pkgports.CacheStats {
+	result := pkgports.CacheStats{Evictions: make(map[string]int64)}
+	for _, part := range parts {
+		result.Hits += part.Hits
+		result.Misses += part.Misses
+		result.Sets += part.Sets
+		for reason, amount := range part.Evictions {
+			result.Evictions[reason] += amount
+		}
+		result.Size += part.Size
+		result.Loads += part.Loads
+		result.TotalLoadLatency += part.TotalLoadLatency
+		result.MaxLoadLatency = max(result.MaxLoadLatency, part.MaxLoadLatency)
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package tiered
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/memory"
+	"github.com/go-redis/redis/v8"
+)
+
+// GenericCache - implement genericports.GenericCachePort with a memory.Cache L1
+// in front of any genericports.GenericCachePort L2, e.g. RedisGenericCache
+//
+// L1 keeps copies of objects, callers may change returned objects
+type GenericCache[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	l1          *memory.Cache[I, T]
+	l2          genericports.GenericCachePort[I, T]
+	invalidator *invalidator[I]
+}
+
+// NewGeneric creates a new instance of GenericCache, see New
+func NewGeneric[I comparable, T genericports.ObjectWithIdentifier[I]](l2 genericports.GenericCachePort[I, T], client *redis.Client, opts Options[I]) (*GenericCache[I, T], error) {
+	l1, opts, err := newL1[I, T](opts)
+	if err != nil {
+		return nil, err
+	}
+	return &GenericCache[I, T]{
+		l1:          l1,
+		l2:          l2,
+		invalidator: newInvalidator(client, opts.Channel, opts.KeyCodec),
+	}, nil
+}
+
+// Run receives invalidations from other replicas until ctx is done, see Cache.Run
+func (c *GenericCache[I, T]) Run(ctx context.Context) {
+	c.invalidator.run(ctx, func(ctx context.Context, ids []I) {
+		for _, id := range ids {
+			_ = c.l1.Delete(ctx, id)
+		}
+	}, func(ctx context.Context) {
+		_ = c.l1.Clear(ctx)
+	})
+}
+
+// L1 returns the local cache, e.g. for its Stats
+func (c *GenericCache[I, T]) L1() *memory.Cache[I, T] {
+	return c.l1
+}
+
+// GetObjectByID - impl genericports.GenericCachePort.GetObjectByID, an L2 hit is copied to L1
+func (c *GenericCache[I, T]) GetObjectByID(ctx context.Context, id I) (*T, error) {
+	if object, found, _ := c.l1.Get(ctx, id); found {
+		return &object, nil
+	}
+
+	generation := c.invalidator.generation.Load()
+	object, err := c.l2.GetObjectByID(ctx, id)
+	if err != nil || object == nil {
+		return object, err
+	}
+	fillL1(ctx, c.l1, c.invalidator, generation, id, *object)
+	return object, nil
+}
+
+// SaveObject - impl genericports.GenericCachePort.SaveObject, the L1 copy is dropped
+func (c *GenericCache[I, T]) SaveObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	saved, err := c.l2.SaveObject(ctx, fullyReadyObject)
+	if err != nil {
+		return nil, err
+	}
+	c.dropL1(ctx, (*saved).GetUniqueIdentifier())
+	return saved, nil
+}
+
+// DeleteObject - impl genericports.GenericCachePort.DeleteObject
+func (c *GenericCache[I, T]) DeleteObject(ctx context.Context, id I) error {
+	if err := c.l2.DeleteObject(ctx, id); err != nil {
+		return err
+	}
+	c.dropL1(ctx, id)
+	return nil
+}
+
+// dropL1 drops the L1 copy and tells other replicas to drop theirs, see Cache.Set
+func (c *GenericCache[I, T]) dropL1(ctx context.Context, id I) {
+	c.invalidator.bump()
+	_ = c.l1.Delete(ctx, id)
+	c.invalidator.invalidate(ctx, id)
+}
@@ -0,0 +1,173 @@
+package tiered
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"math/rand/v2"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// reconnectDelay is the pause after a pub/sub error before the next receive reconnects
+	reconnectDelay = 100 * time.Millisecond
+	// healthCheckInterval is the time without messages after which the connection is pinged
+	healthCheckInterval = 30 * time.Second
+)
+
+// message is an invalidation published to the channel
+type message struct {
+	// Origin is the instance that published it, the instance ignores its own messages
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	Clear  bool     `json:"clear,omitempty"`
+}
+
+// invalidator publishes and receives invalidations of L1 copies
+type invalidator[Key comparable] struct {
+	client   *redis.Client
+	channel  string
+	origin   string
+	keyCodec codec.Codec[Key]
+
+	// generation changes on every local write, received invalidation and flush,
+	// a value read from L2 is copied to L1 only if it didn't change during the read
+	generation atomic.Uint64
+}
+
+func newInvalidator[Key comparable](client *redis.Client, channel string, keyCodec codec.Codec[Key]) *invalidator[Key] {
+	return &invalidator[Key]{
+		client:   client,
+		channel:  channel,
+		origin:   fmt.Sprintf("%016x", rand.Uint64()),
+		keyCodec: keyCodec,
+	}
+}
+
+// bump changes the generation. Local writes call it after L2 is written and before the L1 copy is dropped,
+// so a read of the old L2 value that started before doesn't copy it back to L1
+func (i *invalidator[Key]) bump() {
+	i.generation.Add(1)
+}
+
+// invalidate tells other instances to drop the key, an error is logged:
+// the value is already written and other instances drop their copy after the L1 TTL anyway
+func (i *invalidator[Key]) invalidate(ctx context.Context, key Key) {
+	data, err := i.keyCodec.Encode(key)
+	if err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error encoding invalidated key",
+			zap.Any("key", key), zap.Error(err))
+		return
+	}
+	i.publish(ctx, message{Keys: []string{string(data)}})
+}
+
+// invalidateAll tells other instances to flush L1
+func (i *invalidator[Key]) invalidateAll(ctx context.Context) {
+	i.publish(ctx, message{Clear: true})
+}
+
+func (i *invalidator[Key]) publish(ctx context.Context, msg message) {
+	msg.Origin = i.origin
+	data, err := json.Marshal(msg)
+	if err == nil {
+		err = i.client.Publish(ctx, i.channel, data).Err()
+	}
+	if err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error publishing cache invalidation",
+			zap.String("channel", i.channel), zap.Error(err))
+	}
+}
+
+// run receives invalidations until ctx is done. drop is called with invalidated keys,
+// flush on a clear and whenever the subscription is lost, because messages might have been missed
+func (i *invalidator[Key]) run(ctx context.Context, drop func(ctx context.Context, keys []Key), flush func(ctx context.Context)) {
+	log := logger.GetOrCreateLoggerFromCtx(ctx)
+	pubsub := i.client.Subscribe(ctx, i.channel)
+	// receive doesn't watch ctx, closing the subscription interrupts it
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+		}
+		if err := pubsub.Close(); err != nil {
+			log.Error(ctx, "error closing cache invalidation subscription", zap.Error(err))
+		}
+	}()
+
+	subscribed := false
+	for {
+		received, err := pubsub.ReceiveTimeout(ctx, healthCheckInterval)
+		if ctx.Err() != nil {
+			log.Info(ctx, "cache invalidation stopped")
+			return
+		}
+		if netErr := net.Error(nil); errors.As(err, &netErr) && netErr.Timeout() {
+			// a silently dropped connection fails the ping
+			err = pubsub.Ping(ctx)
+		}
+		if err != nil {
+			log.Error(ctx, "cache invalidation subscription failed, flushing L1", zap.Error(err))
+			i.bump()
+			flush(ctx)
+			select {
+			case <-ctx.Done():
+			case <-time.After(reconnectDelay):
+			}
+			continue
+		}
+
+		switch received := received.(type) {
+		case *redis.Subscription:
+			if subscribed {
+				// resubscribed after a reconnect
+				log.Info(ctx, "cache invalidation resubscribed, flushing L1", zap.String("channel", i.channel))
+				i.bump()
+				flush(ctx)
+			}
+			subscribed = true
+		case *redis.Message:
+			i.receive(ctx, received.Payload, drop, flush)
+		}
+	}
+}
+
+func (i *invalidator[Key]) receive(ctx context.Context, payload string, drop func(ctx context.Context, keys []Key), flush func(ctx context.Context)) {
+	var msg message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error decoding cache invalidation", zap.Error(err))
+		return
+	}
+	if msg.Origin == i.origin {
+		return
+	}
+
+	i.bump()
+	if msg.Clear {
+		flush(ctx)
+		return
+	}
+
+	keys := make([]Key, 0, len(msg.Keys))
+	for _, data := range msg.Keys {
+		key, err := i.keyCodec.Decode([]byte(data))
+		if err != nil {
+			// the stale copy can't be found, so nothing is kept
+			logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error decoding invalidated key, flushing L1",
+				zap.String("key", data), zap.Error(err))
+			flush(ctx)
+			return
+		}
+		keys = append(keys, key)
+	}
+	drop(ctx, keys)
+}
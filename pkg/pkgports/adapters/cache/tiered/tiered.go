@@ -0,0 +1,194 @@
+// Package tiered puts a small in-process L1 in front of a shared L2 (e.g. Redis),
+// so hot keys are read at memory speed and replicas still share one cache
+//
+//	l2 := rediscache.New(client, rediscache.Options[string, models.Order]{Prefix: "orders", TTL: time.Hour})
+//	orders, err := tiered.New(l2, client, tiered.Options[string]{
+//	    L1:      memory.Options{Capacity: 1_000, TTL: 10 * time.Second},
+//	    Channel: "orders:invalidate",
+//	})
+//	go orders.Run(ctx) // receives invalidations until ctx is done
+//
+// Writes and deletes go to L2 first, then the L1 copy is dropped, they're published to the Redis channel
+// and other replicas drop their L1 copy too. L1 is filled by reads only. The L1 TTL bounds staleness if a message is lost,
+// L1 is flushed whenever the subscription breaks
+package tiered
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/memory"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// DefaultL1TTL is the L1 TTL if Options.L1.TTL <= 0
+const DefaultL1TTL = 30 * time.Second
+
+// DefaultChannel is the invalidation channel if Options.Channel is empty
+const DefaultChannel = "cache:invalidate"
+
+// Options configure Cache and GenericCache
+type Options[Key comparable] struct {
+	// L1 configures the local cache, its TTL is DefaultL1TTL if it's not set.
+	// L2 hits are copied to L1 for the L1 TTL, keep it shorter than TTLs of values
+	L1 memory.Options
+	// Channel is the Redis pub/sub channel, DefaultChannel by default. Replicas of one cache must share it
+	Channel string
+	// KeyCodec encodes keys in invalidation messages, codec.NewKeyCodec by default
+	KeyCodec codec.Codec[Key]
+}
+
+// Cache - implement pkgports.Cache with a memory.Cache L1 in front of any pkgports.Cache L2
+type Cache[Key comparable, Value any] struct {
+	l1          *memory.Cache[Key, Value]
+	l2          pkgports.Cache[Key, Value]
+	invalidator *invalidator[Key]
+}
+
+// New creates a new instance of Cache, client publishes and receives invalidations.
+// Returns memory.ErrUnknownPolicy if opts.L1.Policy isn't supported
+func New[Key comparable, Value any](l2 pkgports.Cache[Key, Value], client *redis.Client, opts Options[Key]) (*Cache[Key, Value], error) {
+	l1, opts, err := newL1[Key, Value](opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache[Key, Value]{
+		l1:          l1,
+		l2:          l2,
+		invalidator: newInvalidator(client, opts.Channel, opts.KeyCodec),
+	}, nil
+}
+
+// newL1 fills default options and creates the L1
+func newL1[Key comparable, Value any](opts Options[Key]) (*memory.Cache[Key, Value], Options[Key], error) {
+	if opts.L1.TTL <= 0 {
+		opts.L1.TTL = DefaultL1TTL
+	}
+	if opts.Channel == "" {
+		opts.Channel = DefaultChannel
+	}
+	if opts.KeyCodec == nil {
+		opts.KeyCodec = codec.NewKeyCodec[Key]()
+	}
+
+	l1, err := memory.New[Key, Value](opts.L1)
+	return l1, opts, err
+}
+
+// fillL1 copies a value read from L2 to L1 if the generation loaded before the read didn't change:
+// a write or an invalidation during the read might be about this key, the value might be stale then.
+// The generation is checked again after the copy, a write between the check and the copy drops it
+func fillL1[Key comparable, Value any](ctx context.Context, l1 *memory.Cache[Key, Value], invalidator *invalidator[Key], generation uint64, key Key, value Value) {
+	if invalidator.generation.Load() != generation {
+		return
+	}
+	_ = l1.Set(ctx, key, value)
+	if invalidator.generation.Load() != generation {
+		_ = l1.Delete(ctx, key)
+	}
+}
+
+// Run receives invalidations from other replicas until ctx is done, without it L1 copies live until the L1 TTL
+func (c *Cache[Key, Value]) Run(ctx context.Context) {
+	c.invalidator.run(ctx, func(ctx context.Context, keys []Key) {
+		for _, key := range keys {
+			_ = c.l1.Delete(ctx, key)
+		}
+	}, func(ctx context.Context) {
+		_ = c.l1.Clear(ctx)
+	})
+}
+
+// L1 returns the local cache, e.g. for its Stats
+func (c *Cache[Key, Value]) L1() *memory.Cache[Key, Value] {
+	return c.l1
+}
+
+// Get - impl pkgports.Cache.Get, an L2 hit is copied to L1
+func (c *Cache[Key, Value]) Get(ctx context.Context, key Key) (Value, bool, error) {
+	if value, found, _ := c.l1.Get(ctx, key); found {
+		return value, true, nil
+	}
+
+	generation := c.invalidator.generation.Load()
+	value, found, err := c.l2.Get(ctx, key)
+	if err != nil || !found {
+		return value, found, err
+	}
+	fillL1(ctx, c.l1, c.invalidator, generation, key, value)
+	return value, true, nil
+}
+
+// Set - impl pkgports.Cache.Set, the value expires in L2 as configured there.
+// The L1 copy is dropped, the next Get copies the value from L2
+func (c *Cache[Key, Value]) Set(ctx context.Context, key Key, value Value) error {
+	if err := c.l2.Set(ctx, key, value); err != nil {
+		return err
+	}
+	c.dropL1(ctx, key)
+	return nil
+}
+
+// SetWithTTL - impl pkgports.Cache.SetWithTTL, the L1 copy is dropped like in Set
+func (c *Cache[Key, Value]) SetWithTTL(ctx context.Context, key Key, value Value, ttl time.Duration) error {
+	if err := c.l2.SetWithTTL(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	c.dropL1(ctx, key)
+	return nil
+}
+
+// Peek - impl pkgports.Cache.Peek, an L2 hit isn't copied to L1
+func (c *Cache[Key, Value]) Peek(ctx context.Context, key Key) (Value, bool, error) {
+	if value, found, _ := c.l1.Peek(ctx, key); found {
+		return value, true, nil
+	}
+	return c.l2.Peek(ctx, key)
+}
+
+// Contains - impl pkgports.Cache.Contains
+func (c *Cache[Key, _]) Contains(ctx context.Context, key Key) (bool, error) {
+	if found, _ := c.l1.Contains(ctx, key); found {
+		return true, nil
+	}
+	return c.l2.Contains(ctx, key)
+}
+
+// Delete - impl pkgports.Cache.Delete
+func (c *Cache[Key, _]) Delete(ctx context.Context, key Key) error {
+	if err := c.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	c.dropL1(ctx, key)
+	return nil
+}
+
+// dropL1 drops the L1 copy of a key written to L2 here and tells other replicas to drop theirs.
+// Writes don't put values into L1: two concurrent writes of one key could leave L1 and L2 with different ones
+func (c *Cache[Key, _]) dropL1(ctx context.Context, key Key) {
+	c.invalidator.bump()
+	_ = c.l1.Delete(ctx, key)
+	c.invalidator.invalidate(ctx, key)
+}
+
+// Clear - impl pkgports.Cache.Clear, other replicas flush L1
+func (c *Cache[_, _]) Clear(ctx context.Context) error {
+	if err := c.l2.Clear(ctx); err != nil {
+		return err
+	}
+	c.invalidator.bump()
+	_ = c.l1.Clear(ctx)
+	c.invalidator.invalidateAll(ctx)
+	return nil
+}
+
+// GetKeys - impl pkgports.Cache.GetKeys, keys of L2
+func (c *Cache[Key, _]) GetKeys(ctx context.Context) ([]Key, error) {
+	return c.l2.GetKeys(ctx)
+}
+
+// GetKeysAmount - impl pkgports.Cache.GetKeysAmount, keys of L2
+func (c *Cache[_, _]) GetKeysAmount(ctx context.Context) (int, error) {
+	return c.l2.GetKeysAmount(ctx)
+}
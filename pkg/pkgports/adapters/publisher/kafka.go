@@ -0,0 +1,63 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
+	pkgkafka "github.com/chempik1234/super-danis-library-golang/v2/pkg/kafka"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher is an implementation of pkgports.Publisher that uses Kafka
+//
+// Messages are readable by receiver.KafkaReceiver with the same codec, codec.JSON for NewKafkaReceiver
+type KafkaPublisher[Value any] struct {
+	writer pkgkafka.MessageWriter
+	topic  string
+	codec  codec.Codec[Value]
+}
+
+// NewKafkaPublisher creates a new *KafkaPublisher, returning it as a pkgports.Publisher
+//
+// writer mustn't have a Topic, create it with pkg/kafka.NewWriter.
+// Any pkgkafka.MessageWriter fits, e.g. the one passed to outbox.NewRelay
+//
+//	writer := kafka.NewWriter(ctx, config.Kafka)
+//	defer writer.Close()
+//
+//	ordersPublisher := publisher.NewKafkaPublisher[models.Order](writer, "orders", codec.NewJSON[models.Order]())
+func NewKafkaPublisher[Value any](writer pkgkafka.MessageWriter, topic string, codec codec.Codec[Value]) pkgports.Publisher[Value] {
+	return &KafkaPublisher[Value]{
+		writer: writer,
+		topic:  topic,
+		codec:  codec,
+	}
+}
+
+// Publish - impl pkgports.Publisher.Publish
+func (p *KafkaPublisher[Value]) Publish(ctx context.Context, key string, value Value, headers map[string]string) error {
+	return p.PublishBatch(ctx, pkgports.OutgoingMessage[Value]{Key: key, Value: value, Headers: headers})
+}
+
+// PublishBatch - impl pkgports.Publisher.PublishBatch
+func (p *KafkaPublisher[Value]) PublishBatch(ctx context.Context, messages ...pkgports.OutgoingMessage[Value]) error {
+	kafkaMessages := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		data, err := p.codec.Encode(message.Value)
+		if err != nil {
+			return fmt.Errorf("error encoding message with key '%s': %w", message.Key, err)
+		}
+		kafkaMessages[i] = kafka.Message{
+			Topic:   p.topic,
+			Key:     []byte(message.Key),
+			Value:   data,
+			Headers: pkgkafka.HeadersFromMap(message.Headers),
+		}
+	}
+
+	if err := p.writer.WriteMessages(ctx, kafkaMessages...); err != nil {
+		return fmt.Errorf("error while writing to kafka topic '%s': %w", p.topic, err)
+	}
+	return nil
+}
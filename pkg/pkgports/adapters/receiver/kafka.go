@@ -2,8 +2,8 @@ package receiver
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
 	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
 	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
 	"github.com/segmentio/kafka-go"
@@ -54,21 +54,33 @@ type KafkaReceiver[Value any] struct {
 	maxRetries   int
 	retryChan    chan *KafkaMessage[Value] // this is wrong, check comment in Consume method
 	fixedBackoff time.Duration
+	codec        codec.Codec[Value]
 }
 
 // To our disappointment, I didn't create generic interfaces for retry and backoff
 // Skill issue
 
-// NewKafkaReceiver creates a new *KafkaReceiver, returning it as a pkgports.Receiver
+// NewKafkaReceiver creates a new *KafkaReceiver that reads JSON messages, returning it as a pkgports.Receiver
 func NewKafkaReceiver[ValueType any](
 	reader *kafka.Reader,
 	maxRetries int, retriesCapacity int, fixedBackoff time.Duration,
+) pkgports.Receiver[ValueType, *KafkaMessage[ValueType]] {
+	return NewKafkaReceiverWithCodec[ValueType](reader, maxRetries, retriesCapacity, fixedBackoff, codec.NewJSON[ValueType]())
+}
+
+// NewKafkaReceiverWithCodec creates a new *KafkaReceiver that reads messages with given codec,
+// use the codec of publisher.KafkaPublisher
+func NewKafkaReceiverWithCodec[ValueType any](
+	reader *kafka.Reader,
+	maxRetries int, retriesCapacity int, fixedBackoff time.Duration,
+	codec codec.Codec[ValueType],
 ) pkgports.Receiver[ValueType, *KafkaMessage[ValueType]] {
 	return &KafkaReceiver[ValueType]{
 		reader:       reader,
 		maxRetries:   maxRetries,
 		retryChan:    make(chan *KafkaMessage[ValueType], retriesCapacity),
 		fixedBackoff: fixedBackoff,
+		codec:        codec,
 	}
 }
 
@@ -96,10 +108,9 @@ func (k *KafkaReceiver[Value]) Consume(ctx context.Context) (Value, *KafkaMessag
 	if err != nil {
 		return *new(Value), nil, fmt.Errorf("error while reading from kafka: %w", err)
 	}
-	var value Value
-	err = json.Unmarshal(msg.Value, &value)
+	value, err := k.codec.Decode(msg.Value)
 	if err != nil {
-		return *new(Value), nil, fmt.Errorf("error while unmarshalling message: %w", err)
+		return *new(Value), nil, fmt.Errorf("error while decoding message: %w", err)
 	}
 	return value, NewFreshMessage[Value](msg, value), nil
 }
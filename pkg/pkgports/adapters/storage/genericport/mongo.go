@@ -0,0 +1,431 @@
+package genericport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"reflect"
+	"strings"
+)
+
+// mongoIDField is the field every document is identified by
+const mongoIDField = "_id"
+
+// Audit fields of MongoGenericStorage documents, bson tags of genericports.Timestamped
+// and genericports.SoftDeletable objects must match them
+const (
+	MongoCreatedAtField = "created_at"
+	MongoUpdatedAtField = "updated_at"
+	MongoDeletedAtField = "deleted_at"
+)
+
+// MongoGenericStorage - implement genericports.GenericStoragePort, genericports.RestorableStoragePort,
+// genericports.PatchableStoragePort and genericports.PagedStoragePort
+//
+// V is stored as a bson document, the field returned by GetUniqueIdentifier must be tagged `bson:"_id"`.
+//
+// Runs in the transaction of mongodb.Transactor if ctx has one (driver reads the session from ctx)
+type MongoGenericStorage[K comparable, V genericports.ObjectWithIdentifier[K]] struct {
+	collection *mongo.Collection
+	clock      genericports.Clock
+
+	// bsonFields maps JSON fields of V to bson fields for PatchObject
+	bsonFields map[string]string
+	// bsonNames are bson fields of V, UpdateObject unsets the ones the object doesn't have
+	bsonNames []string
+
+	softDeletable bool
+	timestamped   bool
+}
+
+// NewMongoGenericStorage creates a new instance of MongoGenericStorage
+func NewMongoGenericStorage[K comparable, V genericports.ObjectWithIdentifier[K]](collection *mongo.Collection) *MongoGenericStorage[K, V] {
+	return &MongoGenericStorage[K, V]{
+		collection:    collection,
+		clock:         genericports.SystemClock,
+		bsonFields:    jsonToBSONFields[V](),
+		bsonNames:     bsonFieldNames[V](),
+		softDeletable: genericports.IsSoftDeletable[V](),
+		timestamped:   genericports.IsTimestamped[V](),
+	}
+}
+
+// SetClock replaces the clock used for audit timestamps, genericports.SystemClock by default
+func (s *MongoGenericStorage[K, V]) SetClock(clock genericports.Clock) {
+	s.clock = clock
+}
+
+// GetObjects - impl genericports.GenericStoragePort.GetObjects
+//
+// Soft-deleted objects are skipped unless ctx is genericports.WithDeleted
+func (s *MongoGenericStorage[K, V]) GetObjects(ctx context.Context) ([]*V, error) {
+	return s.findObjects(ctx, bson.M{})
+}
+
+// GetObjectsAfter - impl genericports.PagedStoragePort.GetObjectsAfter, IDs are ordered by mongo
+//
+// Soft-deleted objects are skipped unless ctx is genericports.WithDeleted
+func (s *MongoGenericStorage[K, V]) GetObjectsAfter(ctx context.Context, afterID *K, limit int) ([]*V, error) {
+	filter := bson.M{}
+	if afterID != nil {
+		filter[mongoIDField] = bson.M{"$gt": *afterID}
+	}
+	return s.findObjects(ctx, filter, options.Find().SetSort(bson.D{{Key: mongoIDField, Value: 1}}).SetLimit(int64(limit)))
+}
+
+// findObjects decodes all documents matching filter
+func (s *MongoGenericStorage[K, V]) findObjects(ctx context.Context, filter bson.M, opts ...options.Lister[options.FindOptions]) ([]*V, error) {
+	cursor, err := s.collection.Find(ctx, s.filter(ctx, filter), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error finding objects: %w", err)
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	result := make([]*V, 0)
+	for cursor.Next(ctx) {
+		var object V
+		if err = cursor.Decode(&object); err != nil {
+			return nil, fmt.Errorf("error decoding object: %w", err)
+		}
+		result = append(result, &object)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, fmt.Errorf("error reading objects: %w", err)
+	}
+	return result, nil
+}
+
+// GetObjectByID - impl genericports.GenericStoragePort.GetObjectByID
+//
+// Soft-deleted object is not found unless ctx is genericports.WithDeleted
+func (s *MongoGenericStorage[K, V]) GetObjectByID(ctx context.Context, id K) (*V, error) {
+	var object V
+	err := s.collection.FindOne(ctx, s.filter(ctx, bson.M{mongoIDField: id})).Decode(&object)
+	if err != nil {
+		return nil, wrapMongoError(err, id)
+	}
+	return &object, nil
+}
+
+// CreateObject - impl genericports.GenericStoragePort.CreateObject
+//
+// Audit fields are kept as given if ctx is genericports.WithAuditPreserved
+func (s *MongoGenericStorage[K, V]) CreateObject(ctx context.Context, fullyReadyObject *V) (*V, error) {
+	object := *fullyReadyObject
+	if !genericports.IsAuditPreserved(ctx) {
+		genericports.StampCreated(&object, s.clock.Now())
+	}
+
+	_, err := s.collection.InsertOne(ctx, &object)
+	if err != nil {
+		return nil, wrapMongoError(err, object.GetUniqueIdentifier())
+	}
+	return &object, nil
+}
+
+// UpdateObject - impl genericports.GenericStoragePort.UpdateObject
+//
+// Creation and deletion time are kept as stored: the object is written with one $set/$unset
+// that doesn't touch them, so a concurrent delete or restore isn't overwritten
+func (s *MongoGenericStorage[K, V]) UpdateObject(ctx context.Context, fullyReadyObject *V) (*V, error) {
+	object := *fullyReadyObject
+	id := object.GetUniqueIdentifier()
+	filter := s.filter(ctx, bson.M{mongoIDField: id})
+
+	if !s.timestamped && !s.softDeletable {
+		result, err := s.collection.ReplaceOne(ctx, filter, &object)
+		if err != nil {
+			return nil, wrapMongoError(err, id)
+		}
+		if result.MatchedCount == 0 {
+			return nil, fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+		}
+		return &object, nil
+	}
+
+	genericports.StampUpdated(&object, s.clock.Now())
+
+	data, err := bson.Marshal(&object)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling object: %w", err)
+	}
+	var doc bson.M
+	if err = bson.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error unmarshalling object: %w", err)
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	for name, value := range doc {
+		if !s.isAuditField(name) && name != mongoIDField {
+			set[name] = value
+		}
+	}
+	// fields omitted by the encoder are removed, as ReplaceOne would do
+	for _, name := range s.bsonNames {
+		if _, present := doc[name]; !present && !s.isAuditField(name) && name != mongoIDField {
+			unset[name] = ""
+		}
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var result V
+	err = s.collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&result)
+	if err != nil {
+		return nil, wrapMongoError(err, id)
+	}
+	return &result, nil
+}
+
+// DeleteObject - impl genericports.GenericStoragePort.DeleteObject
+//
+// Sets deleted_at instead of deleting if *V is genericports.SoftDeletable, unless ctx is genericports.WithHardDelete
+func (s *MongoGenericStorage[K, V]) DeleteObject(ctx context.Context, id K) error {
+	if !s.softDeletable || genericports.IsHardDelete(ctx) {
+		result, err := s.collection.DeleteOne(ctx, bson.M{mongoIDField: id})
+		if err != nil {
+			return wrapMongoError(err, id)
+		}
+		if result.DeletedCount == 0 {
+			return fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+		}
+		return nil
+	}
+
+	now := s.clock.Now()
+	set := bson.M{MongoDeletedAtField: now}
+	if s.timestamped {
+		set[MongoUpdatedAtField] = now
+	}
+
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{mongoIDField: id, MongoDeletedAtField: nil},
+		bson.M{"$set": set})
+	if err != nil {
+		return wrapMongoError(err, id)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+	return nil
+}
+
+// RestoreObject - impl genericports.RestorableStoragePort.RestoreObject
+func (s *MongoGenericStorage[K, V]) RestoreObject(ctx context.Context, id K) (*V, error) {
+	if !s.softDeletable {
+		return nil, genericports.ErrNotSoftDeletable
+	}
+
+	set := bson.M{MongoDeletedAtField: nil}
+	if s.timestamped {
+		set[MongoUpdatedAtField] = s.clock.Now()
+	}
+
+	var object V
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{mongoIDField: id, MongoDeletedAtField: bson.M{"$ne": nil}},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&object)
+	if err != nil {
+		return nil, wrapMongoError(err, id)
+	}
+	return &object, nil
+}
+
+// mongoPatchAttempts limits the reads of PatchObject when the object is modified concurrently
+const mongoPatchAttempts = 5
+
+// PatchObject - impl genericports.PatchableStoragePort.PatchObject
+//
+// The patch is validated against the current object, then translated to $set/$unset of patched fields only.
+// Nested objects are written as a whole.
+//
+// The update is a compare-and-set: it matches only if the patched fields still have the values that were read,
+// otherwise the object is read and patched again. ErrConflict if it keeps changing for mongoPatchAttempts reads
+func (s *MongoGenericStorage[K, V]) PatchObject(ctx context.Context, id K, patch genericports.Patch) (*V, error) {
+	for range mongoPatchAttempts {
+		current, err := s.collection.FindOne(ctx, s.filter(ctx, bson.M{mongoIDField: id})).Raw()
+		if err != nil {
+			return nil, wrapMongoError(err, id)
+		}
+
+		update, fields, err := s.patchUpdate(id, current, patch)
+		if err != nil {
+			return nil, err
+		}
+
+		// patched fields must be unchanged since the read, raw values keep the order of embedded documents
+		filter := s.filter(ctx, bson.M{mongoIDField: id})
+		for _, name := range fields {
+			if value, lookupErr := current.LookupErr(name); lookupErr == nil {
+				filter[name] = value
+			} else {
+				filter[name] = bson.M{"$exists": false}
+			}
+		}
+
+		var result V
+		err = s.collection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&result)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, wrapMongoError(err, id)
+		}
+		return &result, nil
+	}
+	return nil, fmt.Errorf("%w: id '%v' is modified concurrently", genericports.ErrConflict, id)
+}
+
+// patchUpdate applies patch to the current document, returns the update and bson names of patched fields
+func (s *MongoGenericStorage[K, V]) patchUpdate(id K, current bson.Raw, patch genericports.Patch) (bson.M, []string, error) {
+	var object V
+	if err := bson.Unmarshal(current, &object); err != nil {
+		return nil, nil, fmt.Errorf("error decoding object: %w", err)
+	}
+
+	patched, err := genericports.ApplyPatch(&object, patch)
+	if err != nil {
+		return nil, nil, err
+	}
+	if (*patched).GetUniqueIdentifier() != id {
+		return nil, nil, fmt.Errorf("%w: id can't be patched", genericports.ErrInvalidPatch)
+	}
+	genericports.StampUpdated(patched, s.clock.Now())
+
+	data, err := bson.Marshal(patched)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error marshalling patched object: %w", err)
+	}
+	var doc bson.M
+	if err = bson.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling patched object: %w", err)
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	fields := make([]string, 0, len(patch.Fields()))
+	for _, field := range patch.Fields() {
+		name, ok := s.bsonFields[field]
+		if !ok || name == mongoIDField || s.isAuditField(name) {
+			return nil, nil, fmt.Errorf("%w: field '%s' can't be patched", genericports.ErrInvalidPatch, field)
+		}
+		fields = append(fields, name)
+		if value, present := doc[name]; present && value != nil {
+			set[name] = value
+		} else {
+			unset[name] = ""
+		}
+	}
+	if s.timestamped {
+		set[MongoUpdatedAtField] = doc[MongoUpdatedAtField]
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
+		return nil, nil, fmt.Errorf("%w: nothing to patch", genericports.ErrInvalidPatch)
+	}
+	return update, fields, nil
+}
+
+// filter adds "not deleted" condition unless ctx is genericports.WithDeleted
+func (s *MongoGenericStorage[K, V]) filter(ctx context.Context, filter bson.M) bson.M {
+	if s.softDeletable && !genericports.IsWithDeleted(ctx) {
+		filter[MongoDeletedAtField] = nil
+	}
+	return filter
+}
+
+// isAuditField reports whether UpdateObject must keep the stored value of the bson field
+func (s *MongoGenericStorage[K, V]) isAuditField(name string) bool {
+	return (s.timestamped && name == MongoCreatedAtField) || (s.softDeletable && name == MongoDeletedAtField)
+}
+
+// jsonToBSONFields maps JSON names of top-level fields of struct V to their bson names
+func jsonToBSONFields[V any]() map[string]string {
+	result := make(map[string]string)
+
+	t := reflect.TypeFor[V]()
+	if t.Kind() != reflect.Struct {
+		return result
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+
+		bsonName, _, _ := strings.Cut(field.Tag.Get("bson"), ",")
+		if bsonName == "-" {
+			continue
+		}
+		if bsonName == "" {
+			bsonName = strings.ToLower(field.Name)
+		}
+
+		result[jsonName] = bsonName
+	}
+	return result
+}
+
+// bsonFieldNames returns bson names of top-level fields of struct V
+func bsonFieldNames[V any]() []string {
+	t := reflect.TypeFor[V]()
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	result := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("bson"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// wrapMongoError converts mongo errors into genericports sentinel errors
+func wrapMongoError[K comparable](err error, id K) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: id '%v': %w", genericports.ErrConflict, id, err)
+	}
+	return fmt.Errorf("mongodb error on id '%v': %w", id, err)
+}
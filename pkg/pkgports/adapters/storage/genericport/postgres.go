@@ -0,0 +1,451 @@
+package genericport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/postgres"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"slices"
+	"strings"
+)
+
+// pgUniqueViolation is the postgres error code for unique_violation
+const pgUniqueViolation = "23505"
+
+// default audit columns of PostgresTable
+const (
+	defaultCreatedAtColumn = "created_at"
+	defaultUpdatedAtColumn = "updated_at"
+	defaultDeletedAtColumn = "deleted_at"
+)
+
+// ErrInvalidTable describes an error when PostgresTable is misconfigured
+var ErrInvalidTable = errors.New("invalid postgres table description")
+
+// PostgresTable describes how objects of type V are stored in a postgres table
+//
+//	table := genericport.PostgresTable[models.Order]{
+//	    Name:     "orders",
+//	    IDColumn: "order_uid",
+//	    Columns:  []string{"order_uid", "track_number", "created_at"},
+//	    Values: func(o *models.Order) []any {
+//	        return []any{o.OrderUID, o.TrackNumber, o.CreatedAt}
+//	    },
+//	    Scan: func(row pgx.Row) (*models.Order, error) {
+//	        var o models.Order
+//	        return &o, row.Scan(&o.OrderUID, &o.TrackNumber, &o.CreatedAt)
+//	    },
+//	}
+type PostgresTable[V any] struct {
+	// Name of the table
+	Name string
+	// IDColumn is the primary key column, it must be listed in Columns
+	IDColumn string
+	// Columns are all stored columns, Values and Scan use the same order
+	Columns []string
+	// Values returns values of Columns for given object
+	Values func(object *V) []any
+	// Scan reads a row that has all Columns selected
+	Scan func(row pgx.Row) (*V, error)
+
+	// CreatedAtColumn is used if *V is genericports.Timestamped, "created_at" by default.
+	// It's never overwritten by UpdateObject
+	CreatedAtColumn string
+	// UpdatedAtColumn is used if *V is genericports.Timestamped, "updated_at" by default
+	UpdatedAtColumn string
+	// DeletedAtColumn is used if *V is genericports.SoftDeletable, "deleted_at" by default
+	DeletedAtColumn string
+
+	// PatchFields maps JSON fields of V to Columns for PatchObject, fields named like their columns may be omitted
+	PatchFields map[string]string
+}
+
+// postgresQueries are the statements of PostgresGenericStorage, built once for both soft delete modes
+type postgresQueries struct {
+	selectAll   string
+	selectByID  string
+	selectFirst string
+	selectAfter string
+	update      string
+}
+
+// PostgresGenericStorage - implement genericports.GenericStoragePort, genericports.RestorableStoragePort,
+// genericports.PatchableStoragePort and genericports.PagedStoragePort
+//
+// Runs in the transaction of postgres.Transactor if ctx has one.
+//
+// ID type K must be encodable by pgx (e.g. string, int, uuid.UUID)
+type PostgresGenericStorage[K comparable, V genericports.ObjectWithIdentifier[K]] struct {
+	pool       *pgxpool.Pool
+	transactor *postgres.Transactor
+	table      PostgresTable[V]
+	clock      genericports.Clock
+
+	softDeletable bool
+	timestamped   bool
+
+	// setIndexes are indexes of Values that are written by UpdateObject
+	setIndexes []int
+	idIndex    int
+
+	notDeleted  postgresQueries
+	withDeleted postgresQueries
+
+	insertQuery     string
+	deleteQuery     string
+	hardDeleteQuery string
+	restoreQuery    string
+
+	// sanitized names for PatchObject queries
+	tableName   string
+	idColumn    string
+	columnsList string
+}
+
+// NewPostgresGenericStorage creates a new instance of PostgresGenericStorage, queries are built once here
+func NewPostgresGenericStorage[K comparable, V genericports.ObjectWithIdentifier[K]](pool *pgxpool.Pool, table PostgresTable[V]) (*PostgresGenericStorage[K, V], error) {
+	if table.Name == "" || len(table.Columns) == 0 || table.Values == nil || table.Scan == nil {
+		return nil, fmt.Errorf("%w: name, columns, values and scan are required", ErrInvalidTable)
+	}
+	if table.CreatedAtColumn == "" {
+		table.CreatedAtColumn = defaultCreatedAtColumn
+	}
+	if table.UpdatedAtColumn == "" {
+		table.UpdatedAtColumn = defaultUpdatedAtColumn
+	}
+	if table.DeletedAtColumn == "" {
+		table.DeletedAtColumn = defaultDeletedAtColumn
+	}
+
+	s := &PostgresGenericStorage[K, V]{
+		pool:          pool,
+		transactor:    postgres.NewTransactor(pool, pgx.TxOptions{}),
+		table:         table,
+		clock:         genericports.SystemClock,
+		softDeletable: genericports.IsSoftDeletable[V](),
+		timestamped:   genericports.IsTimestamped[V](),
+		idIndex:       slices.Index(table.Columns, table.IDColumn),
+	}
+
+	if s.idIndex == -1 {
+		return nil, fmt.Errorf("%w: id column '%s' is not listed in columns", ErrInvalidTable, table.IDColumn)
+	}
+	if s.timestamped && (!slices.Contains(table.Columns, table.CreatedAtColumn) ||
+		!slices.Contains(table.Columns, table.UpdatedAtColumn)) {
+		return nil, fmt.Errorf("%w: timestamped object requires columns '%s' and '%s'",
+			ErrInvalidTable, table.CreatedAtColumn, table.UpdatedAtColumn)
+	}
+	if s.softDeletable && !slices.Contains(table.Columns, table.DeletedAtColumn) {
+		return nil, fmt.Errorf("%w: soft-deletable object requires column '%s'",
+			ErrInvalidTable, table.DeletedAtColumn)
+	}
+
+	s.buildQueries()
+	return s, nil
+}
+
+// SetClock replaces the clock used for audit timestamps, genericports.SystemClock by default
+func (s *PostgresGenericStorage[K, V]) SetClock(clock genericports.Clock) {
+	s.clock = clock
+}
+
+func (s *PostgresGenericStorage[K, V]) buildQueries() {
+	tableName := pgx.Identifier{s.table.Name}.Sanitize()
+	idColumn := pgx.Identifier{s.table.IDColumn}.Sanitize()
+	deletedAtColumn := pgx.Identifier{s.table.DeletedAtColumn}.Sanitize()
+	updatedAtColumn := pgx.Identifier{s.table.UpdatedAtColumn}.Sanitize()
+
+	columns := make([]string, len(s.table.Columns))
+	placeholders := make([]string, len(s.table.Columns))
+	setters := make([]string, 0, len(s.table.Columns))
+	for i, column := range s.table.Columns {
+		columns[i] = pgx.Identifier{column}.Sanitize()
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+
+		if i == s.idIndex ||
+			(s.timestamped && column == s.table.CreatedAtColumn) ||
+			(s.softDeletable && column == s.table.DeletedAtColumn) {
+			continue
+		}
+		s.setIndexes = append(s.setIndexes, i)
+		setters = append(setters, fmt.Sprintf("%s = $%d", columns[i], len(setters)+1))
+	}
+	columnsList := strings.Join(columns, ", ")
+
+	s.tableName, s.idColumn, s.columnsList = tableName, idColumn, columnsList
+
+	build := func(condition string) postgresQueries {
+		return postgresQueries{
+			selectAll: fmt.Sprintf("SELECT %s FROM %s WHERE TRUE%s",
+				columnsList, tableName, condition),
+			selectByID: fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1%s",
+				columnsList, tableName, idColumn, condition),
+			selectFirst: fmt.Sprintf("SELECT %s FROM %s WHERE TRUE%s ORDER BY %s LIMIT $1",
+				columnsList, tableName, condition, idColumn),
+			selectAfter: fmt.Sprintf("SELECT %s FROM %s WHERE %s > $1%s ORDER BY %s LIMIT $2",
+				columnsList, tableName, idColumn, condition, idColumn),
+			update: fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d%s RETURNING %s",
+				tableName, strings.Join(setters, ", "), idColumn, len(setters)+1, condition, columnsList),
+		}
+	}
+
+	s.withDeleted = build("")
+	s.notDeleted = s.withDeleted
+	if s.softDeletable {
+		s.notDeleted = build(fmt.Sprintf(" AND %s IS NULL", deletedAtColumn))
+	}
+
+	s.insertQuery = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		tableName, columnsList, strings.Join(placeholders, ", "), columnsList)
+
+	s.hardDeleteQuery = fmt.Sprintf("DELETE FROM %s WHERE %s = $1", tableName, idColumn)
+	if !s.softDeletable {
+		s.deleteQuery = s.hardDeleteQuery
+		return
+	}
+
+	touch := ""
+	if s.timestamped {
+		touch = fmt.Sprintf(", %s = $2", updatedAtColumn)
+	}
+	s.deleteQuery = fmt.Sprintf("UPDATE %s SET %s = $2%s WHERE %s = $1 AND %s IS NULL",
+		tableName, deletedAtColumn, touch, idColumn, deletedAtColumn)
+	s.restoreQuery = fmt.Sprintf("UPDATE %s SET %s = NULL%s WHERE %s = $1 AND %s IS NOT NULL RETURNING %s",
+		tableName, deletedAtColumn, touch, idColumn, deletedAtColumn, columnsList)
+}
+
+// GetObjects - impl genericports.GenericStoragePort.GetObjects
+//
+// Soft-deleted objects are skipped unless ctx is genericports.WithDeleted
+func (s *PostgresGenericStorage[K, V]) GetObjects(ctx context.Context) ([]*V, error) {
+	return s.selectObjects(ctx, s.queries(ctx).selectAll)
+}
+
+// GetObjectsAfter - impl genericports.PagedStoragePort.GetObjectsAfter, IDs are ordered by postgres
+//
+// Soft-deleted objects are skipped unless ctx is genericports.WithDeleted
+func (s *PostgresGenericStorage[K, V]) GetObjectsAfter(ctx context.Context, afterID *K, limit int) ([]*V, error) {
+	if afterID == nil {
+		return s.selectObjects(ctx, s.queries(ctx).selectFirst, limit)
+	}
+	return s.selectObjects(ctx, s.queries(ctx).selectAfter, *afterID, limit)
+}
+
+// selectObjects scans all rows of query
+func (s *PostgresGenericStorage[K, V]) selectObjects(ctx context.Context, query string, args ...any) ([]*V, error) {
+	rows, err := s.querier(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting objects: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*V, 0)
+	for rows.Next() {
+		object, err := s.table.Scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning object: %w", err)
+		}
+		result = append(result, object)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading objects: %w", err)
+	}
+	return result, nil
+}
+
+// GetObjectByID - impl genericports.GenericStoragePort.GetObjectByID
+//
+// Soft-deleted object is not found unless ctx is genericports.WithDeleted
+func (s *PostgresGenericStorage[K, V]) GetObjectByID(ctx context.Context, id K) (*V, error) {
+	object, err := s.table.Scan(s.querier(ctx).QueryRow(ctx, s.queries(ctx).selectByID, id))
+	if err != nil {
+		return nil, wrapPostgresError(err, id)
+	}
+	return object, nil
+}
+
+// CreateObject - impl genericports.GenericStoragePort.CreateObject
+//
+// Audit fields are kept as given if ctx is genericports.WithAuditPreserved
+func (s *PostgresGenericStorage[K, V]) CreateObject(ctx context.Context, fullyReadyObject *V) (*V, error) {
+	object := *fullyReadyObject
+	if !genericports.IsAuditPreserved(ctx) {
+		genericports.StampCreated(&object, s.clock.Now())
+	}
+
+	createdObject, err := s.table.Scan(s.querier(ctx).QueryRow(ctx, s.insertQuery, s.table.Values(&object)...))
+	if err != nil {
+		return nil, wrapPostgresError(err, object.GetUniqueIdentifier())
+	}
+	return createdObject, nil
+}
+
+// UpdateObject - impl genericports.GenericStoragePort.UpdateObject
+//
+// Creation and deletion time are kept as stored
+func (s *PostgresGenericStorage[K, V]) UpdateObject(ctx context.Context, fullyReadyObject *V) (*V, error) {
+	object := *fullyReadyObject
+	genericports.StampUpdated(&object, s.clock.Now())
+
+	values := s.table.Values(&object)
+
+	args := make([]any, 0, len(s.setIndexes)+1)
+	for _, i := range s.setIndexes {
+		args = append(args, values[i])
+	}
+	args = append(args, values[s.idIndex])
+
+	updatedObject, err := s.table.Scan(s.querier(ctx).QueryRow(ctx, s.queries(ctx).update, args...))
+	if err != nil {
+		return nil, wrapPostgresError(err, object.GetUniqueIdentifier())
+	}
+	return updatedObject, nil
+}
+
+// DeleteObject - impl genericports.GenericStoragePort.DeleteObject
+//
+// Sets deleted_at instead of deleting if *V is genericports.SoftDeletable, unless ctx is genericports.WithHardDelete
+func (s *PostgresGenericStorage[K, V]) DeleteObject(ctx context.Context, id K) error {
+	query, args := s.deleteQuery, []any{id}
+	if genericports.IsHardDelete(ctx) {
+		query = s.hardDeleteQuery
+	} else if s.softDeletable {
+		args = append(args, s.clock.Now())
+	}
+
+	tag, err := s.querier(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return wrapPostgresError(err, id)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+	return nil
+}
+
+// RestoreObject - impl genericports.RestorableStoragePort.RestoreObject
+func (s *PostgresGenericStorage[K, V]) RestoreObject(ctx context.Context, id K) (*V, error) {
+	if !s.softDeletable {
+		return nil, genericports.ErrNotSoftDeletable
+	}
+
+	args := []any{id}
+	if s.timestamped {
+		args = append(args, s.clock.Now())
+	}
+
+	object, err := s.table.Scan(s.querier(ctx).QueryRow(ctx, s.restoreQuery, args...))
+	if err != nil {
+		return nil, wrapPostgresError(err, id)
+	}
+	return object, nil
+}
+
+// PatchObject - impl genericports.PatchableStoragePort.PatchObject
+//
+// The row is locked (SELECT ... FOR UPDATE), patched and validated in memory,
+// then only patched columns are written. Runs in its own transaction if ctx has none
+func (s *PostgresGenericStorage[K, V]) PatchObject(ctx context.Context, id K, patch genericports.Patch) (*V, error) {
+	indexes, err := s.patchIndexes(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	setters := make([]string, len(indexes))
+	for i, index := range indexes {
+		setters[i] = fmt.Sprintf("%s = $%d", pgx.Identifier{s.table.Columns[index]}.Sanitize(), i+1)
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d RETURNING %s",
+		s.tableName, strings.Join(setters, ", "), s.idColumn, len(setters)+1, s.columnsList)
+
+	var result *V
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.table.Scan(s.querier(ctx).QueryRow(ctx, s.queries(ctx).selectByID+" FOR UPDATE", id))
+		if err != nil {
+			return wrapPostgresError(err, id)
+		}
+
+		patched, err := genericports.ApplyPatch(current, patch)
+		if err != nil {
+			return err
+		}
+		if (*patched).GetUniqueIdentifier() != id {
+			return fmt.Errorf("%w: id can't be patched", genericports.ErrInvalidPatch)
+		}
+		genericports.StampUpdated(patched, s.clock.Now())
+
+		values := s.table.Values(patched)
+		args := make([]any, 0, len(indexes)+1)
+		for _, index := range indexes {
+			args = append(args, values[index])
+		}
+		args = append(args, id)
+
+		result, err = s.table.Scan(s.querier(ctx).QueryRow(ctx, query, args...))
+		if err != nil {
+			return wrapPostgresError(err, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// patchIndexes returns indexes of Columns that are written by PatchObject: patched ones and updated_at
+func (s *PostgresGenericStorage[K, V]) patchIndexes(patch genericports.Patch) ([]int, error) {
+	indexes := make([]int, 0, len(patch.Fields())+1)
+	for _, field := range patch.Fields() {
+		column, ok := s.table.PatchFields[field]
+		if !ok {
+			column = field
+		}
+		index := slices.Index(s.table.Columns, column)
+		if index == -1 || !slices.Contains(s.setIndexes, index) {
+			return nil, fmt.Errorf("%w: field '%s' can't be patched", genericports.ErrInvalidPatch, field)
+		}
+		if !slices.Contains(indexes, index) {
+			indexes = append(indexes, index)
+		}
+	}
+
+	if s.timestamped {
+		if index := slices.Index(s.table.Columns, s.table.UpdatedAtColumn); !slices.Contains(indexes, index) {
+			indexes = append(indexes, index)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("%w: nothing to patch", genericports.ErrInvalidPatch)
+	}
+	return indexes, nil
+}
+
+func (s *PostgresGenericStorage[K, V]) queries(ctx context.Context) postgresQueries {
+	if genericports.IsWithDeleted(ctx) {
+		return s.withDeleted
+	}
+	return s.notDeleted
+}
+
+func (s *PostgresGenericStorage[K, V]) querier(ctx context.Context) postgres.Querier {
+	return postgres.QuerierFromContext(ctx, s.pool)
+}
+
+// wrapPostgresError converts pgx errors into genericports sentinel errors
+func wrapPostgresError[K comparable](err error, id K) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: id '%v'", genericports.ErrNotFound, id)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return fmt.Errorf("%w: id '%v': %w", genericports.ErrConflict, id, err)
+	}
+
+	return fmt.Errorf("postgres error on id '%v': %w", id, err)
+}
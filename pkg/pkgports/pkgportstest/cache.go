@@ -0,0 +1,315 @@
+// Package pkgportstest is the conformance test kit for pkgports implementations
+//
+//	func TestMyCache(t *testing.T) {
+//	    pkgportstest.RunCacheSuite(t, pkgportstest.CacheSuite[string, int]{
+//	        NewCache: func(t *testing.T, capacity int) pkgports.Cache[string, int] {
+//	            return mycache.New[string, int](capacity)
+//	        },
+//	        NewKey:   func(n int) string { return fmt.Sprint("key", n) },
+//	        NewValue: func(n int) int { return n },
+//	        LRU:      true,
+//	    })
+//	}
+package pkgportstest
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// CacheSuite describes how to test a pkgports.Cache implementation
+type CacheSuite[K comparable, V any] struct {
+	// NewCache returns an empty cache of given capacity, called for every subtest
+	NewCache func(t *testing.T, capacity int) pkgports.Cache[K, V]
+	// NewKey returns a key, different n must give different keys
+	NewKey func(n int) K
+	// NewValue returns a value, different n must give different values
+	NewValue func(n int) V
+	// Equal compares values, reflect.DeepEqual if nil
+	Equal func(a, b V) bool
+
+	// Unbounded - cache has no capacity (e.g. redis), capacity checks are skipped
+	Unbounded bool
+	// LRU - eviction must remove the least recently used key
+	LRU bool
+	// Wait lets time pass for TTL checks, time.Sleep if nil. Set it for fake clocks (e.g. miniredis.FastForward)
+	Wait func(d time.Duration)
+}
+
+// RunCacheSuite checks the contracts of pkgports.Cache: set/get, misses, overwrites, capacity and eviction
+func RunCacheSuite[K comparable, V any](t *testing.T, suite CacheSuite[K, V]) {
+	t.Helper()
+	if suite.Equal == nil {
+		suite.Equal = func(a, b V) bool { return reflect.DeepEqual(a, b) }
+	}
+	if suite.Wait == nil {
+		suite.Wait = time.Sleep
+	}
+
+	t.Run("set then get", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 3)
+
+		if err := cache.Set(ctx, suite.NewKey(1), suite.NewValue(1)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		value, found, err := cache.Get(ctx, suite.NewKey(1))
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if !found || !suite.Equal(value, suite.NewValue(1)) {
+			t.Errorf("Expected %v to be found, got %v (found %v)", suite.NewValue(1), value, found)
+		}
+	})
+
+	t.Run("get missing", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 3)
+
+		value, found, err := cache.Get(ctx, suite.NewKey(1))
+		if err != nil {
+			t.Fatalf("Get of missing key must not fail, got %v", err)
+		}
+		if found {
+			t.Error("Expected missing key not to be found")
+		}
+		if !reflect.ValueOf(&value).Elem().IsZero() {
+			t.Errorf("Expected zero value on miss, got %v", value)
+		}
+	})
+
+	t.Run("overwrite keeps one key", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 3)
+
+		for _, n := range []int{1, 2} {
+			if err := cache.Set(ctx, suite.NewKey(1), suite.NewValue(n)); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+		}
+
+		value, found, err := cache.Get(ctx, suite.NewKey(1))
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if !found || !suite.Equal(value, suite.NewValue(2)) {
+			t.Errorf("Expected overwritten value %v, got %v", suite.NewValue(2), value)
+		}
+		if amount := keysAmount(t, ctx, cache); amount != 1 {
+			t.Errorf("Expected 1 key after overwrite, got %d", amount)
+		}
+		if keys := keys(t, ctx, cache); len(keys) != 1 || keys[0] != suite.NewKey(1) {
+			t.Errorf("Expected keys [%v], got %v", suite.NewKey(1), keys)
+		}
+	})
+
+	t.Run("peek and contains", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 3)
+		_ = cache.Set(ctx, suite.NewKey(1), suite.NewValue(1))
+
+		value, found, err := cache.Peek(ctx, suite.NewKey(1))
+		if err != nil || !found || !suite.Equal(value, suite.NewValue(1)) {
+			t.Errorf("Expected Peek to return %v, got %v (found %v, err %v)", suite.NewValue(1), value, found, err)
+		}
+		if _, found, err = cache.Peek(ctx, suite.NewKey(2)); err != nil || found {
+			t.Errorf("Expected Peek of missing key to miss, got found %v, err %v", found, err)
+		}
+
+		if contains, err := cache.Contains(ctx, suite.NewKey(1)); err != nil || !contains {
+			t.Errorf("Expected Contains to be true, got %v, %v", contains, err)
+		}
+		if contains, err := cache.Contains(ctx, suite.NewKey(2)); err != nil || contains {
+			t.Errorf("Expected Contains of missing key to be false, got %v, %v", contains, err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 3)
+		_ = cache.Set(ctx, suite.NewKey(1), suite.NewValue(1))
+		_ = cache.Set(ctx, suite.NewKey(2), suite.NewValue(2))
+
+		if err := cache.Delete(ctx, suite.NewKey(1)); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := cache.Delete(ctx, suite.NewKey(3)); err != nil {
+			t.Errorf("Delete of missing key must not fail, got %v", err)
+		}
+
+		if _, found, _ := cache.Get(ctx, suite.NewKey(1)); found {
+			t.Error("Expected deleted key to miss")
+		}
+		if keys := keys(t, ctx, cache); len(keys) != 1 || keys[0] != suite.NewKey(2) {
+			t.Errorf("Expected keys [%v] after delete, got %v", suite.NewKey(2), keys)
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 3)
+		for n := 1; n <= 3; n++ {
+			_ = cache.Set(ctx, suite.NewKey(n), suite.NewValue(n))
+		}
+
+		if err := cache.Clear(ctx); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		if amount := keysAmount(t, ctx, cache); amount != 0 {
+			t.Errorf("Expected no keys after clear, got %d", amount)
+		}
+		if _, found, _ := cache.Get(ctx, suite.NewKey(1)); found {
+			t.Error("Expected cleared key to miss")
+		}
+
+		// still usable
+		_ = cache.Set(ctx, suite.NewKey(1), suite.NewValue(1))
+		if _, found, _ := cache.Get(ctx, suite.NewKey(1)); !found {
+			t.Error("Expected key set after clear to be cached")
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 3)
+
+		if err := cache.SetWithTTL(ctx, suite.NewKey(1), suite.NewValue(1), time.Second); err != nil {
+			t.Fatalf("SetWithTTL failed: %v", err)
+		}
+		_ = cache.SetWithTTL(ctx, suite.NewKey(2), suite.NewValue(2), 0)
+
+		if _, found, _ := cache.Get(ctx, suite.NewKey(1)); !found {
+			t.Fatal("Expected key to be cached before TTL")
+		}
+
+		suite.Wait(1100 * time.Millisecond)
+
+		if _, found, _ := cache.Get(ctx, suite.NewKey(1)); found {
+			t.Error("Expected key to expire after TTL")
+		}
+		if contains, _ := cache.Contains(ctx, suite.NewKey(1)); contains {
+			t.Error("Expected expired key not to be contained")
+		}
+		if _, found, _ := cache.Get(ctx, suite.NewKey(2)); !found {
+			t.Error("Expected key without TTL to stay")
+		}
+		if keys := keys(t, ctx, cache); len(keys) != 1 || keys[0] != suite.NewKey(2) {
+			t.Errorf("Expected keys [%v] after expiration, got %v", suite.NewKey(2), keys)
+		}
+		if amount := keysAmount(t, ctx, cache); amount != 1 {
+			t.Errorf("Expected 1 key after expiration, got %d", amount)
+		}
+	})
+
+	if suite.Unbounded {
+		return
+	}
+
+	t.Run("capacity", func(t *testing.T) {
+		ctx := newContext(t)
+		capacity := 3
+		cache := suite.NewCache(t, capacity)
+
+		for n := 1; n <= capacity*3; n++ {
+			if err := cache.Set(ctx, suite.NewKey(n), suite.NewValue(n)); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if amount := keysAmount(t, ctx, cache); amount > capacity {
+				t.Fatalf("Expected at most %d keys, got %d", capacity, amount)
+			}
+		}
+		if amount, keys := keysAmount(t, ctx, cache), keys(t, ctx, cache); amount != len(keys) {
+			t.Errorf("GetKeysAmount %d doesn't match GetKeys length %d", amount, len(keys))
+		}
+
+		// the last set key is always kept
+		if _, found, _ := cache.Get(ctx, suite.NewKey(capacity*3)); !found {
+			t.Error("Expected the last set key to be cached")
+		}
+	})
+
+	t.Run("zero capacity", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 0)
+
+		if err := cache.Set(ctx, suite.NewKey(1), suite.NewValue(1)); err != nil {
+			t.Fatalf("Set must handle zero capacity, got %v", err)
+		}
+		if _, found, _ := cache.Get(ctx, suite.NewKey(1)); found {
+			t.Error("Expected nothing to be cached with zero capacity")
+		}
+	})
+
+	if !suite.LRU {
+		return
+	}
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 2)
+
+		_ = cache.Set(ctx, suite.NewKey(1), suite.NewValue(1))
+		_ = cache.Set(ctx, suite.NewKey(2), suite.NewValue(2))
+		// key 1 becomes recently used
+		if _, found, _ := cache.Get(ctx, suite.NewKey(1)); !found {
+			t.Fatal("Expected key 1 to be cached")
+		}
+		_ = cache.Set(ctx, suite.NewKey(3), suite.NewValue(3))
+
+		if _, found, _ := cache.Get(ctx, suite.NewKey(2)); found {
+			t.Error("Expected key 2 to be evicted as least recently used")
+		}
+		for _, n := range []int{1, 3} {
+			if _, found, _ := cache.Get(ctx, suite.NewKey(n)); !found {
+				t.Errorf("Expected key %d to be cached", n)
+			}
+		}
+	})
+
+	t.Run("peek doesn't touch recency", func(t *testing.T) {
+		ctx := newContext(t)
+		cache := suite.NewCache(t, 2)
+
+		_ = cache.Set(ctx, suite.NewKey(1), suite.NewValue(1))
+		_ = cache.Set(ctx, suite.NewKey(2), suite.NewValue(2))
+		_, _, _ = cache.Peek(ctx, suite.NewKey(1))
+		_, _ = cache.Contains(ctx, suite.NewKey(1))
+		_ = cache.Set(ctx, suite.NewKey(3), suite.NewValue(3))
+
+		if contains, _ := cache.Contains(ctx, suite.NewKey(1)); contains {
+			t.Error("Expected key 1 to be evicted, Peek and Contains mustn't count as use")
+		}
+	})
+}
+
+func keys[K comparable, V any](t *testing.T, ctx context.Context, cache pkgports.Cache[K, V]) []K {
+	t.Helper()
+	keys, err := cache.GetKeys(ctx)
+	if err != nil {
+		t.Fatalf("GetKeys failed: %v", err)
+	}
+	return keys
+}
+
+func keysAmount[K comparable, V any](t *testing.T, ctx context.Context, cache pkgports.Cache[K, V]) int {
+	t.Helper()
+	amount, err := cache.GetKeysAmount(ctx)
+	if err != nil {
+		t.Fatalf("GetKeysAmount failed: %v", err)
+	}
+	return amount
+}
+
+// newContext returns a context with logger, adapters of this library log through it
+func newContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, err := logger.New(context.Background())
+	if err != nil {
+		t.Fatalf("Error creating logger for test: %v", err)
+	}
+	return ctx
+}
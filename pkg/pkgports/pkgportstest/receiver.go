@@ -0,0 +1,98 @@
+package pkgportstest
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ReceiverSuite describes how to test a pkgports.Receiver implementation
+type ReceiverSuite[V any, M any] struct {
+	// NewReceiver returns a receiver on an empty source and a function that sends values into it.
+	// Retry backoff must be zero. Called for every subtest
+	NewReceiver func(t *testing.T) (receiver pkgports.Receiver[V, M], produce func(values ...V))
+	// NewValue returns a value, different n must give different values
+	NewValue func(n int) V
+	// Equal compares values, reflect.DeepEqual if nil
+	Equal func(a, b V) bool
+	// ConsumeTimeout - how long to wait for a message, also used to check that nothing is delivered
+	ConsumeTimeout time.Duration
+}
+
+// RunReceiverSuite checks the contracts of pkgports.Receiver: delivery order, ack, retry and drop on fail
+func RunReceiverSuite[V any, M any](t *testing.T, suite ReceiverSuite[V, M]) {
+	t.Helper()
+	if suite.Equal == nil {
+		suite.Equal = func(a, b V) bool { return reflect.DeepEqual(a, b) }
+	}
+	if suite.ConsumeTimeout == 0 {
+		suite.ConsumeTimeout = 5 * time.Second
+	}
+
+	consume := func(t *testing.T, receiver pkgports.Receiver[V, M], expected V) M {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(newContext(t), suite.ConsumeTimeout)
+		defer cancel()
+
+		value, message, err := receiver.Consume(ctx)
+		if err != nil {
+			t.Fatalf("Consume failed: %v", err)
+		}
+		if !suite.Equal(value, expected) {
+			t.Fatalf("Expected %v to be consumed, got %v", expected, value)
+		}
+		return message
+	}
+
+	expectNothing := func(t *testing.T, receiver pkgports.Receiver[V, M]) {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(newContext(t), suite.ConsumeTimeout/5)
+		defer cancel()
+
+		if value, _, err := receiver.Consume(ctx); err == nil {
+			t.Errorf("Expected nothing to be consumed, got %v", value)
+		}
+	}
+
+	t.Run("consume in order and ack", func(t *testing.T) {
+		receiver, produce := suite.NewReceiver(t)
+		produce(suite.NewValue(1), suite.NewValue(2), suite.NewValue(3))
+
+		for n := 1; n <= 3; n++ {
+			message := consume(t, receiver, suite.NewValue(n))
+			if err := receiver.OnSuccess(newContext(t), message); err != nil {
+				t.Fatalf("OnSuccess failed: %v", err)
+			}
+		}
+		expectNothing(t, receiver)
+	})
+
+	t.Run("fail with retry redelivers", func(t *testing.T) {
+		receiver, produce := suite.NewReceiver(t)
+		produce(suite.NewValue(1))
+
+		message := consume(t, receiver, suite.NewValue(1))
+		if err := receiver.OnFail(newContext(t), true, message); err != nil {
+			t.Fatalf("OnFail failed: %v", err)
+		}
+
+		message = consume(t, receiver, suite.NewValue(1))
+		if err := receiver.OnSuccess(newContext(t), message); err != nil {
+			t.Fatalf("OnSuccess failed: %v", err)
+		}
+		expectNothing(t, receiver)
+	})
+
+	t.Run("fail without retry drops", func(t *testing.T) {
+		receiver, produce := suite.NewReceiver(t)
+		produce(suite.NewValue(1))
+
+		message := consume(t, receiver, suite.NewValue(1))
+		if err := receiver.OnFail(newContext(t), false, message); err != nil {
+			t.Fatalf("OnFail failed: %v", err)
+		}
+		expectNothing(t, receiver)
+	})
+}
@@ -2,6 +2,7 @@ package pkgports
 
 import (
 	"context"
+	"time"
 )
 
 // Cache describes a cache that might be
@@ -11,14 +12,68 @@ type Cache[Key comparable, Value any] interface {
 	// Set saves a value (invalidates first value)
 	Set(ctx context.Context, key Key, value Value) error
 
+	// SetWithTTL saves a value that expires after ttl, ttl <= 0 means it never expires
+	SetWithTTL(ctx context.Context, key Key, value Value, ttl time.Duration) error
+
 	// Get returns value, ok, err (idempotent)
 	Get(ctx context.Context, key Key) (Value, bool, error)
 
-	// GetKeys returns a slice of all saved keys
-	GetKeys() []Key
+	// Peek returns value, ok, err like Get, but it isn't counted as a use (e.g. LRU order isn't touched)
+	Peek(ctx context.Context, key Key) (Value, bool, error)
+
+	// Contains tells if key is saved, it isn't counted as a use
+	Contains(ctx context.Context, key Key) (bool, error)
+
+	// Delete removes the value, deleting a missing key is not an error
+	Delete(ctx context.Context, key Key) error
+
+	// Clear removes all values
+	Clear(ctx context.Context) error
+
+	// GetKeys returns a snapshot of all saved keys
+	GetKeys(ctx context.Context) ([]Key, error)
 
 	// GetKeysAmount returns the amount of saved keys
-	GetKeysAmount() int
+	GetKeysAmount(ctx context.Context) (int, error)
+}
+
+// CacheStats is a snapshot of cache counters since creation or the last reset
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	Sets   int64
+	// Evictions are amounts of values that left the cache by reason, e.g. "capacity", "expired", "deleted"
+	Evictions map[string]int64
+	// Size is the current amount of keys
+	Size int
+	// Loads is the amount of values loaded from the backing storage, e.g. Redis round trips
+	Loads            int64
+	TotalLoadLatency time.Duration
+	MaxLoadLatency   time.Duration
+}
+
+// HitRatio returns hits / (hits + misses), 0 if there were no reads
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// AverageLoadLatency returns the mean load latency, 0 if there were no loads
+func (s CacheStats) AverageLoadLatency() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.TotalLoadLatency / time.Duration(s.Loads)
+}
+
+// CacheStatsReporter describes a cache that counts its operations, e.g. for a debug endpoint or metrics
+type CacheStatsReporter interface {
+	// Stats returns a snapshot of counters
+	Stats() CacheStats
+	// ResetStats sets all counters to zero, the size isn't a counter and stays
+	ResetStats()
 }
 
 // Receiver port describes a message queue consumer that gets orders for save, e.g. kafka
@@ -35,3 +90,20 @@ type Receiver[ValueType, MessageType any] interface {
 	// OnFail must be called on every unsuccessful message processing
 	OnFail(ctx context.Context, shouldRetry bool, givenMessage MessageType) error
 }
+
+// Publisher port describes a message queue producer, e.g. kafka. It's the counterpart of Receiver
+//
+// values are marshalled from generic ValueType, key decides the partition and the order of messages
+type Publisher[ValueType any] interface {
+	// Publish sends one message, returns when it's accepted by the queue
+	Publish(ctx context.Context, key string, value ValueType, headers map[string]string) error
+	// PublishBatch sends messages in one request, order is kept for messages with same key
+	PublishBatch(ctx context.Context, messages ...OutgoingMessage[ValueType]) error
+}
+
+// OutgoingMessage is a message for Publisher.PublishBatch
+type OutgoingMessage[ValueType any] struct {
+	Key     string
+	Value   ValueType
+	Headers map[string]string
+}
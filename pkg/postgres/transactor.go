@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type key string
+
+const keyForTx key = "postgres_tx"
+
+// Querier is the common part of *pgxpool.Pool and pgx.Tx, use it in storage adapters
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// TxFromContext returns the transaction started by Transactor, if any
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(keyForTx).(pgx.Tx)
+	return tx, ok
+}
+
+// QuerierFromContext returns the active transaction from ctx or the pool if there's none
+//
+//	func (r *OrdersRepo) Create(ctx context.Context, order *Order) error {
+//	    _, err := postgres.QuerierFromContext(ctx, r.pool).Exec(ctx, "INSERT ...", ...)
+//	    return err
+//	}
+func QuerierFromContext(ctx context.Context, pool *pgxpool.Pool) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return pool
+}
+
+// Transactor implements genericports.Transactor with pgx transactions
+//
+// Nested WithinTransaction calls create savepoints: inner rollback doesn't abort the outer transaction
+type Transactor struct {
+	pool    *pgxpool.Pool
+	options pgx.TxOptions
+}
+
+// NewTransactor creates a new Transactor, options are applied to top-level transactions only
+func NewTransactor(pool *pgxpool.Pool, options pgx.TxOptions) *Transactor {
+	return &Transactor{pool: pool, options: options}
+}
+
+// WithinTransaction - impl genericports.Transactor.WithinTransaction
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	var tx pgx.Tx
+	var err error
+
+	if outerTx, ok := TxFromContext(ctx); ok {
+		tx, err = outerTx.Begin(ctx) // savepoint
+	} else {
+		tx, err = t.pool.BeginTx(ctx, t.options)
+	}
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+
+	// rollback must happen even if ctx is already cancelled
+	rollbackCtx := context.WithoutCancel(ctx)
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(rollbackCtx)
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, keyForTx, tx)); err != nil {
+		if rollbackErr := tx.Rollback(rollbackCtx); rollbackErr != nil {
+			return fmt.Errorf("error rolling back transaction: %w (cause: %w)", rollbackErr, err)
+		}
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
@@ -22,6 +22,11 @@ type Config struct {
 	Pool PoolConfig `yaml:"pool" env-prefix:"POOL_"`
 }
 
+// TTL returns TTLSeconds as a duration, 0 means values never expire
+func (c Config) TTL() time.Duration {
+	return time.Duration(max(0, c.TTLSeconds)) * time.Second
+}
+
 // PoolConfig - pool for redis.Config
 type PoolConfig struct {
 	Size               int `yaml:"size" env:"SIZE" env-default:"3"`
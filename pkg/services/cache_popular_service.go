@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
 	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/lru"
+	"sync/atomic"
 )
 
 // CachePopularService - cache only popular objects
@@ -35,6 +37,18 @@ type CachePopularService[K comparable, V genericports.ObjectWithIdentifier[K]] s
 	cacheStorage      genericports.GenericCachePort[K, V]
 
 	minUses int
+
+	// crossed counts objects that reached minUses
+	crossed atomic.Int64
+}
+
+// PopularStats are counters of CachePopularService
+type PopularStats struct {
+	// Crossed is the amount of objects that reached minUses.
+	// An object whose uses count was evicted from the LRU is counted again when it comes back
+	Crossed int64
+	// Uses are counters of the uses LRU
+	Uses pkgports.CacheStats
 }
 
 // NewCachePopularService - create new CachePopularService
@@ -76,6 +90,10 @@ func (s *CachePopularService[K, V]) UpdatePopularity(ctx context.Context, object
 		return fmt.Errorf("error updating uses count: %w", err)
 	}
 
+	if count-uses < s.minUses && count >= s.minUses {
+		s.crossed.Add(1)
+	}
+
 	// step 2. Save
 	if count >= s.minUses {
 		err = s.save(ctx, object)
@@ -109,3 +127,14 @@ func (s *CachePopularService[K, V]) ForceSave(ctx context.Context, object V) err
 func (s *CachePopularService[K, V]) MinUsesBeforeCaching() int {
 	return s.minUses
 }
+
+// Stats returns how many objects crossed minUses and counters of the uses LRU
+func (s *CachePopularService[K, V]) Stats() PopularStats {
+	return PopularStats{Crossed: s.crossed.Load(), Uses: s.usesCountLRUCache.Stats()}
+}
+
+// ResetStats sets all counters to zero
+func (s *CachePopularService[K, V]) ResetStats() {
+	s.crossed.Store(0)
+	s.usesCountLRUCache.ResetStats()
+}
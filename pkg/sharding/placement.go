@@ -0,0 +1,87 @@
+package sharding
+
+import (
+	"fmt"
+	"github.com/cespare/xxhash/v2"
+	"github.com/dgryski/go-rendezvous"
+	"slices"
+)
+
+// Placement decides which shard owns a key
+type Placement interface {
+	// Locate returns the name of the shard owning given key
+	Locate(key string) string
+}
+
+// PlacementFactory builds a Placement for given shard names
+type PlacementFactory func(names []string) Placement
+
+//region rendezvous
+
+type rendezvousPlacement struct {
+	rendezvous *rendezvous.Rendezvous
+}
+
+// NewRendezvous is a PlacementFactory that uses rendezvous (highest random weight) hashing
+//
+// Adding or removing a shard moves only the keys of that shard
+func NewRendezvous(names []string) Placement {
+	return &rendezvousPlacement{rendezvous: rendezvous.New(names, xxhash.Sum64String)}
+}
+
+func (p *rendezvousPlacement) Locate(key string) string {
+	return p.rendezvous.Lookup(key)
+}
+
+//endregion
+
+//region consistent hashing
+
+// DefaultReplicas is the amount of virtual nodes per shard used by NewConsistentHash if replicas <= 0
+const DefaultReplicas = 128
+
+type consistentHashPlacement struct {
+	hashes []uint64
+	owners map[uint64]string
+}
+
+// NewConsistentHash returns a PlacementFactory that uses a consistent hashing ring
+// with given amount of virtual nodes per shard
+func NewConsistentHash(replicas int) PlacementFactory {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	return func(names []string) Placement {
+		p := &consistentHashPlacement{
+			hashes: make([]uint64, 0, len(names)*replicas),
+			owners: make(map[uint64]string, len(names)*replicas),
+		}
+		for _, name := range names {
+			for i := 0; i < replicas; i++ {
+				hash := xxhash.Sum64String(fmt.Sprintf("%s#%d", name, i))
+				if _, taken := p.owners[hash]; taken {
+					continue
+				}
+				p.owners[hash] = name
+				p.hashes = append(p.hashes, hash)
+			}
+		}
+		slices.Sort(p.hashes)
+		return p
+	}
+}
+
+func (p *consistentHashPlacement) Locate(key string) string {
+	if len(p.hashes) == 0 {
+		return ""
+	}
+
+	index, _ := slices.BinarySearch(p.hashes, xxhash.Sum64String(key))
+	if index == len(p.hashes) {
+		index = 0
+	}
+	return p.owners[p.hashes[index]]
+}
+
+//endregion
@@ -0,0 +1,403 @@
+// Package sharding splits a genericports.GenericStoragePort across multiple backends
+//
+//	router, err := sharding.NewRouter([]sharding.Shard[string, models.Order]{
+//	    {Name: "orders-1", Storage: ordersPostgres1},
+//	    {Name: "orders-2", Storage: ordersPostgres2},
+//	}, sharding.Options[string]{})
+//
+// Adding shards online:
+//
+//	err = router.StartMigration(append(shards, sharding.Shard[string, models.Order]{Name: "orders-3", Storage: ordersPostgres3}))
+//	moved, err := router.Rebalance(ctx) // or let UpdateObject move objects lazily
+//	err = router.FinishMigration()
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ErrInvalidShards describes an error when shards list is empty or has duplicate/empty names
+var ErrInvalidShards = errors.New("invalid shards")
+
+// ErrMigrationInProgress describes an error when a migration is started while another one isn't finished
+var ErrMigrationInProgress = errors.New("shard migration is already in progress")
+
+// ErrNoMigration describes an error when a migration is finished but was never started
+var ErrNoMigration = errors.New("no shard migration in progress")
+
+// Shard is a named underlying storage
+//
+// Name is used for placement, so keep it stable between restarts
+type Shard[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	Name    string
+	Storage genericports.GenericStoragePort[I, T]
+}
+
+// Options configure Router
+type Options[I comparable] struct {
+	// Placement builds placement from shard names, NewRendezvous if nil
+	Placement PlacementFactory
+	// Key converts ID to placement key, fmt.Sprint if nil
+	Key func(id I) string
+}
+
+// layout is a set of shards with its placement
+type layout struct {
+	names     []string
+	placement Placement
+}
+
+func (l *layout) contains(name string) bool {
+	return slices.Contains(l.names, name)
+}
+
+// Router - implement genericports.GenericStoragePort on top of N shards
+//
+// Every object is stored in exactly one shard chosen by its ID.
+// GetObjects asks all shards concurrently and concatenates results in shard names order.
+//
+// During a migration (StartMigration - FinishMigration) the router knows both old and new placement:
+// reads try the new owner first and fall back to the old one, writes go to the new owner,
+// UpdateObject moves an object if it's still in its old shard
+type Router[I comparable, T genericports.ObjectWithIdentifier[I]] struct {
+	mu       sync.RWMutex
+	shards   map[string]genericports.GenericStoragePort[I, T]
+	current  *layout
+	previous *layout
+
+	newPlacement PlacementFactory
+	key          func(id I) string
+}
+
+// NewRouter creates a new instance of Router
+//
+// Returns ErrInvalidShards if shards are empty or names aren't unique
+func NewRouter[I comparable, T genericports.ObjectWithIdentifier[I]](shards []Shard[I, T], opts Options[I]) (*Router[I, T], error) {
+	if opts.Placement == nil {
+		opts.Placement = NewRendezvous
+	}
+	if opts.Key == nil {
+		opts.Key = func(id I) string { return fmt.Sprint(id) }
+	}
+
+	r := &Router[I, T]{
+		shards:       make(map[string]genericports.GenericStoragePort[I, T], len(shards)),
+		newPlacement: opts.Placement,
+		key:          opts.Key,
+	}
+
+	current, err := r.addShards(shards)
+	if err != nil {
+		return nil, err
+	}
+	r.current = current
+	return r, nil
+}
+
+// addShards validates shards, registers their storages and returns their layout
+//
+// A shard that is already registered must have the same name and storage
+func (r *Router[I, T]) addShards(shards []Shard[I, T]) (*layout, error) {
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("%w: no shards given", ErrInvalidShards)
+	}
+
+	names := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		if shard.Name == "" || shard.Storage == nil {
+			return nil, fmt.Errorf("%w: shard must have name and storage", ErrInvalidShards)
+		}
+		if slices.Contains(names, shard.Name) {
+			return nil, fmt.Errorf("%w: duplicate shard name '%s'", ErrInvalidShards, shard.Name)
+		}
+		if registered, ok := r.shards[shard.Name]; ok && registered != shard.Storage {
+			return nil, fmt.Errorf("%w: shard '%s' is already registered with another storage", ErrInvalidShards, shard.Name)
+		}
+		names = append(names, shard.Name)
+	}
+
+	for _, shard := range shards {
+		r.shards[shard.Name] = shard.Storage
+	}
+	slices.Sort(names)
+	return &layout{names: names, placement: r.newPlacement(names)}, nil
+}
+
+// StartMigration switches the router to given shards while keeping the old placement for reads
+//
+// Returns ErrMigrationInProgress if the previous migration isn't finished
+func (r *Router[I, T]) StartMigration(shards []Shard[I, T]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.previous != nil {
+		return ErrMigrationInProgress
+	}
+
+	next, err := r.addShards(shards)
+	if err != nil {
+		return err
+	}
+	r.previous, r.current = r.current, next
+	return nil
+}
+
+// FinishMigration forgets the old placement and the shards that aren't used anymore
+//
+// Call it after Rebalance, objects left in old places become invisible
+func (r *Router[I, T]) FinishMigration() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.previous == nil {
+		return ErrNoMigration
+	}
+
+	for _, name := range r.previous.names {
+		if !r.current.contains(name) {
+			delete(r.shards, name)
+		}
+	}
+	r.previous = nil
+	return nil
+}
+
+// Migrating tells if a migration is in progress
+func (r *Router[I, T]) Migrating() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.previous != nil
+}
+
+// Locate returns the name of the shard that owns given ID in the current placement
+func (r *Router[I, T]) Locate(id I) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current.placement.Locate(r.key(id))
+}
+
+// Rebalance moves every object of the old placement that is stored in a wrong shard to its new owner
+//
+// An object is copied with its audit fields (genericports.WithAuditPreserved) and then removed from the old shard
+// (genericports.WithHardDelete), so it isn't moved atomically. If the new owner already has the object,
+// e.g. UpdateObject has moved it, the new owner's copy wins. Pass genericports.WithDeleted ctx to move soft-deleted
+// objects as well.
+//
+// Returns amount of moved objects, does nothing if no migration is in progress
+func (r *Router[I, T]) Rebalance(ctx context.Context) (int, error) {
+	// storages are resolved once, a concurrent FinishMigration forgets the old ones
+	r.mu.RLock()
+	previous, current := r.previous, r.current
+	shards := maps.Clone(r.shards)
+	r.mu.RUnlock()
+
+	if previous == nil {
+		return 0, nil
+	}
+
+	moved := 0
+	for _, name := range previous.names {
+		source := shards[name]
+		objects, err := source.GetObjects(ctx)
+		if err != nil {
+			return moved, fmt.Errorf("error listing shard '%s': %w", name, err)
+		}
+
+		for _, object := range objects {
+			id := (*object).GetUniqueIdentifier()
+			owner := current.placement.Locate(r.key(id))
+			if owner == name {
+				continue
+			}
+
+			if err = r.move(ctx, source, shards[owner], object); err != nil {
+				return moved, fmt.Errorf("error moving object '%v' from shard '%s' to '%s': %w", id, name, owner, err)
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+// move copies stored object to target with its audit fields and removes it from source
+//
+// An object that target already has wins: writes go to the current owner, so its copy is the newer one
+func (r *Router[I, T]) move(ctx context.Context, source, target genericports.GenericStoragePort[I, T], stored *T) error {
+	_, err := target.CreateObject(genericports.WithAuditPreserved(ctx), stored)
+	if err != nil && !errors.Is(err, genericports.ErrConflict) {
+		return err
+	}
+	err = source.DeleteObject(genericports.WithHardDelete(ctx), (*stored).GetUniqueIdentifier())
+	if err != nil && !errors.Is(err, genericports.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// owners returns the current and the old (nil if the same or no migration) owner of given ID
+func (r *Router[I, T]) owners(id I) (current, previous genericports.GenericStoragePort[I, T]) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key := r.key(id)
+	currentName := r.current.placement.Locate(key)
+	current = r.shards[currentName]
+
+	if r.previous != nil {
+		if previousName := r.previous.placement.Locate(key); previousName != currentName {
+			previous = r.shards[previousName]
+		}
+	}
+	return current, previous
+}
+
+// GetObjects - impl genericports.GenericStoragePort.GetObjects
+//
+// Asks all shards concurrently, during a migration an object found in both places is returned once
+func (r *Router[I, T]) GetObjects(ctx context.Context) ([]*T, error) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.shards))
+	for name := range r.shards {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	storages := make([]genericports.GenericStoragePort[I, T], len(names))
+	for i, name := range names {
+		storages[i] = r.shards[name]
+	}
+	current := r.current
+	migrating := r.previous != nil
+	r.mu.RUnlock()
+
+	results := make([][]*T, len(storages))
+	errs := make([]error, len(storages))
+
+	var wg sync.WaitGroup
+	for i, storage := range storages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = storage.GetObjects(ctx)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("error listing shard '%s': %w", names[i], errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	// during a migration an object may be listed by its old and new shard, the new one wins
+	placed := make(map[I]struct{})
+	if migrating {
+		for i, objects := range results {
+			for _, object := range objects {
+				id := (*object).GetUniqueIdentifier()
+				if current.placement.Locate(r.key(id)) == names[i] {
+					placed[id] = struct{}{}
+				}
+			}
+		}
+	}
+
+	objects := make([]*T, 0)
+	for i, shardObjects := range results {
+		for _, object := range shardObjects {
+			id := (*object).GetUniqueIdentifier()
+			if _, ok := placed[id]; ok && current.placement.Locate(r.key(id)) != names[i] {
+				continue
+			}
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+// GetObjectByID - impl genericports.GenericStoragePort.GetObjectByID
+//
+// During a migration falls back to the old owner on genericports.ErrNotFound
+func (r *Router[I, T]) GetObjectByID(ctx context.Context, id I) (*T, error) {
+	current, previous := r.owners(id)
+
+	object, err := current.GetObjectByID(ctx, id)
+	if previous == nil || !errors.Is(err, genericports.ErrNotFound) {
+		return object, err
+	}
+	return previous.GetObjectByID(ctx, id)
+}
+
+// CreateObject - impl genericports.GenericStoragePort.CreateObject
+//
+// During a migration the old owner is checked first, so IDs stay unique
+func (r *Router[I, T]) CreateObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	id := (*fullyReadyObject).GetUniqueIdentifier()
+	current, previous := r.owners(id)
+
+	if previous != nil {
+		_, err := previous.GetObjectByID(ctx, id)
+		if err == nil {
+			return nil, fmt.Errorf("%w: id '%v'", genericports.ErrConflict, id)
+		}
+		if !errors.Is(err, genericports.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return current.CreateObject(ctx, fullyReadyObject)
+}
+
+// UpdateObject - impl genericports.GenericStoragePort.UpdateObject
+//
+// During a migration an object that is still in its old shard is moved to the new one, then updated there
+func (r *Router[I, T]) UpdateObject(ctx context.Context, fullyReadyObject *T) (*T, error) {
+	id := (*fullyReadyObject).GetUniqueIdentifier()
+	current, previous := r.owners(id)
+
+	updated, err := current.UpdateObject(ctx, fullyReadyObject)
+	if previous == nil || !errors.Is(err, genericports.ErrNotFound) {
+		return updated, err
+	}
+
+	stored, err := previous.GetObjectByID(ctx, id)
+	if errors.Is(err, genericports.ErrNotFound) {
+		// Rebalance may have moved the object since the first try
+		return current.UpdateObject(ctx, fullyReadyObject)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.move(ctx, previous, current, stored); err != nil {
+		return nil, err
+	}
+	// the caller's object is written over whatever copy the new owner has now
+	return current.UpdateObject(ctx, fullyReadyObject)
+}
+
+// DeleteObject - impl genericports.GenericStoragePort.DeleteObject
+//
+// During a migration deletes from both places, genericports.ErrNotFound only if found in neither
+func (r *Router[I, T]) DeleteObject(ctx context.Context, id I) error {
+	current, previous := r.owners(id)
+
+	err := current.DeleteObject(ctx, id)
+	if previous == nil {
+		return err
+	}
+	if err != nil && !errors.Is(err, genericports.ErrNotFound) {
+		return err
+	}
+
+	previousErr := previous.DeleteObject(ctx, id)
+	if previousErr != nil && (!errors.Is(previousErr, genericports.ErrNotFound) || err != nil) {
+		return previousErr
+	}
+	return nil
+}
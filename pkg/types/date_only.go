@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -46,3 +47,25 @@ func (d DateOnly) Value() time.Time {
 func (d DateOnly) String() string {
 	return d.Value().Format("2006-01-02")
 }
+
+// MarshalJSON returns types.DateOnly as 'YYYY-MM-DD' string
+func (d DateOnly) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON parses types.DateOnly with NewDateOnlyFromString, null is invalid
+func (d *DateOnly) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid date: %w", err)
+	}
+	if s == nil {
+		return fmt.Errorf("invalid date: null")
+	}
+	parsed, err := NewDateOnlyFromString(*s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
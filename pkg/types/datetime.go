@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -41,3 +42,25 @@ func (d DateTime) Value() time.Time {
 func (d DateTime) String() string {
 	return d.Value().Format(datetimeFormat)
 }
+
+// MarshalJSON returns types.DateTime as “2006-01-02 15:04:05“ string
+func (d DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON parses types.DateTime with NewDateTimeFromString, null is invalid
+func (d *DateTime) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid datetime: %w", err)
+	}
+	if s == nil {
+		return fmt.Errorf("invalid datetime: null")
+	}
+	parsed, err := NewDateTimeFromString(*s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
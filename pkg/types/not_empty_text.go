@@ -1,7 +1,9 @@
 package types
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 )
 
 var ErrEmptyText = errors.New("empty text")
@@ -21,3 +23,20 @@ func NewNotEmptyText(text string) (NotEmptyText, error) {
 func (d NotEmptyText) String() string {
 	return string(d)
 }
+
+// UnmarshalJSON validates text with NewNotEmptyText, null is invalid
+func (d *NotEmptyText) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid text: %w", err)
+	}
+	if s == nil {
+		return ErrEmptyText
+	}
+	parsed, err := NewNotEmptyText(*s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
@@ -1,7 +1,9 @@
 package types
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 )
 
 // ErrLessThanZero is an error when given value is less than 0
@@ -26,3 +28,25 @@ func NewPositiveIntID(value int) (PositiveIntID, error) {
 func (v PositiveIntID) Value() int {
 	return v.value
 }
+
+// MarshalJSON returns types.PositiveIntID as number
+func (v PositiveIntID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.value)
+}
+
+// UnmarshalJSON validates number with NewPositiveIntID, null is invalid
+func (v *PositiveIntID) UnmarshalJSON(data []byte) error {
+	var i *int
+	if err := json.Unmarshal(data, &i); err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	if i == nil {
+		return ErrLessThanZero
+	}
+	parsed, err := NewPositiveIntID(*i)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 )
@@ -37,3 +38,25 @@ func (v UUID) Value() uuid.UUID {
 func (v UUID) String() string {
 	return v.value.String()
 }
+
+// MarshalJSON returns types.UUID as string
+func (v UUID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// UnmarshalJSON parses types.UUID with NewUUID, null is invalid
+func (v *UUID) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid uuid: %w", err)
+	}
+	if s == nil {
+		return fmt.Errorf("invalid uuid: null")
+	}
+	parsed, err := NewUUID(*s)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
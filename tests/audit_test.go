@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"testing"
+	"time"
+)
+
+type auditedObject struct {
+	ID        string     `json:"id" bson:"_id"`
+	Name      string     `json:"name" bson:"name"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" bson:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at" bson:"deleted_at"`
+}
+
+func (o auditedObject) GetUniqueIdentifier() string { return o.ID }
+
+func (o *auditedObject) GetCreatedAt() time.Time { return o.CreatedAt }
+
+func (o *auditedObject) SetCreatedAt(createdAt time.Time) { o.CreatedAt = createdAt }
+
+func (o *auditedObject) SetUpdatedAt(updatedAt time.Time) { o.UpdatedAt = updatedAt }
+
+func (o *auditedObject) GetDeletedAt() *time.Time { return o.DeletedAt }
+
+func (o *auditedObject) SetDeletedAt(deletedAt *time.Time) { o.DeletedAt = deletedAt }
+
+type plainObject struct {
+	ID string
+}
+
+func (o plainObject) GetUniqueIdentifier() string { return o.ID }
+
+func TestAuditInterfacesDetection(t *testing.T) {
+	if !genericports.IsSoftDeletable[auditedObject]() {
+		t.Error("Expected *auditedObject to be soft-deletable")
+	}
+	if !genericports.IsTimestamped[auditedObject]() {
+		t.Error("Expected *auditedObject to be timestamped")
+	}
+	if genericports.IsSoftDeletable[plainObject]() || genericports.IsTimestamped[plainObject]() {
+		t.Error("Expected *plainObject to implement no audit interfaces")
+	}
+}
+
+func TestStampCreatedAndUpdated(t *testing.T) {
+	created := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+
+	object := &auditedObject{ID: "1"}
+	genericports.StampCreated(object, created)
+	if !object.CreatedAt.Equal(created) || !object.UpdatedAt.Equal(created) {
+		t.Errorf("Expected both timestamps to be %v, got %v and %v", created, object.CreatedAt, object.UpdatedAt)
+	}
+
+	genericports.StampUpdated(object, updated)
+	if !object.CreatedAt.Equal(created) {
+		t.Errorf("Expected created time to stay %v, got %v", created, object.CreatedAt)
+	}
+	if !object.UpdatedAt.Equal(updated) {
+		t.Errorf("Expected updated time %v, got %v", updated, object.UpdatedAt)
+	}
+
+	// must be a no-op for plain objects
+	genericports.StampCreated(&plainObject{ID: "2"}, created)
+}
+
+func TestWithDeleted(t *testing.T) {
+	ctx := context.Background()
+	if genericports.IsWithDeleted(ctx) {
+		t.Error("Expected deleted objects to be excluded by default")
+	}
+	if !genericports.IsWithDeleted(genericports.WithDeleted(ctx)) {
+		t.Error("Expected deleted objects to be included with WithDeleted")
+	}
+}
+
+func TestClockFunc(t *testing.T) {
+	fixed := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := genericports.ClockFunc(func() time.Time { return fixed })
+	if !clock.Now().Equal(fixed) {
+		t.Errorf("Expected %v, got %v", fixed, clock.Now())
+	}
+}
@@ -0,0 +1,290 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/bloom"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+var bloomParams = bloom.NewParams(1000, 0.01)
+
+func bloomFilters(t *testing.T) map[string]func(t *testing.T) bloom.Filter {
+	return map[string]func(t *testing.T) bloom.Filter{
+		"memory": func(t *testing.T) bloom.Filter {
+			return newMemoryFilter(t)
+		},
+		"redis": func(t *testing.T) bloom.Filter {
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { _ = client.Close() })
+
+			filter, err := bloom.NewRedisFilter(client, "orders", bloomParams, 0)
+			if err != nil {
+				t.Fatalf("NewRedisFilter failed: %v", err)
+			}
+			return filter
+		},
+	}
+}
+
+func newMemoryFilter(t *testing.T) *bloom.MemoryFilter {
+	t.Helper()
+	filter, err := bloom.NewMemoryFilter(bloomParams)
+	if err != nil {
+		t.Fatalf("NewMemoryFilter failed: %v", err)
+	}
+	return filter
+}
+
+func TestNewMemoryFilterInvalidParams(t *testing.T) {
+	for _, params := range []bloom.Params{{Bits: 0, Hashes: 7}, {Bits: 1000, Hashes: 0}} {
+		if _, err := bloom.NewMemoryFilter(params); !errors.Is(err, bloom.ErrInvalidParams) {
+			t.Errorf("Expected ErrInvalidParams for %+v, got %v", params, err)
+		}
+	}
+}
+
+func TestBloomParams(t *testing.T) {
+	params := bloom.NewParams(1000, 0.01)
+	// ~9.6 bits and ~7 hashes per key for 1%
+	if params.Bits < 9000 || params.Bits > 10000 || params.Hashes != 7 {
+		t.Errorf("Unexpected params %+v", params)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	for name, newFilter := range bloomFilters(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			filter := newFilter(t)
+
+			for i := 0; i < 1000; i++ {
+				if err := filter.Add(ctx, fmt.Sprint("id-", i)); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+
+			for i := 0; i < 1000; i++ {
+				if found, err := filter.MayContain(ctx, fmt.Sprint("id-", i)); err != nil || !found {
+					t.Fatalf("Added key id-%d must be found, got %v, %v", i, found, err)
+				}
+			}
+
+			falsePositives := 0
+			for i := 0; i < 1000; i++ {
+				if found, _ := filter.MayContain(ctx, fmt.Sprint("missing-", i)); found {
+					falsePositives++
+				}
+			}
+			if falsePositives > 30 {
+				t.Errorf("Expected about 1%% false positives, got %d of 1000", falsePositives)
+			}
+		})
+	}
+}
+
+func TestBloomFilterRebuild(t *testing.T) {
+	for name, newFilter := range bloomFilters(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			filter := newFilter(t)
+			_ = filter.Add(ctx, "deleted")
+
+			rebuilder, err := filter.StartRebuild(ctx)
+			if err != nil {
+				t.Fatalf("StartRebuild failed: %v", err)
+			}
+			if _, err = filter.StartRebuild(ctx); !errors.Is(err, bloom.ErrRebuildInProgress) {
+				t.Errorf("Expected ErrRebuildInProgress, got %v", err)
+			}
+
+			if err = rebuilder.Add(ctx, "kept"); err != nil {
+				t.Fatalf("Rebuilder.Add failed: %v", err)
+			}
+			// created during the rebuild
+			_ = filter.Add(ctx, "created")
+
+			if found, _ := filter.MayContain(ctx, "kept"); found {
+				t.Error("Rebuilt keys must not be visible before commit")
+			}
+			if err = rebuilder.Commit(ctx); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+			if err = rebuilder.Commit(ctx); !errors.Is(err, bloom.ErrRebuildFinished) {
+				t.Errorf("Expected ErrRebuildFinished on second commit, got %v", err)
+			}
+
+			for key, expected := range map[string]bool{"deleted": false, "kept": true, "created": true} {
+				if found, _ := filter.MayContain(ctx, key); found != expected {
+					t.Errorf("Expected MayContain(%s) = %v after rebuild", key, expected)
+				}
+			}
+
+			rebuilder, _ = filter.StartRebuild(ctx)
+			if err = rebuilder.Abort(ctx); err != nil {
+				t.Fatalf("Abort failed: %v", err)
+			}
+			if found, _ := filter.MayContain(ctx, "kept"); !found {
+				t.Error("Aborted rebuild must keep the filter")
+			}
+		})
+	}
+}
+
+func TestNewRedisFilterInvalidParams(t *testing.T) {
+	_, err := bloom.NewRedisFilter(redis.NewClient(&redis.Options{}), "orders", bloom.Params{Bits: 1 << 33, Hashes: 1}, 0)
+	if !errors.Is(err, bloom.ErrInvalidParams) {
+		t.Errorf("Expected ErrInvalidParams, got %v", err)
+	}
+}
+
+// countingStorage counts GetObjectByID calls that reached the storage
+type countingStorage struct {
+	*genericportstest.InMemoryStorage[string, conformanceObject]
+	reads int
+}
+
+func (s *countingStorage) GetObjectByID(ctx context.Context, id string) (*conformanceObject, error) {
+	s.reads++
+	return s.InMemoryStorage.GetObjectByID(ctx, id)
+}
+
+func TestBloomGuard(t *testing.T) {
+	ctx := context.Background()
+	storage := &countingStorage{InMemoryStorage: genericportstest.NewInMemoryStorage[string, conformanceObject]()}
+	_, _ = storage.CreateObject(ctx, newConformanceObject(1))
+
+	guard := bloom.NewGuard[string, conformanceObject](storage, newMemoryFilter(t), bloom.GuardOptions[string]{})
+	if err := guard.Rebuild(ctx); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+
+	if _, err := guard.GetObjectByID(ctx, newConformanceObject(1).ID); err != nil {
+		t.Fatalf("Existing object must be found, got %v", err)
+	}
+	if _, err := guard.CreateObject(ctx, newConformanceObject(2)); err != nil {
+		t.Fatalf("CreateObject failed: %v", err)
+	}
+	if _, err := guard.GetObjectByID(ctx, newConformanceObject(2).ID); err != nil {
+		t.Fatalf("Created object must be found, got %v", err)
+	}
+
+	storage.reads = 0
+	for i := 100; i < 200; i++ {
+		if _, err := guard.GetObjectByID(ctx, newConformanceObject(i).ID); !errors.Is(err, genericports.ErrNotFound) {
+			t.Fatalf("Expected ErrNotFound, got %v", err)
+		}
+	}
+	if storage.reads > 5 {
+		t.Errorf("Expected missing IDs to be answered by the filter, %d of 100 reached the storage", storage.reads)
+	}
+}
+
+var errBloomFilter = errors.New("filter is down")
+
+// failingFilter fails every call
+type failingFilter struct {
+	bloom.Filter
+}
+
+func (failingFilter) Add(ctx context.Context, keys ...string) error { return errBloomFilter }
+
+func (failingFilter) MayContain(ctx context.Context, key string) (bool, error) {
+	return false, errBloomFilter
+}
+
+func TestBloomGuardFailingFilter(t *testing.T) {
+	ctx := context.Background()
+	storage := genericportstest.NewInMemoryStorage[string, conformanceObject]()
+	_, _ = storage.CreateObject(ctx, newConformanceObject(1))
+	guard := bloom.NewGuard[string, conformanceObject](storage, failingFilter{}, bloom.GuardOptions[string]{})
+
+	// reads fail open
+	if _, err := guard.GetObjectByID(ctx, newConformanceObject(1).ID); err != nil {
+		t.Errorf("Expected the read to go to the storage, got %v", err)
+	}
+	if _, err := guard.UpdateObject(ctx, modifyConformanceObject(newConformanceObject(1))); err != nil {
+		t.Errorf("Expected the update to go to the storage, got %v", err)
+	}
+
+	// creates fail closed
+	if _, err := guard.CreateObject(ctx, newConformanceObject(2)); !errors.Is(err, errBloomFilter) {
+		t.Errorf("Expected the filter error on create, got %v", err)
+	}
+	if _, err := storage.GetObjectByID(ctx, newConformanceObject(2).ID); !errors.Is(err, genericports.ErrNotFound) {
+		t.Errorf("Expected nothing to be created when the filter fails, got %v", err)
+	}
+
+	if err := guard.DeleteObject(ctx, newConformanceObject(1).ID); err != nil {
+		t.Errorf("Expected the delete to go to the storage, got %v", err)
+	}
+}
+
+func TestConformanceBloomGuard(t *testing.T) {
+	genericportstest.RunStorageSuite(t, genericportstest.StorageSuite[string, conformanceObject]{
+		NewStorage: func(t *testing.T) genericports.GenericStoragePort[string, conformanceObject] {
+			return bloom.NewGuard[string, conformanceObject](
+				genericportstest.NewInMemoryStorage[string, conformanceObject](),
+				newMemoryFilter(t),
+				bloom.GuardOptions[string]{},
+			)
+		},
+		NewObject: newConformanceObject,
+		Modify:    modifyConformanceObject,
+	})
+}
+
+func TestRedisFilterExpiredRebuildOwner(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	filter, err := bloom.NewRedisFilter(client, "orders", bloomParams, time.Second)
+	if err != nil {
+		t.Fatalf("NewRedisFilter failed: %v", err)
+	}
+
+	stale, err := filter.StartRebuild(ctx)
+	if err != nil {
+		t.Fatalf("StartRebuild failed: %v", err)
+	}
+	// the first replica stalls, its rebuild expires and another replica starts a new one
+	server.FastForward(2 * time.Second)
+	current, err := filter.StartRebuild(ctx)
+	if err != nil {
+		t.Fatalf("Expected the expired rebuild to be replaced, got %v", err)
+	}
+
+	if err = stale.Add(ctx, "stale"); !errors.Is(err, bloom.ErrRebuildFinished) {
+		t.Errorf("Expected the stale rebuilder not to add, got %v", err)
+	}
+	if err = stale.Commit(ctx); !errors.Is(err, bloom.ErrRebuildFinished) {
+		t.Errorf("Expected the stale rebuilder not to commit, got %v", err)
+	}
+	if err = stale.Abort(ctx); !errors.Is(err, bloom.ErrRebuildFinished) {
+		t.Errorf("Expected the stale rebuilder not to abort, got %v", err)
+	}
+
+	if err = current.Add(ctx, "fresh"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err = current.Commit(ctx); err != nil {
+		t.Fatalf("Expected the current rebuild to commit, got %v", err)
+	}
+	if found, _ := filter.MayContain(ctx, "fresh"); !found {
+		t.Error("Expected the key of the current rebuild to be found")
+	}
+	if found, _ := filter.MayContain(ctx, "stale"); found {
+		t.Error("Expected the key of the stale rebuild to be missing")
+	}
+	if keys := server.Keys(); len(keys) != 1 {
+		t.Errorf("Expected only the filter key to be left, got %v", keys)
+	}
+}
@@ -0,0 +1,413 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/bulk"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type bulkItem struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Price    int               `json:"price"`
+	Active   bool              `json:"active"`
+	Comment  *string           `json:"comment"`
+	Tags     []string          `json:"tags"`
+	Delivery patchDelivery     `json:"delivery"`
+	Extra    map[string]string `json:"extra,omitempty"`
+}
+
+func (i bulkItem) GetUniqueIdentifier() string { return i.ID }
+
+func newBulkItem(n int) *bulkItem {
+	comment := fmt.Sprintf("comment, \"quoted\" %d", n)
+	return &bulkItem{
+		ID:       fmt.Sprintf("item-%03d", n),
+		Name:     fmt.Sprint(n * 10), // looks like a number but is a string
+		Price:    n,
+		Active:   n%2 == 0,
+		Comment:  &comment,
+		Tags:     []string{"a", fmt.Sprint(n)},
+		Delivery: patchDelivery{City: "Kazan", Street: fmt.Sprintf("street %d", n)},
+	}
+}
+
+func newBulkStorage(t *testing.T, amount int) *genericportstest.InMemoryStorage[string, bulkItem] {
+	t.Helper()
+	storage := genericportstest.NewInMemoryStorage[string, bulkItem]()
+	for i := 0; i < amount; i++ {
+		if _, err := storage.CreateObject(context.Background(), newBulkItem(i)); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+	}
+	return storage
+}
+
+func TestBulkRoundTrip(t *testing.T) {
+	for _, format := range []bulk.Format{bulk.FormatNDJSON, bulk.FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			ctx := context.Background()
+			source := newBulkStorage(t, 25)
+
+			var buffer bytes.Buffer
+			exportStats, err := bulk.Export[string, bulkItem](ctx, source, &buffer, bulk.ExportOptions{Format: format, BatchSize: 10})
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			if exportStats.Exported != 25 {
+				t.Errorf("Expected 25 exported objects, got %d", exportStats.Exported)
+			}
+
+			target := genericportstest.NewInMemoryStorage[string, bulkItem]()
+			importStats, err := bulk.Import[string, bulkItem](ctx, target, &buffer, bulk.ImportOptions{Format: format, BatchSize: 10})
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if importStats.Created != 25 {
+				t.Errorf("Expected 25 created objects, got %d", importStats.Created)
+			}
+
+			for i := 0; i < 25; i++ {
+				expected := newBulkItem(i)
+				found, err := target.GetObjectByID(ctx, expected.ID)
+				if err != nil {
+					t.Fatalf("GetObjectByID failed: %v", err)
+				}
+				if !reflect.DeepEqual(found, expected) {
+					t.Errorf("Expected %+v, got %+v", expected, found)
+				}
+			}
+		})
+	}
+}
+
+func TestBulkCSVHeader(t *testing.T) {
+	var buffer bytes.Buffer
+	_, err := bulk.Export[string, bulkItem](context.Background(), newBulkStorage(t, 1), &buffer, bulk.ExportOptions{Format: bulk.FormatCSV})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	header, _, _ := strings.Cut(buffer.String(), "\n")
+	if header != "id,name,price,active,comment,tags,delivery,extra" {
+		t.Errorf("Unexpected csv header %s", header)
+	}
+}
+
+func TestBulkImportConflicts(t *testing.T) {
+	ctx := context.Background()
+	input := func() *bytes.Buffer {
+		var buffer bytes.Buffer
+		source := newBulkStorage(t, 4)
+		_, _ = source.UpdateObject(ctx, &bulkItem{ID: "item-001", Name: "changed"})
+		if _, err := bulk.Export[string, bulkItem](ctx, source, &buffer, bulk.ExportOptions{}); err != nil {
+			t.Fatalf("Export failed: %v", err)
+		}
+		return &buffer
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := bulk.Import[string, bulkItem](ctx, newBulkStorage(t, 2), input(), bulk.ImportOptions{})
+		if !errors.Is(err, genericports.ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		target := newBulkStorage(t, 2)
+		stats, err := bulk.Import[string, bulkItem](ctx, target, input(), bulk.ImportOptions{OnConflict: bulk.ConflictSkip})
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if stats.Created != 2 || stats.Skipped != 2 {
+			t.Errorf("Expected 2 created and 2 skipped, got %+v", stats)
+		}
+		if found, _ := target.GetObjectByID(ctx, "item-001"); found.Name != "10" {
+			t.Errorf("Expected stored object to be kept, got %+v", found)
+		}
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		target := newBulkStorage(t, 2)
+		stats, err := bulk.Import[string, bulkItem](ctx, target, input(), bulk.ImportOptions{OnConflict: bulk.ConflictUpsert})
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if stats.Created != 2 || stats.Updated != 2 {
+			t.Errorf("Expected 2 created and 2 updated, got %+v", stats)
+		}
+		if found, _ := target.GetObjectByID(ctx, "item-001"); found.Name != "changed" {
+			t.Errorf("Expected stored object to be replaced, got %+v", found)
+		}
+	})
+}
+
+// failingStorage fails CreateObject of one ID
+type failingStorage struct {
+	*genericportstest.InMemoryStorage[string, bulkItem]
+	failID string
+}
+
+func (s *failingStorage) CreateObject(ctx context.Context, object *bulkItem) (*bulkItem, error) {
+	if object.ID == s.failID {
+		return nil, errors.New("storage is down")
+	}
+	return s.InMemoryStorage.CreateObject(ctx, object)
+}
+
+func TestBulkImportResume(t *testing.T) {
+	ctx := context.Background()
+	checkpoint := filepath.Join(t.TempDir(), "import.checkpoint")
+
+	var data bytes.Buffer
+	if _, err := bulk.Export[string, bulkItem](ctx, newBulkStorage(t, 25), &data, bulk.ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	target := &failingStorage{InMemoryStorage: genericportstest.NewInMemoryStorage[string, bulkItem](), failID: "item-012"}
+	opts := bulk.ImportOptions{BatchSize: 10, CheckpointPath: checkpoint}
+
+	if _, err := bulk.Import[string, bulkItem](ctx, target, bytes.NewReader(data.Bytes()), opts); err == nil {
+		t.Fatal("Expected import to fail")
+	}
+	saved, err := bulk.LoadCheckpoint(checkpoint)
+	if err != nil || saved.Records != 10 {
+		t.Fatalf("Expected checkpoint after the first batch, got %+v, %v", saved, err)
+	}
+
+	// the half-written batch is written again, skip conflicts
+	target.failID = ""
+	opts.OnConflict = bulk.ConflictSkip
+	stats, err := bulk.Import[string, bulkItem](ctx, target, bytes.NewReader(data.Bytes()), opts)
+	if err != nil {
+		t.Fatalf("Resumed import failed: %v", err)
+	}
+	if stats.Resumed != 10 || stats.Skipped != 2 || stats.Created != 13 {
+		t.Errorf("Expected 10 resumed, 2 skipped and 13 created, got %+v", stats)
+	}
+	if _, err = os.Stat(checkpoint); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected checkpoint to be removed, got %v", err)
+	}
+}
+
+// pagedBulkStorage is a genericports.PagedStoragePort over the in-memory storage, GetObjects fails,
+// so Export must page. pages counts GetObjectsAfter calls
+type pagedBulkStorage struct {
+	*genericportstest.InMemoryStorage[string, bulkItem]
+	pages int
+}
+
+func (s *pagedBulkStorage) GetObjects(ctx context.Context) ([]*bulkItem, error) {
+	return nil, errors.New("paged storage must not be read whole")
+}
+
+func (s *pagedBulkStorage) GetObjectsAfter(ctx context.Context, afterID *string, limit int) ([]*bulkItem, error) {
+	s.pages++
+	objects, err := s.InMemoryStorage.GetObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(objects, func(a, b *bulkItem) int { return strings.Compare(a.ID, b.ID) })
+	objects = slices.DeleteFunc(objects, func(object *bulkItem) bool { return afterID != nil && object.ID <= *afterID })
+	return objects[:min(limit, len(objects))], nil
+}
+
+func TestBulkExportPaged(t *testing.T) {
+	storage := &pagedBulkStorage{InMemoryStorage: newBulkStorage(t, 25)}
+
+	var expected, paged bytes.Buffer
+	if _, err := bulk.Export[string, bulkItem](context.Background(), storage.InMemoryStorage, &expected, bulk.ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	stats, err := bulk.Export[string, bulkItem](context.Background(), storage, &paged, bulk.ExportOptions{BatchSize: 10})
+	if err != nil {
+		t.Fatalf("Paged export failed: %v", err)
+	}
+	if stats.Exported != 25 || storage.pages != 3 {
+		t.Errorf("Expected 25 objects in 3 pages, got %+v in %d pages", stats, storage.pages)
+	}
+	if !bytes.Equal(paged.Bytes(), expected.Bytes()) {
+		t.Errorf("Paged export differs:\n%s\nexpected:\n%s", paged.Bytes(), expected.Bytes())
+	}
+}
+
+func TestBulkExportResume(t *testing.T) {
+	storages := map[string]func(t *testing.T) genericports.GenericStoragePort[string, bulkItem]{
+		"whole": func(t *testing.T) genericports.GenericStoragePort[string, bulkItem] {
+			return newBulkStorage(t, 25)
+		},
+		"paged": func(t *testing.T) genericports.GenericStoragePort[string, bulkItem] {
+			return &pagedBulkStorage{InMemoryStorage: newBulkStorage(t, 25)}
+		},
+	}
+	for name, newStorage := range storages {
+		for _, format := range []bulk.Format{bulk.FormatNDJSON, bulk.FormatCSV} {
+			t.Run(name+" "+string(format), func(t *testing.T) {
+				dir := t.TempDir()
+				checkpoint := filepath.Join(dir, "export.checkpoint")
+				storage := newStorage(t)
+
+				var expected bytes.Buffer
+				if _, err := bulk.Export[string, bulkItem](context.Background(), newBulkStorage(t, 25), &expected, bulk.ExportOptions{Format: format}); err != nil {
+					t.Fatalf("Export failed: %v", err)
+				}
+
+				file, err := os.OpenFile(filepath.Join(dir, "export"), os.O_CREATE|os.O_RDWR, 0o644)
+				if err != nil {
+					t.Fatalf("OpenFile failed: %v", err)
+				}
+				defer file.Close()
+
+				// stops after the first checkpoint
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				opts := bulk.ExportOptions{Format: format, BatchSize: 10, CheckpointPath: checkpoint}
+				if _, err = bulk.Export[string, bulkItem](ctx, storage, file, opts); !errors.Is(err, context.Canceled) {
+					t.Fatalf("Expected export to be canceled, got %v", err)
+				}
+				// garbage after the checkpoint, like a crash in the middle of a batch
+				_, _ = file.WriteString("half-written")
+				// created between the runs before the last exported ID, it's neither exported nor resumed
+				late := newBulkItem(100)
+				late.ID = "item-0005"
+				if _, err = storage.CreateObject(context.Background(), late); err != nil {
+					t.Fatalf("CreateObject failed: %v", err)
+				}
+
+				stats, err := bulk.Export[string, bulkItem](context.Background(), storage, file, opts)
+				if err != nil {
+					t.Fatalf("Resumed export failed: %v", err)
+				}
+				if stats.Resumed != 10 || stats.Exported != 15 {
+					t.Errorf("Expected 10 resumed and 15 exported, got %+v", stats)
+				}
+
+				written, _ := os.ReadFile(file.Name())
+				if !bytes.Equal(written, expected.Bytes()) {
+					t.Errorf("Resumed export differs:\n%s\nexpected:\n%s", written, expected.Bytes())
+				}
+			})
+		}
+	}
+}
+
+func TestBulkExportToPipe(t *testing.T) {
+	storage := newBulkStorage(t, 5)
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe failed: %v", err)
+	}
+	defer reader.Close()
+
+	var expected bytes.Buffer
+	if _, err = bulk.Export[string, bulkItem](context.Background(), storage, &expected, bulk.ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	read := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		read <- data
+	}()
+
+	// a pipe is an *os.File, so it's a Truncater, but a fresh export must not truncate it
+	stats, err := bulk.Export[string, bulkItem](context.Background(), storage, writer, bulk.ExportOptions{})
+	_ = writer.Close()
+	if err != nil {
+		t.Fatalf("Export into a pipe failed: %v", err)
+	}
+	if stats.Exported != 5 {
+		t.Errorf("Expected 5 exported, got %+v", stats)
+	}
+	if data := <-read; !bytes.Equal(data, expected.Bytes()) {
+		t.Errorf("Piped export differs:\n%s\nexpected:\n%s", data, expected.Bytes())
+	}
+}
+
+// mapRecord is a row without typed fields like the record of cmd/bulk, CSV can't detect its columns
+type mapRecord struct {
+	values map[string]any
+}
+
+func (r mapRecord) GetUniqueIdentifier() string { return fmt.Sprint(r.values["id"]) }
+
+func (r mapRecord) MarshalJSON() ([]byte, error) { return json.Marshal(r.values) }
+
+func (r *mapRecord) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(&r.values)
+}
+
+func TestBulkCSVStringColumns(t *testing.T) {
+	ctx := context.Background()
+	texts := []string{"", "null", "[1, 2]", `{"a": 1}`, "10"}
+
+	source := genericportstest.NewInMemoryStorage[string, mapRecord]()
+	for i, text := range texts {
+		_, _ = source.CreateObject(ctx, &mapRecord{values: map[string]any{"id": fmt.Sprint(i), "name": text, "price": json.Number("5")}})
+	}
+
+	var buffer bytes.Buffer
+	columns := []string{"id", "name", "price"}
+	if _, err := bulk.Export[string, mapRecord](ctx, source, &buffer, bulk.ExportOptions{Format: bulk.FormatCSV, Columns: columns}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	target := genericportstest.NewInMemoryStorage[string, mapRecord]()
+	_, err := bulk.Import[string, mapRecord](ctx, target, &buffer, bulk.ImportOptions{Format: bulk.FormatCSV, StringColumns: []string{"id", "name"}})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for i, text := range texts {
+		imported, err := target.GetObjectByID(ctx, fmt.Sprint(i))
+		if err != nil {
+			t.Fatalf("GetObjectByID failed: %v", err)
+		}
+		if name, ok := imported.values["name"].(string); !ok || name != text {
+			t.Errorf("Expected text %q to round-trip as is, got %#v", text, imported.values["name"])
+		}
+		// columns that aren't declared as strings are still JSON values
+		if price := imported.values["price"]; price != json.Number("5") {
+			t.Errorf("Expected price to be the number 5, got %#v", price)
+		}
+	}
+}
+
+func TestBulkInvalidInput(t *testing.T) {
+	tests := map[string]struct {
+		format bulk.Format
+		input  string
+	}{
+		"ndjson unknown field": {format: bulk.FormatNDJSON, input: `{"id": "1", "weight": 10}`},
+		"ndjson broken":        {format: bulk.FormatNDJSON, input: `{"id": `},
+		"csv not a number":     {format: bulk.FormatCSV, input: "id,price\n1,ten\n"},
+		"csv unknown column":   {format: bulk.FormatCSV, input: "id,weight\n1,10\n"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			storage := genericportstest.NewInMemoryStorage[string, bulkItem]()
+			_, err := bulk.Import[string, bulkItem](context.Background(), storage, strings.NewReader(tt.input), bulk.ImportOptions{Format: tt.format})
+			if !errors.Is(err, bulk.ErrInvalidRecord) {
+				t.Errorf("Expected ErrInvalidRecord, got %v", err)
+			}
+		})
+	}
+
+	if _, err := bulk.ParseFormat("xml"); !errors.Is(err, bulk.ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
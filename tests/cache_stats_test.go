@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	cachegenericport "github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/genericport"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/memory"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/rediscache"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/sharded"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/services"
+	"testing"
+	"time"
+)
+
+// compile-time check of the caches that report stats
+var (
+	_ pkgports.CacheStatsReporter = (*memory.Cache[string, int])(nil)
+	_ pkgports.CacheStatsReporter = (*sharded.Cache[string, int])(nil)
+	_ pkgports.CacheStatsReporter = (*cachegenericport.RedisGenericCache[string, conformanceObject])(nil)
+	_ pkgports.CacheStatsReporter = (*rediscache.Cache[string, int])(nil)
+)
+
+func TestMemoryCacheStats(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newMemoryCache[string, int](t, 2, memory.PolicyLRU)
+
+	_ = cache.Set(ctx, "a", 1)
+	_ = cache.Set(ctx, "a", 2) // replaced
+	_ = cache.Set(ctx, "b", 3)
+	_ = cache.Set(ctx, "c", 4) // a is evicted by capacity
+	_, _, _ = cache.Get(ctx, "a")
+	_, _, _ = cache.Get(ctx, "b")
+	_, _, _ = cache.Get(ctx, "c")
+	_, _, _ = cache.Peek(ctx, "c") // not counted
+	_ = cache.Delete(ctx, "b")
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Sets != 4 || stats.Size != 1 {
+		t.Errorf("Expected 2 hits, 1 miss, 4 sets and size 1, got %+v", stats)
+	}
+	for reason, expected := range map[memory.EvictReason]int64{
+		memory.EvictReplaced: 1, memory.EvictCapacity: 1, memory.EvictDeleted: 1, memory.EvictExpired: 0,
+	} {
+		if got := stats.Evictions[string(reason)]; got != expected {
+			t.Errorf("Expected %d evictions by %s, got %d", expected, reason, got)
+		}
+	}
+	if ratio := stats.HitRatio(); ratio < 0.66 || ratio > 0.67 {
+		t.Errorf("Expected hit ratio 2/3, got %f", ratio)
+	}
+
+	cache.ResetStats()
+	stats = cache.Stats()
+	if stats.Hits != 0 || stats.Misses != 0 || stats.Sets != 0 || len(stats.Evictions) != 0 {
+		t.Errorf("Expected counters to be reset, got %+v", stats)
+	}
+	if stats.Size != 1 {
+		t.Errorf("Expected the size to stay after reset, got %d", stats.Size)
+	}
+}
+
+func TestShardedCacheStats(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := sharded.NewCache[int, int](sharded.Options{Shards: 4, Capacity: 100})
+
+	for i := range 10 {
+		_ = cache.Set(ctx, i, i)
+	}
+	for i := range 20 {
+		_, _, _ = cache.Get(ctx, i)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 10 || stats.Misses != 10 || stats.Sets != 10 || stats.Size != 10 {
+		t.Errorf("Expected 10 hits, misses, sets and keys over all shards, got %+v", stats)
+	}
+
+	cache.ResetStats()
+	if stats = cache.Stats(); stats.Hits != 0 || stats.Sets != 0 {
+		t.Errorf("Expected counters of all shards to be reset, got %+v", stats)
+	}
+}
+
+func TestRedisGenericCacheStats(t *testing.T) {
+	ctx := newLoggerContext(t)
+	server := miniredis.RunT(t)
+	cache := cachegenericport.NewRedisGenericCache[string, conformanceObject](server.Addr(), "", 0, 60000)
+
+	object := newConformanceObject(1)
+	if _, err := cache.SaveObject(ctx, object); err != nil {
+		t.Fatalf("SaveObject failed: %v", err)
+	}
+	_, _ = cache.GetObjectByID(ctx, object.ID)
+	_, _ = cache.GetObjectByID(ctx, "missing")
+	_ = cache.DeleteObject(ctx, object.ID)
+	_ = cache.DeleteObject(ctx, object.ID) // nothing to delete
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Sets != 1 || stats.Evictions["deleted"] != 1 {
+		t.Errorf("Expected 1 hit, miss, set and deletion, got %+v", stats)
+	}
+	if stats.Loads != 2 || stats.TotalLoadLatency <= 0 || stats.MaxLoadLatency > stats.TotalLoadLatency {
+		t.Errorf("Expected 2 timed loads, got %+v", stats)
+	}
+	if stats.AverageLoadLatency() > time.Second {
+		t.Errorf("Expected a fast average load from miniredis, got %v", stats.AverageLoadLatency())
+	}
+}
+
+func TestCachePopularServiceStats(t *testing.T) {
+	ctx := newLoggerContext(t)
+	server := miniredis.RunT(t)
+	storage := cachegenericport.NewRedisGenericCache[string, conformanceObject](server.Addr(), "", 0, 60000)
+	service := services.NewCachePopularService[string, conformanceObject](3, 100, storage)
+
+	popular, rare := *newConformanceObject(1), *newConformanceObject(2)
+	for range 5 {
+		_ = service.UpdatePopularity(ctx, popular, 1)
+	}
+	_ = service.UpdatePopularity(ctx, rare, 2)
+
+	stats := service.Stats()
+	if stats.Crossed != 1 {
+		t.Errorf("Expected 1 object to cross minUses, got %d", stats.Crossed)
+	}
+	if stats.Uses.Sets != 6 || stats.Uses.Size != 2 {
+		t.Errorf("Expected 6 uses updates of 2 objects, got %+v", stats.Uses)
+	}
+
+	_ = service.UpdatePopularity(ctx, rare, 1)
+	if stats = service.Stats(); stats.Crossed != 2 {
+		t.Errorf("Expected the second object to cross minUses, got %d", stats.Crossed)
+	}
+
+	service.ResetStats()
+	if stats = service.Stats(); stats.Crossed != 0 || stats.Uses.Sets != 0 {
+		t.Errorf("Expected counters to be reset, got %+v", stats)
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/chaos"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/lru"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func newChaosStorage(injector *chaos.Injector) genericports.GenericStoragePort[string, conformanceObject] {
+	storage := genericportstest.NewInMemoryStorage[string, conformanceObject]()
+	_, _ = storage.CreateObject(context.Background(), newConformanceObject(1))
+	return chaos.NewStorage(storage, injector)
+}
+
+// chaosPattern returns which of n calls failed
+func chaosPattern(storage genericports.GenericStoragePort[string, conformanceObject], n int) []bool {
+	pattern := make([]bool, n)
+	for i := range pattern {
+		_, err := storage.GetObjectByID(context.Background(), newConformanceObject(1).ID)
+		pattern[i] = err != nil
+	}
+	return pattern
+}
+
+func TestChaosReproducible(t *testing.T) {
+	faults := chaos.Faults{ErrorRate: 0.3}
+	first := chaosPattern(newChaosStorage(chaos.NewInjector(7, faults)), 200)
+	second := chaosPattern(newChaosStorage(chaos.NewInjector(7, faults)), 200)
+	other := chaosPattern(newChaosStorage(chaos.NewInjector(8, faults)), 200)
+
+	failed, differs := 0, false
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Same seed gave different faults at call %d", i)
+		}
+		if first[i] {
+			failed++
+		}
+		differs = differs || first[i] != other[i]
+	}
+	if failed < 40 || failed > 80 {
+		t.Errorf("Expected about 60 of 200 calls to fail, got %d", failed)
+	}
+	if !differs {
+		t.Error("Different seeds gave the same faults")
+	}
+}
+
+func TestChaosSentinelErrors(t *testing.T) {
+	injector := chaos.NewInjector(1, chaos.Faults{ErrorRate: 1, Errors: []error{genericports.ErrNotFound, genericports.ErrConflict}})
+	storage := newChaosStorage(injector)
+
+	seen := map[error]bool{}
+	for i := 0; i < 50; i++ {
+		_, err := storage.GetObjectByID(context.Background(), "id-1")
+		if !errors.Is(err, chaos.ErrInjected) {
+			t.Fatalf("Expected ErrInjected, got %v", err)
+		}
+		for _, sentinel := range []error{genericports.ErrNotFound, genericports.ErrConflict} {
+			if errors.Is(err, sentinel) {
+				seen[sentinel] = true
+			}
+		}
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected both sentinel errors to be injected, got %v", seen)
+	}
+	if injected := injector.Injected()["get_object_by_id"]; injected != 50 {
+		t.Errorf("Expected 50 injected faults, got %d", injected)
+	}
+}
+
+func TestChaosRuntimeSwitch(t *testing.T) {
+	injector := chaos.NewInjector(1, chaos.Faults{ErrorRate: 1})
+	storage := newChaosStorage(injector)
+	ctx := context.Background()
+
+	if _, err := storage.GetObjectByID(ctx, "id-1"); !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("Expected injected error, got %v", err)
+	}
+
+	injector.Disable()
+	if _, err := storage.GetObjectByID(ctx, "id-1"); err != nil {
+		t.Fatalf("Disabled injector must pass calls, got %v", err)
+	}
+
+	injector.Enable()
+	injector.SetOperationFaults("get_object_by_id", chaos.Faults{})
+	if _, err := storage.GetObjectByID(ctx, "id-1"); err != nil {
+		t.Fatalf("Operation faults must override common ones, got %v", err)
+	}
+	if _, err := storage.GetObjects(ctx); !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("Other operations must keep common faults, got %v", err)
+	}
+
+	injector.ResetOperationFaults("get_object_by_id")
+	injector.SetFaults(chaos.Faults{})
+	if _, err := storage.GetObjectByID(ctx, "id-1"); err != nil {
+		t.Fatalf("Expected no faults after SetFaults, got %v", err)
+	}
+}
+
+func TestChaosTimeout(t *testing.T) {
+	injector := chaos.NewInjector(1, chaos.Faults{TimeoutRate: 1, Timeout: 20 * time.Millisecond})
+	cache := chaos.NewCache(lru.NewCacheLRUInMemory[string, int](10), injector)
+
+	start := time.Now()
+	err := cache.Set(context.Background(), "key", 1)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("Expected injected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the call to hang for the timeout, took %v", elapsed)
+	}
+	if amount, _ := cache.GetKeysAmount(context.Background()); amount != 0 {
+		t.Error("Timed out call must not reach the cache")
+	}
+
+	// ctx deadline comes first
+	injector.SetFaults(chaos.Faults{TimeoutRate: 1, Timeout: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err = cache.Get(ctx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected ctx deadline, got %v", err)
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	for name, tt := range map[string]struct {
+		latency  chaos.Latency
+		from, to time.Duration
+	}{
+		"fixed":       {latency: chaos.Fixed(time.Second), from: time.Second, to: time.Second},
+		"uniform":     {latency: chaos.Uniform(time.Second, 2*time.Second), from: time.Second, to: 2 * time.Second},
+		"normal":      {latency: chaos.Normal(time.Second, 100*time.Millisecond), from: 0, to: 2 * time.Second},
+		"exponential": {latency: chaos.Exponential(time.Second), from: 0, to: time.Minute},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var total time.Duration
+			for i := 0; i < 1000; i++ {
+				sample := tt.latency.Sample(rng)
+				if sample < tt.from || sample > tt.to {
+					t.Fatalf("Sample %v is out of [%v, %v]", sample, tt.from, tt.to)
+				}
+				total += sample
+			}
+			if mean := total / 1000; name != "uniform" && (mean < 900*time.Millisecond || mean > 1100*time.Millisecond) {
+				t.Errorf("Expected mean about 1s, got %v", mean)
+			}
+		})
+	}
+
+	injector := chaos.NewInjector(1, chaos.Faults{Latency: chaos.Fixed(20 * time.Millisecond)})
+	storage := newChaosStorage(injector)
+	start := time.Now()
+	if _, err := storage.GetObjectByID(context.Background(), "id-1"); err != nil {
+		t.Fatalf("Latency must not fail the call, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected injected latency, took %v", elapsed)
+	}
+}
+
+func TestChaosReceiver(t *testing.T) {
+	receiver := &chanReceiver{messages: make(chan int, 1)}
+	receiver.messages <- 1
+	injector := chaos.NewInjector(1, chaos.Faults{ErrorRate: 1})
+	wrapped := chaos.NewReceiver[int, int](receiver, injector)
+
+	ctx := context.Background()
+	if _, _, err := wrapped.Consume(ctx); !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("Expected injected error, got %v", err)
+	}
+
+	// the message wasn't read by the failed call
+	injector.Disable()
+	value, message, err := wrapped.Consume(ctx)
+	if err != nil || value != 1 {
+		t.Fatalf("Expected message 1, got %v, %v", value, err)
+	}
+	if err = wrapped.OnSuccess(ctx, message); err != nil {
+		t.Fatalf("OnSuccess failed: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	cachegenericport "github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/genericport"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+type codecTestValue struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestJSONCodecRoundTrip(t *testing.T) {
+	c := codec.NewJSON[codecTestValue]()
+	value := codecTestValue{Name: "danis", Count: 42}
+
+	data, err := c.Encode(value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if string(data) != `{"name":"danis","count":42}` {
+		t.Errorf("Expected plain json, got %s", data)
+	}
+
+	decoded, err := c.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded != value {
+		t.Errorf("Expected %v, got %v", value, decoded)
+	}
+}
+
+func TestJSONCodecDecodeInvalid(t *testing.T) {
+	c := codec.NewJSON[codecTestValue]()
+	if _, err := c.Decode([]byte("not json")); err == nil {
+		t.Error("Expected error for invalid json")
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	value := codecTestValue{Name: "danis", Count: 42}
+	codecs := map[string]codec.Codec[codecTestValue]{
+		"json":    codec.NewJSON[codecTestValue](),
+		"gob":     codec.NewGob[codecTestValue](),
+		"msgpack": codec.NewMsgPack[codecTestValue](),
+	}
+	for name, c := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := c.Encode(value)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			decoded, err := c.Decode(data)
+			if err != nil || decoded != value {
+				t.Errorf("Expected %v, got %v (%v)", value, decoded, err)
+			}
+			if _, err = c.Decode([]byte{0xc1}); err == nil {
+				t.Error("Expected error for invalid data")
+			}
+		})
+	}
+}
+
+func TestProtoCodecRoundTrip(t *testing.T) {
+	c := codec.NewProto[*wrapperspb.StringValue]()
+	value := wrapperspb.String("danis")
+
+	data, err := c.Encode(value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := c.Decode(data)
+	if err != nil || !proto.Equal(decoded, value) {
+		t.Errorf("Expected %v, got %v (%v)", value, decoded, err)
+	}
+}
+
+func TestHeaderCodecCompression(t *testing.T) {
+	value := codecTestValue{Name: string(bytes.Repeat([]byte("danis"), 100)), Count: 42}
+	plain, _ := codec.NewJSON[codecTestValue]().Encode(value)
+
+	for _, compression := range []codec.Compression{codec.CompressionNone, codec.CompressionSnappy, codec.CompressionZstd} {
+		c, err := codec.NewHeader(codec.TagJSON, codec.NewJSON[codecTestValue](), compression)
+		if err != nil {
+			t.Fatalf("NewHeader failed: %v", err)
+		}
+		data, err := c.Encode(value)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if compression != codec.CompressionNone && len(data) >= len(plain) {
+			t.Errorf("Expected compression %d to shrink %d bytes, got %d", compression, len(plain), len(data))
+		}
+		if decoded, err := c.Decode(data); err != nil || decoded != value {
+			t.Errorf("Expected the value back with compression %d, got %v", compression, err)
+		}
+	}
+}
+
+func TestHeaderCodecSwitchWithoutFlush(t *testing.T) {
+	value := codecTestValue{Name: "danis", Count: 42}
+	legacy, _ := codec.NewJSON[codecTestValue]().Encode(value)
+	old, _ := codec.NewHeader(codec.TagGob, codec.NewGob[codecTestValue](), codec.CompressionSnappy)
+	oldData, _ := old.Encode(value)
+
+	current, err := codec.NewHeader(codec.TagMsgPack, codec.NewMsgPack[codecTestValue](), codec.CompressionZstd,
+		codec.TaggedCodec[codecTestValue]{Tag: codec.TagJSON, Codec: codec.NewJSON[codecTestValue]()},
+		codec.TaggedCodec[codecTestValue]{Tag: codec.TagGob, Codec: codec.NewGob[codecTestValue]()},
+	)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	for name, data := range map[string][]byte{"json without header": legacy, "gob with snappy": oldData} {
+		if decoded, err := current.Decode(data); err != nil || decoded != value {
+			t.Errorf("Expected %s to be decoded, got %v (%v)", name, decoded, err)
+		}
+	}
+
+	withoutJSON, _ := codec.NewHeader(codec.TagMsgPack, codec.NewMsgPack[codecTestValue](), codec.CompressionNone)
+	if _, err = withoutJSON.Decode(oldData); !errors.Is(err, codec.ErrUnknownHeader) {
+		t.Errorf("Expected ErrUnknownHeader for an unknown tag, got %v", err)
+	}
+	if _, err = withoutJSON.Decode(legacy); !errors.Is(err, codec.ErrUnknownHeader) {
+		t.Errorf("Expected ErrUnknownHeader for data without header, got %v", err)
+	}
+	if _, err = codec.NewHeader(16, codec.NewMsgPack[codecTestValue](), codec.CompressionNone); !errors.Is(err, codec.ErrUnknownHeader) {
+		t.Errorf("Expected ErrUnknownHeader for tag 16, got %v", err)
+	}
+}
+
+func TestConformanceRedisGenericCacheWithCodec(t *testing.T) {
+	c, err := codec.NewHeader(codec.TagMsgPack, codec.NewMsgPack[conformanceObject](), codec.CompressionSnappy)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	genericportstest.RunCachePortSuite(t, genericportstest.CachePortSuite[string, conformanceObject]{
+		NewCache: func(t *testing.T) genericports.GenericCachePort[string, conformanceObject] {
+			server := miniredis.RunT(t)
+			return cachegenericport.NewRedisGenericCacheWithCodec[string, conformanceObject](server.Addr(), "", 0, 60000, c)
+		},
+		NewObject: newConformanceObject,
+		Modify:    modifyConformanceObject,
+	})
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"context"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	storagegenericport "github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/storage/genericport"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"os"
+	"testing"
+)
+
+func newLoggerContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, err := logger.New(context.Background())
+	if err != nil {
+		t.Fatalf("Error creating logger for test: %v", err)
+	}
+	return ctx
+}
+
+// newPostgresTestPool connects to TEST_POSTGRES_DSN, skips the test if it isn't set
+func newPostgresTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("Error connecting to postgres: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// newPostgresTestStorage connects to TEST_POSTGRES_DSN and creates the table of conformanceObject,
+// skips the test if TEST_POSTGRES_DSN isn't set
+func newPostgresTestStorage(t *testing.T) (*pgxpool.Pool, *storagegenericport.PostgresGenericStorage[string, conformanceObject]) {
+	t.Helper()
+	pool := newPostgresTestPool(t)
+
+	_, err := pool.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS conformance_objects (id TEXT PRIMARY KEY, name TEXT NOT NULL)`)
+	if err != nil {
+		t.Fatalf("Error creating table: %v", err)
+	}
+
+	storage, err := storagegenericport.NewPostgresGenericStorage[string, conformanceObject](pool,
+		storagegenericport.PostgresTable[conformanceObject]{
+			Name:     "conformance_objects",
+			IDColumn: "id",
+			Columns:  []string{"id", "name"},
+			Values:   func(o *conformanceObject) []any { return []any{o.ID, o.Name} },
+			Scan: func(row pgx.Row) (*conformanceObject, error) {
+				var o conformanceObject
+				return &o, row.Scan(&o.ID, &o.Name)
+			},
+		})
+	if err != nil {
+		t.Fatalf("Error creating storage: %v", err)
+	}
+	return pool, storage
+}
+
+func truncatePostgresTestTable(t *testing.T, pool *pgxpool.Pool) {
+	t.Helper()
+	if _, err := pool.Exec(context.Background(), "TRUNCATE conformance_objects"); err != nil {
+		t.Fatalf("Error truncating table: %v", err)
+	}
+}
+
+// newMongoTestCollection connects to TEST_MONGODB_URI, skips the test if it isn't set
+func newMongoTestCollection(t *testing.T) (*mongo.Client, *mongo.Collection) {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI is not set")
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("Error connecting to mongodb: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+
+	return client, client.Database("conformance").Collection("objects")
+}
+
+func dropMongoTestCollection(t *testing.T, collection *mongo.Collection) {
+	t.Helper()
+	if err := collection.Drop(context.Background()); err != nil {
+		t.Fatalf("Error dropping collection: %v", err)
+	}
+}
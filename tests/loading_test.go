@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/loading"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/lru"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/pkgportstest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newLoadingCache(opts loading.Options) *loading.Cache[string, int] {
+	return loading.New[string, int](lru.NewCacheLRUInMemory[string, loading.Entry[int]](100), opts)
+}
+
+func TestConformanceLoadingCache(t *testing.T) {
+	pkgportstest.RunCacheSuite(t, pkgportstest.CacheSuite[string, int]{
+		NewCache: func(t *testing.T, capacity int) pkgports.Cache[string, int] {
+			return loading.New[string, int](lru.NewCacheLRUInMemory[string, loading.Entry[int]](capacity), loading.Options{})
+		},
+		NewKey:   func(n int) string { return fmt.Sprintf("key%d", n) },
+		NewValue: func(n int) int { return n },
+		LRU:      true,
+	})
+}
+
+func TestLoadingCacheCollapsesConcurrentLoads(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newLoadingCache(loading.Options{})
+
+	var loads atomic.Int64
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (int, error) {
+		loads.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 100)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = cache.GetOrLoad(ctx, "hot", loader)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads.Load() != 1 {
+		t.Errorf("Expected 1 load, got %d", loads.Load())
+	}
+	for i, result := range results {
+		if result != 42 {
+			t.Fatalf("Expected caller %d to get 42, got %d", i, result)
+		}
+	}
+	if value, found, _ := cache.Get(ctx, "hot"); !found || value != 42 {
+		t.Errorf("Expected the loaded value to be cached, got %d (found %v)", value, found)
+	}
+}
+
+func TestLoadingCacheServesStaleWhileRefreshing(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newLoadingCache(loading.Options{SoftTTL: 20 * time.Millisecond})
+	_ = cache.Set(ctx, "order", 1)
+	time.Sleep(30 * time.Millisecond)
+
+	refreshed := make(chan struct{})
+	value, err := cache.GetOrLoad(ctx, "order", func(ctx context.Context, key string) (int, error) {
+		defer close(refreshed)
+		return 2, nil
+	})
+	if err != nil || value != 1 {
+		t.Fatalf("Expected the stale value 1 right away, got %d (%v)", value, err)
+	}
+
+	<-refreshed
+	waitUntil(t, "the refreshed value", func() bool {
+		value, _, _ := cache.Get(ctx, "order")
+		return value == 2
+	})
+}
+
+func TestLoadingCacheErrors(t *testing.T) {
+	ctx := newLoggerContext(t)
+	errLoad := errors.New("storage is down")
+
+	var loads atomic.Int64
+	failing := func(ctx context.Context, key string) (int, error) {
+		loads.Add(1)
+		return 0, errLoad
+	}
+
+	t.Run("not cached by default", func(t *testing.T) {
+		loads.Store(0)
+		cache := newLoadingCache(loading.Options{})
+		for range 2 {
+			if _, err := cache.GetOrLoad(ctx, "order", failing); !errors.Is(err, errLoad) {
+				t.Fatalf("Expected the loader error, got %v", err)
+			}
+		}
+		if loads.Load() != 2 {
+			t.Errorf("Expected 2 loads, got %d", loads.Load())
+		}
+		if found, _ := cache.Contains(ctx, "order"); found {
+			t.Error("Expected a failed load to save nothing")
+		}
+	})
+
+	t.Run("cached with ErrorTTL", func(t *testing.T) {
+		loads.Store(0)
+		cache := newLoadingCache(loading.Options{ErrorTTL: 50 * time.Millisecond})
+		for range 2 {
+			if _, err := cache.GetOrLoad(ctx, "order", failing); !errors.Is(err, errLoad) {
+				t.Fatalf("Expected the loader error, got %v", err)
+			}
+		}
+		if loads.Load() != 1 {
+			t.Errorf("Expected the cached error to skip the second load, got %d loads", loads.Load())
+		}
+
+		time.Sleep(60 * time.Millisecond)
+		_, _ = cache.GetOrLoad(ctx, "order", failing)
+		if loads.Load() != 2 {
+			t.Errorf("Expected a load after ErrorTTL, got %d loads", loads.Load())
+		}
+
+		// Set forgets the cached error
+		_ = cache.Set(ctx, "order", 3)
+		_ = cache.Delete(ctx, "order")
+		value, err := cache.GetOrLoad(ctx, "order", func(ctx context.Context, key string) (int, error) {
+			return 4, nil
+		})
+		if err != nil || value != 4 {
+			t.Errorf("Expected 4 after the error was forgotten, got %d (%v)", value, err)
+		}
+	})
+}
+
+func TestLoadingCacheWaiterCancel(t *testing.T) {
+	cache := newLoadingCache(loading.Options{})
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(newLoggerContext(t), 20*time.Millisecond)
+	defer cancel()
+	_, err := cache.GetOrLoad(ctx, "slow", func(ctx context.Context, key string) (int, error) {
+		<-release
+		return 1, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the waiter to stop with its ctx, got %v", err)
+	}
+}
+
+func TestLoadingCacheRefreshErrorNotCached(t *testing.T) {
+	ctx := newLoggerContext(t)
+	errLoad := errors.New("storage is down")
+	cache := newLoadingCache(loading.Options{TTL: 60 * time.Millisecond, SoftTTL: 10 * time.Millisecond, ErrorTTL: time.Hour})
+	_ = cache.Set(ctx, "order", 1)
+	time.Sleep(20 * time.Millisecond)
+
+	refreshed := make(chan struct{})
+	value, err := cache.GetOrLoad(ctx, "order", func(ctx context.Context, key string) (int, error) {
+		defer close(refreshed)
+		return 0, errLoad
+	})
+	if err != nil || value != 1 {
+		t.Fatalf("Expected the stale value 1 right away, got %d (%v)", value, err)
+	}
+	<-refreshed
+
+	// the failed refresh doesn't hide the next load after the value expires
+	time.Sleep(60 * time.Millisecond)
+	value, err = cache.GetOrLoad(ctx, "order", func(ctx context.Context, key string) (int, error) {
+		return 2, nil
+	})
+	if err != nil || value != 2 {
+		t.Errorf("Expected the error of the refresh not to be cached, got %d (%v)", value, err)
+	}
+}
+
+func TestLoadingCacheLoaderPanic(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newLoadingCache(loading.Options{})
+
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.GetOrLoad(ctx, "order", func(ctx context.Context, key string) (int, error) {
+				<-release
+				panic("broken loader")
+			})
+			if !errors.Is(err, loading.ErrLoaderPanic) {
+				t.Errorf("Expected ErrLoaderPanic for every waiter, got %v", err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// the panicked load is finished, the next one starts
+	value, err := cache.GetOrLoad(ctx, "order", func(ctx context.Context, key string) (int, error) {
+		return 1, nil
+	})
+	if err != nil || value != 1 {
+		t.Errorf("Expected a new load after the panic, got %d (%v)", value, err)
+	}
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/linkedlist"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/lru"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/sharded"
+	"math/rand/v2"
+	"testing"
+)
+
+var benchmarkCapacities = []int{1_000, 10_000, 100_000}
+
+// newBenchmarkContext has a logger, otherwise every miss and eviction creates a new one
+func newBenchmarkContext(b *testing.B) context.Context {
+	b.Helper()
+	ctx, err := logger.New(context.Background())
+	if err != nil {
+		b.Fatalf("Error creating logger for benchmark: %v", err)
+	}
+	return ctx
+}
+
+func filledLRU(b *testing.B, capacity int) *lru.CacheLRUInMemory[int, int] {
+	b.Helper()
+	ctx := newBenchmarkContext(b)
+	cache := lru.NewCacheLRUInMemory[int, int](capacity)
+	for i := 0; i < capacity; i++ {
+		if err := cache.Set(ctx, i, i); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+	return cache
+}
+
+// BenchmarkCacheLRUInMemoryGet hits keys all over the list, the time must not grow with capacity
+func BenchmarkCacheLRUInMemoryGet(b *testing.B) {
+	ctx := newBenchmarkContext(b)
+	for _, capacity := range benchmarkCapacities {
+		b.Run(fmt.Sprintf("capacity=%d", capacity), func(b *testing.B) {
+			cache := filledLRU(b, capacity)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := cache.Get(ctx, i*7919%capacity); err != nil {
+					b.Fatalf("Get failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCacheLRUInMemorySetEvict inserts new keys into a full cache, every Set evicts
+func BenchmarkCacheLRUInMemorySetEvict(b *testing.B) {
+	ctx := newBenchmarkContext(b)
+	for _, capacity := range benchmarkCapacities {
+		b.Run(fmt.Sprintf("capacity=%d", capacity), func(b *testing.B) {
+			cache := filledLRU(b, capacity)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := cache.Set(ctx, capacity+i, i); err != nil {
+					b.Fatalf("Set failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCacheLRUInMemoryParallel mixes 90% reads and 10% writes from all procs
+func BenchmarkCacheLRUInMemoryParallel(b *testing.B) {
+	ctx := newBenchmarkContext(b)
+	for _, capacity := range benchmarkCapacities {
+		b.Run(fmt.Sprintf("capacity=%d", capacity), func(b *testing.B) {
+			cache := filledLRU(b, capacity)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := i * 7919 % (capacity * 2)
+					if i%10 == 0 {
+						_ = cache.Set(ctx, key, i)
+					} else {
+						_, _, _ = cache.Get(ctx, key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkLinkedListHit is the hit path of the previous design (GetIndex + MoveToFirst), O(n) per hit
+func BenchmarkLinkedListHit(b *testing.B) {
+	equal := func(a, b int) bool { return a == b }
+	for _, capacity := range benchmarkCapacities {
+		b.Run(fmt.Sprintf("capacity=%d", capacity), func(b *testing.B) {
+			list := linkedlist.NewLinkedList[int]()
+			for i := 0; i < capacity; i++ {
+				if err := list.InsertLast(i); err != nil {
+					b.Fatalf("InsertLast failed: %v", err)
+				}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				index, err := list.GetIndex(i*7919%capacity, equal)
+				if err != nil {
+					b.Fatalf("GetIndex failed: %v", err)
+				}
+				if err = list.MoveToFirst(index); err != nil {
+					b.Fatalf("MoveToFirst failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkShardedCacheParallel is BenchmarkCacheLRUInMemoryParallel over 16 shards
+func BenchmarkShardedCacheParallel(b *testing.B) {
+	ctx := newBenchmarkContext(b)
+	for _, globalLRU := range []bool{false, true} {
+		for _, capacity := range benchmarkCapacities {
+			b.Run(fmt.Sprintf("global=%v/capacity=%d", globalLRU, capacity), func(b *testing.B) {
+				cache := sharded.NewCache[int, int](sharded.Options{Capacity: capacity, GlobalLRU: globalLRU})
+				for i := 0; i < capacity; i++ {
+					_ = cache.Set(ctx, i, i)
+				}
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := 0
+					for pb.Next() {
+						key := i * 7919 % (capacity * 2)
+						if i%10 == 0 {
+							_ = cache.Set(ctx, key, i)
+						} else {
+							_, _, _ = cache.Get(ctx, key)
+						}
+						i++
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkMemoryCachePolicies is a read-through of a zipf-like key stream, hit ratio is reported
+func BenchmarkMemoryCachePolicies(b *testing.B) {
+	ctx := newBenchmarkContext(b)
+	const capacity = 10_000
+	for _, policy := range memoryPolicies {
+		b.Run(string(policy), func(b *testing.B) {
+			cache := newMemoryCache[int, int](b, capacity, policy)
+			rng := rand.New(rand.NewPCG(1, 1))
+			zipf := rand.NewZipf(rng, 1.1, 1, capacity*100)
+			hits := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := int(zipf.Uint64())
+				if _, found, _ := cache.Get(ctx, key); found {
+					hits++
+				} else {
+					_ = cache.Set(ctx, key, key)
+				}
+			}
+			b.ReportMetric(float64(hits)/float64(b.N), "hits/op")
+		})
+	}
+}
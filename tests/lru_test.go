@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
 	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/lru"
+	"sync"
 	"testing"
 )
 
@@ -143,7 +144,7 @@ func TestCacheSetExceedCapacityLRUEviction(t *testing.T) {
 	}
 
 	// Now the state is: key1, key2
-	keysList = cache.GetKeys()
+	keysList, _ = cache.GetKeys(ctx)
 	if keysList[0] != "key1" || keysList[1] != "key2" {
 		t.Errorf("After read key1 again, keys to be listed as key1, key2, got %v", keysList)
 	}
@@ -155,7 +156,7 @@ func TestCacheSetExceedCapacityLRUEviction(t *testing.T) {
 	}
 
 	// Now the state is: key3, key1
-	keysList = cache.GetKeys()
+	keysList, _ = cache.GetKeys(ctx)
 	if keysList[0] != "key3" || keysList[1] != "key1" {
 		t.Errorf("After saving key3, keys to be listed as key3, key1, got %v", keysList)
 	}
@@ -354,7 +355,7 @@ func TestKeysOrder(t *testing.T) {
 		t.Fatalf("Set failed: %v", err)
 	}
 
-	keysOrder := cache.GetKeys()
+	keysOrder, _ := cache.GetKeys(ctx)
 	if len(keysOrder) != 3 {
 		t.Errorf("GetKeys failed: expected len 3, got: %d", len(keysOrder))
 	}
@@ -381,3 +382,37 @@ func TestKeysOrder(t *testing.T) {
 		t.Errorf("LeastUsedKey failed: incorrect order, expected key1, got: %v", key)
 	}
 }
+
+func TestCacheConcurrentAccess(t *testing.T) {
+	// run with -race: Get reorders the list, so readers mustn't share a read lock with it
+	cache := lru.NewCacheLRUInMemory[int, int](64)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (worker*31 + i) % 100
+				switch i % 5 {
+				case 0:
+					_ = cache.Set(ctx, key, i)
+				case 1:
+					_ = cache.Delete(ctx, key)
+				case 2:
+					_, _ = cache.GetKeys(ctx)
+				default:
+					_, _, _ = cache.Get(ctx, key)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	keys, _ := cache.GetKeys(ctx)
+	amount, _ := cache.GetKeysAmount(ctx)
+	if len(keys) != amount || amount > cache.GetCapacity() {
+		t.Errorf("Expected %d keys within capacity %d, got %d", amount, cache.GetCapacity(), len(keys))
+	}
+}
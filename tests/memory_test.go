@@ -0,0 +1,415 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/memory"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/pkgportstest"
+	"testing"
+	"time"
+)
+
+var memoryPolicies = []memory.Policy{
+	memory.PolicyLRU, memory.PolicyLFU, memory.PolicyARC, memory.Policy2Q, memory.PolicyTinyLFU,
+}
+
+func newMemoryCache[K comparable, V any](t testing.TB, capacity int, policy memory.Policy) *memory.Cache[K, V] {
+	t.Helper()
+	cache, err := memory.New[K, V](memory.Options{Capacity: capacity, Policy: policy})
+	if err != nil {
+		t.Fatalf("Error creating %s cache: %v", policy, err)
+	}
+	return cache
+}
+
+func TestConformanceMemoryCache(t *testing.T) {
+	for _, policy := range memoryPolicies {
+		t.Run(string(policy), func(t *testing.T) {
+			pkgportstest.RunCacheSuite(t, pkgportstest.CacheSuite[string, int]{
+				NewCache: func(t *testing.T, capacity int) pkgports.Cache[string, int] {
+					return newMemoryCache[string, int](t, capacity, policy)
+				},
+				NewKey:   func(n int) string { return fmt.Sprintf("key%d", n) },
+				NewValue: func(n int) int { return n },
+				LRU:      policy == memory.PolicyLRU,
+			})
+		})
+	}
+}
+
+func TestMemoryCacheUnknownPolicy(t *testing.T) {
+	if _, err := memory.New[string, int](memory.Options{Capacity: 1, Policy: "mru"}); !errors.Is(err, memory.ErrUnknownPolicy) {
+		t.Errorf("Expected ErrUnknownPolicy, got %v", err)
+	}
+	if _, err := memory.ParsePolicy("fifo"); !errors.Is(err, memory.ErrUnknownPolicy) {
+		t.Errorf("Expected ErrUnknownPolicy from ParsePolicy, got %v", err)
+	}
+	if policy, err := memory.ParsePolicy("tinylfu"); err != nil || policy != memory.PolicyTinyLFU {
+		t.Errorf("Expected PolicyTinyLFU, got %v (%v)", policy, err)
+	}
+
+	cache, err := memory.New[string, int](memory.Options{Capacity: 1})
+	if err != nil || cache.Policy() != memory.PolicyLRU {
+		t.Errorf("Expected PolicyLRU by default, got %v", err)
+	}
+}
+
+// TestMemoryCacheScanResistance reads a hot set many times, then scans keys that are read once.
+// LRU loses the hot set, the other policies must keep most of it
+func TestMemoryCacheScanResistance(t *testing.T) {
+	const (
+		capacity = 100
+		hotKeys  = 50
+		scanKeys = 1000
+	)
+
+	survived := make(map[memory.Policy]int)
+	for _, policy := range memoryPolicies {
+		ctx := newLoggerContext(t)
+		cache := newMemoryCache[string, int](t, capacity, policy)
+
+		// read-through: get, set on miss
+		read := func(key string) {
+			if _, found, _ := cache.Get(ctx, key); !found {
+				if err := cache.Set(ctx, key, 1); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+			}
+		}
+
+		for round := 0; round < 5; round++ {
+			for i := 0; i < hotKeys; i++ {
+				read(fmt.Sprintf("hot%d", i))
+			}
+		}
+		for i := 0; i < scanKeys; i++ {
+			read(fmt.Sprintf("scan%d", i))
+		}
+
+		for i := 0; i < hotKeys; i++ {
+			if found, _ := cache.Contains(ctx, fmt.Sprintf("hot%d", i)); found {
+				survived[policy]++
+			}
+		}
+		if amount, _ := cache.GetKeysAmount(ctx); amount != capacity {
+			t.Errorf("%s: expected a full cache of %d keys, got %d", policy, capacity, amount)
+		}
+	}
+
+	t.Logf("hot keys that survived the scan: %v", survived)
+	if survived[memory.PolicyLRU] != 0 {
+		t.Errorf("Expected LRU to lose the hot set, %d keys survived", survived[memory.PolicyLRU])
+	}
+	for _, policy := range memoryPolicies[1:] {
+		if survived[policy] < hotKeys*9/10 {
+			t.Errorf("%s: expected at least %d of %d hot keys to survive the scan, got %d",
+				policy, hotKeys*9/10, hotKeys, survived[policy])
+		}
+	}
+}
+
+func TestMemoryCacheLFUEvictsLeastFrequent(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newMemoryCache[string, int](t, 3, memory.PolicyLFU)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = cache.Set(ctx, key, 1)
+	}
+	// a: 3 uses, b: 2 uses, c: 1 use
+	_, _, _ = cache.Get(ctx, "a")
+	_, _, _ = cache.Get(ctx, "a")
+	_, _, _ = cache.Get(ctx, "b")
+
+	if key, _ := cache.LeastUsedKey(); key != "c" {
+		t.Errorf("Expected c to be the next victim, got %s", key)
+	}
+	_ = cache.Set(ctx, "d", 1)
+	if found, _ := cache.Contains(ctx, "c"); found {
+		t.Error("Expected c to be evicted as the least frequently used")
+	}
+
+	keys, _ := cache.GetKeys(ctx)
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "d" {
+		t.Errorf("Expected keys from the most frequent a, b, d, got %v", keys)
+	}
+}
+
+func TestMemoryCacheARCRemembersEvicted(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newMemoryCache[string, int](t, 2, memory.PolicyARC)
+
+	_ = cache.Set(ctx, "a", 1)
+	_ = cache.Set(ctx, "b", 1)
+	_ = cache.Set(ctx, "c", 1) // a is evicted and remembered
+
+	// a comes back: it's a recency hit of an evicted key, so it's protected from the one-time keys
+	_ = cache.Set(ctx, "a", 1)
+	_ = cache.Set(ctx, "d", 1)
+	_ = cache.Set(ctx, "e", 1)
+
+	if found, _ := cache.Contains(ctx, "a"); !found {
+		t.Error("Expected a key that came back after eviction to outlive one-time keys")
+	}
+}
+
+func TestMemoryCacheDefaultTTL(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache, err := memory.New[string, int](memory.Options{Capacity: 10, TTL: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error creating cache: %v", err)
+	}
+
+	_ = cache.Set(ctx, "expiring", 1)
+	_ = cache.SetWithTTL(ctx, "forever", 2, 0)
+	time.Sleep(50 * time.Millisecond)
+
+	if _, found, _ := cache.Get(ctx, "expiring"); found {
+		t.Error("Expected a value saved with Set to expire after Options.TTL")
+	}
+	if _, found, _ := cache.Get(ctx, "forever"); !found {
+		t.Error("Expected SetWithTTL(0) to override Options.TTL")
+	}
+	if keys, _ := cache.GetKeys(ctx); len(keys) != 1 || keys[0] != "forever" {
+		t.Errorf("Expected only the not expired key, got %v", keys)
+	}
+}
+
+func TestMemoryCacheJanitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(newLoggerContext(t))
+	cache, err := memory.New[string, int](memory.Options{Capacity: 10, TTL: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error creating cache: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		_ = cache.Set(ctx, fmt.Sprint(i), i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cache.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	// nothing is read, the janitor removes expired entries on its own
+	deadline := time.Now().Add(time.Second)
+	for cache.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected the janitor to remove expired entries, %d left", cache.Len())
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the janitor to stop when ctx is done")
+	}
+}
+
+func TestMemoryCacheExpiredKeysAreSkipped(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newMemoryCache[string, int](t, 2, memory.PolicyLRU)
+
+	_ = cache.Set(ctx, "old", 1)
+	_ = cache.SetWithTTL(ctx, "expiring", 2, 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+
+	if key, err := cache.MostUsedKey(); err != nil || key != "old" {
+		t.Errorf("Expected MostUsedKey to skip the expired key, got %v (%v)", key, err)
+	}
+	if key, err := cache.LeastUsedKey(); err != nil || key != "old" {
+		t.Errorf("Expected LeastUsedKey to skip the expired key, got %v (%v)", key, err)
+	}
+
+	// the overflow removes the expired key instead of the least used one
+	_ = cache.SetWithTTL(ctx, "expiring", 2, 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	_ = cache.Set(ctx, "new", 3)
+	if found, _ := cache.Contains(ctx, "old"); !found {
+		t.Error("Expected the expired key to be evicted before the least used one")
+	}
+	if amount, _ := cache.GetKeysAmount(ctx); amount != 2 {
+		t.Errorf("Expected 2 keys, got %d", amount)
+	}
+}
+
+func TestMemoryCacheOnEvictReasons(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newMemoryCache[string, int](t, 2, memory.PolicyLRU)
+
+	type evicted struct {
+		key    string
+		value  int
+		reason memory.EvictReason
+	}
+	var got []evicted
+	cache.OnEvict(func(key string, value int, reason memory.EvictReason) {
+		got = append(got, evicted{key, value, reason})
+	})
+
+	_ = cache.Set(ctx, "a", 1)
+	_ = cache.Set(ctx, "a", 2) // replaced
+	_ = cache.Set(ctx, "b", 3)
+	_ = cache.Set(ctx, "c", 4) // a is evicted by capacity
+	_ = cache.Delete(ctx, "b") // deleted
+	_ = cache.SetWithTTL(ctx, "d", 5, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	_, _, _ = cache.Get(ctx, "d") // expired
+	_ = cache.Clear(ctx)          // c is deleted
+
+	expected := []evicted{
+		{"a", 1, memory.EvictReplaced},
+		{"a", 2, memory.EvictCapacity},
+		{"b", 3, memory.EvictDeleted},
+		{"d", 5, memory.EvictExpired},
+		{"c", 4, memory.EvictDeleted},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected evictions %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Eviction %d: expected %v, got %v", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestMemoryCacheOnEvictOutsideLock(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newMemoryCache[string, int](t, 1, memory.PolicyLRU)
+
+	// the hook uses the cache, it would deadlock under the lock
+	cache.OnEvict(func(key string, value int, reason memory.EvictReason) {
+		if reason == memory.EvictCapacity && key == "a" {
+			if found, _ := cache.Contains(ctx, key); found {
+				t.Errorf("Expected evicted key %s to be gone when the hook runs", key)
+			}
+			_ = cache.Set(ctx, "written back "+key, value)
+		}
+	})
+
+	_ = cache.Set(ctx, "a", 1)
+	_ = cache.Set(ctx, "b", 2)
+
+	if found, _ := cache.Contains(ctx, "written back a"); !found {
+		t.Error("Expected the hook to write the evicted value back")
+	}
+}
+
+func TestMemoryCacheOnEvictPanicIsRecovered(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := newMemoryCache[string, int](t, 10, memory.PolicyLRU)
+
+	called := 0
+	cache.OnEvict(func(string, int, memory.EvictReason) { panic("broken hook") })
+	cache.OnEvict(func(string, int, memory.EvictReason) { called++ })
+
+	_ = cache.Set(ctx, "a", 1)
+	if err := cache.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if called != 1 {
+		t.Errorf("Expected the next hook to run after a panic, called %d times", called)
+	}
+
+	// the lock is released and the cache still works
+	_ = cache.Set(ctx, "b", 2)
+	if value, found, _ := cache.Get(ctx, "b"); !found || value != 2 {
+		t.Errorf("Expected the cache to work after a hook panic, got %d (found %v)", value, found)
+	}
+}
+
+func TestMemoryCacheMaxCost(t *testing.T) {
+	ctx := newLoggerContext(t)
+	for _, policy := range memoryPolicies {
+		t.Run(string(policy), func(t *testing.T) {
+			cache, err := memory.New[string, string](memory.Options{
+				Policy:  policy,
+				MaxCost: 10,
+				Cost:    func(value any) int64 { return int64(len(value.(string))) },
+			})
+			if err != nil {
+				t.Fatalf("Error creating cache: %v", err)
+			}
+
+			for i := range 20 {
+				_ = cache.Set(ctx, fmt.Sprintf("key%d", i), "abc")
+				if cost := cache.Cost(); cost > 10 {
+					t.Fatalf("Expected cost <= 10 after %d sets, got %d", i+1, cost)
+				}
+			}
+			if amount := cache.Len(); amount != 3 {
+				t.Errorf("Expected 3 values of cost 3 to fit into 10, got %d", amount)
+			}
+
+			// a bigger value evicts more entries
+			_ = cache.Set(ctx, "big", "abcdefgh")
+			if cost := cache.Cost(); cost > 10 {
+				t.Errorf("Expected cost <= 10 after a big value, got %d", cost)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheMaxCostRejectsTooCostly(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache, err := memory.New[string, []byte](memory.Options{Capacity: 100, MaxCost: 64})
+	if err != nil {
+		t.Fatalf("Error creating cache: %v", err)
+	}
+
+	var reasons []memory.EvictReason
+	cache.OnEvict(func(_ string, _ []byte, reason memory.EvictReason) {
+		reasons = append(reasons, reason)
+	})
+
+	_ = cache.Set(ctx, "a", []byte("small"))
+	if err = cache.Set(ctx, "a", make([]byte, 100)); !errors.Is(err, memory.ErrCostExceeded) {
+		t.Fatalf("Expected ErrCostExceeded, got %v", err)
+	}
+	if found, _ := cache.Contains(ctx, "a"); found {
+		t.Error("Expected the previous value to be removed")
+	}
+	if len(reasons) != 1 || reasons[0] != memory.EvictReplaced {
+		t.Errorf("Expected the previous value to be replaced, got %v", reasons)
+	}
+	if cost := cache.Cost(); cost != 0 {
+		t.Errorf("Expected cost 0, got %d", cost)
+	}
+}
+
+func TestDefaultCost(t *testing.T) {
+	type report struct {
+		ID    int64
+		Title string
+		Tags  []string
+		Owner *struct{ Name string }
+	}
+
+	short, long := memory.DefaultCost("a"), memory.DefaultCost(string(make([]byte, 1000)))
+	if long-short != 999 {
+		t.Errorf("Expected strings to cost their length, got %d and %d", short, long)
+	}
+	if cost := memory.DefaultCost(make([]int64, 100)); cost < 800 {
+		t.Errorf("Expected 100 int64 to cost at least 800, got %d", cost)
+	}
+
+	empty := memory.DefaultCost(report{})
+	full := memory.DefaultCost(report{
+		Title: "quarterly", Tags: []string{"sales", "q3"}, Owner: &struct{ Name string }{Name: "danis"},
+	})
+	if full <= empty {
+		t.Errorf("Expected referenced data to be counted, got %d for an empty and %d for a full struct", empty, full)
+	}
+
+	type node struct{ next *node }
+	cyclic := &node{}
+	cyclic.next = cyclic
+	if cost := memory.DefaultCost(cyclic); cost <= 0 {
+		t.Errorf("Expected a positive cost of a cyclic value, got %d", cost)
+	}
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/negative"
+	cachegenericport "github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/genericport"
+	"testing"
+	"time"
+)
+
+// compile-time check of the caches that remember not-found results
+var _ genericports.NotFoundCachePort[string, conformanceObject] = (*cachegenericport.RedisGenericCache[string, conformanceObject])(nil)
+
+func TestConformanceNegativeStorage(t *testing.T) {
+	genericportstest.RunStorageSuite(t, genericportstest.StorageSuite[string, conformanceObject]{
+		NewStorage: func(t *testing.T) genericports.GenericStoragePort[string, conformanceObject] {
+			server := miniredis.RunT(t)
+			return negative.NewStorage[string, conformanceObject](
+				genericportstest.NewInMemoryStorage[string, conformanceObject](),
+				cachegenericport.NewRedisGenericCache[string, conformanceObject](server.Addr(), "", 0, 60000),
+				negative.Options{},
+			)
+		},
+		NewObject: newConformanceObject,
+		Modify:    modifyConformanceObject,
+	})
+}
+
+func TestRedisGenericCacheNotFound(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cache := cachegenericport.NewRedisGenericCache[string, conformanceObject](server.Addr(), "", 0, 60000)
+	object := newConformanceObject(1)
+
+	if err := cache.SaveNotFound(ctx, object.ID, time.Second); err != nil {
+		t.Fatalf("SaveNotFound failed: %v", err)
+	}
+	_, err := cache.GetObjectByID(ctx, object.ID)
+	if !errors.Is(err, genericports.ErrCachedNotFound) || !errors.Is(err, genericports.ErrNotFound) {
+		t.Fatalf("Expected ErrCachedNotFound, got %v", err)
+	}
+
+	server.FastForward(time.Second)
+	if found, err := cache.GetObjectByID(ctx, object.ID); found != nil || err != nil {
+		t.Fatalf("Expected an expired record to be a plain miss, got %v, %v", found, err)
+	}
+
+	_ = cache.SaveNotFound(ctx, object.ID, time.Second)
+	_, _ = cache.SaveObject(ctx, object)
+	if found, err := cache.GetObjectByID(ctx, object.ID); err != nil || found == nil {
+		t.Fatalf("Expected SaveObject to replace the record, got %v, %v", found, err)
+	}
+}
+
+func TestNegativeStorage(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	storage := &countingStorage{InMemoryStorage: genericportstest.NewInMemoryStorage[string, conformanceObject]()}
+	cache := cachegenericport.NewRedisGenericCache[string, conformanceObject](server.Addr(), "", 0, 60000)
+	orders := negative.NewStorage[string, conformanceObject](storage, cache, negative.Options{TTL: 10 * time.Second})
+	object := newConformanceObject(1)
+
+	if _, err := orders.GetObjectByID(ctx, object.ID); !errors.Is(err, genericports.ErrNotFound) || errors.Is(err, genericports.ErrCachedNotFound) {
+		t.Fatalf("Expected a fresh ErrNotFound, got %v", err)
+	}
+	for range 10 {
+		if _, err := orders.GetObjectByID(ctx, object.ID); !errors.Is(err, genericports.ErrCachedNotFound) {
+			t.Fatalf("Expected ErrCachedNotFound, got %v", err)
+		}
+	}
+	if storage.reads != 1 {
+		t.Errorf("Expected 1 storage read, got %d", storage.reads)
+	}
+
+	if _, err := orders.CreateObject(ctx, object); err != nil {
+		t.Fatalf("CreateObject failed: %v", err)
+	}
+	if found, err := orders.GetObjectByID(ctx, object.ID); err != nil || found.ID != object.ID {
+		t.Fatalf("Expected the created object, got %v, %v", found, err)
+	}
+
+	if err := orders.DeleteObject(ctx, object.ID); err != nil {
+		t.Fatalf("DeleteObject failed: %v", err)
+	}
+	storage.reads = 0
+	if _, err := orders.GetObjectByID(ctx, object.ID); !errors.Is(err, genericports.ErrCachedNotFound) {
+		t.Fatalf("Expected the deleted object to be cached as not found, got %v", err)
+	}
+	if storage.reads != 0 {
+		t.Errorf("Expected no storage reads, got %d", storage.reads)
+	}
+
+	server.FastForward(10 * time.Second)
+	if _, err := orders.GetObjectByID(ctx, object.ID); errors.Is(err, genericports.ErrCachedNotFound) {
+		t.Errorf("Expected the record to expire after TTL, got %v", err)
+	}
+}
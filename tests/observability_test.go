@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/logger"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/observability"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/lru"
+	"testing"
+	"time"
+)
+
+// slowNotFoundCache is a GenericCachePort that never finds anything and is slow at saving
+type slowNotFoundCache struct {
+	delay time.Duration
+}
+
+func (c slowNotFoundCache) GetObjectByID(_ context.Context, id string) (*plainObject, error) {
+	return nil, fmt.Errorf("%w: id '%s'", genericports.ErrNotFound, id)
+}
+
+func (c slowNotFoundCache) SaveObject(_ context.Context, object *plainObject) (*plainObject, error) {
+	time.Sleep(c.delay)
+	return object, nil
+}
+
+func (c slowNotFoundCache) DeleteObject(context.Context, string) error {
+	return context.DeadlineExceeded
+}
+
+func TestObservabilityCacheCounters(t *testing.T) {
+	ctx, err := logger.New(context.Background())
+	if err != nil {
+		t.Fatalf("Error creating logger for test: %v", err)
+	}
+
+	counters := observability.NewCounters()
+	cache := observability.NewCache(lru.NewCacheLRUInMemory[string, int](2), observability.Options{
+		Name:     "test_cache",
+		Recorder: counters,
+	})
+
+	if err = cache.Set(ctx, "key1", 1); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, _, err = cache.Get(ctx, "key1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, _, err = cache.Get(ctx, "key2"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// the decorated value must still work as a cache
+	if amount, _ := cache.GetKeysAmount(ctx); amount != 1 {
+		t.Errorf("Expected 1 key, got %d", amount)
+	}
+
+	snapshot := counters.Snapshot()
+	if snapshot["test_cache.set"].Calls != 1 {
+		t.Errorf("Expected 1 set call, got %d", snapshot["test_cache.set"].Calls)
+	}
+	if snapshot["test_cache.get"].Calls != 2 {
+		t.Errorf("Expected 2 get calls, got %d", snapshot["test_cache.get"].Calls)
+	}
+
+	counters.Reset()
+	if len(counters.Snapshot()) != 0 {
+		t.Error("Expected no counters after reset")
+	}
+}
+
+func TestObservabilityErrorKindsAndSlowCalls(t *testing.T) {
+	counters := observability.NewCounters()
+	cache := observability.NewCachePort[string, plainObject](slowNotFoundCache{delay: 20 * time.Millisecond},
+		observability.Options{
+			Name:          "test_port",
+			SlowThreshold: 5 * time.Millisecond,
+			Recorder:      counters,
+			QuietKinds:    []string{observability.KindNotFound},
+		})
+
+	// no logger in ctx - decorator must create one
+	ctx := context.Background()
+
+	_, _ = cache.GetObjectByID(ctx, "1")
+	_, _ = cache.SaveObject(ctx, &plainObject{ID: "1"})
+	_ = cache.DeleteObject(ctx, "1")
+
+	snapshot := counters.Snapshot()
+	if snapshot["test_port.get_object_by_id"].Errors[observability.KindNotFound] != 1 {
+		t.Errorf("Expected not_found error to be counted, got %v", snapshot["test_port.get_object_by_id"].Errors)
+	}
+	if snapshot["test_port.delete_object"].Errors[observability.KindTimeout] != 1 {
+		t.Errorf("Expected timeout error to be counted, got %v", snapshot["test_port.delete_object"].Errors)
+	}
+	if snapshot["test_port.save_object"].Slow != 1 {
+		t.Errorf("Expected slow save to be counted, got %d", snapshot["test_port.save_object"].Slow)
+	}
+	if snapshot["test_port.get_object_by_id"].Slow != 0 {
+		t.Error("Expected fast get not to be slow")
+	}
+}
@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/outbox"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/postgres"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errOutboxWrite = errors.New("outbox test write error")
+
+// fakeMessageWriter - impl outbox.MessageWriter, remembers batches and fails messages chosen by fail
+type fakeMessageWriter struct {
+	mu      sync.Mutex
+	batches [][]kafka.Message
+	fail    func(message kafka.Message) bool
+}
+
+func (w *fakeMessageWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, msgs)
+
+	writeErrors := make(kafka.WriteErrors, len(msgs))
+	failed := false
+	for i, msg := range msgs {
+		if w.fail != nil && w.fail(msg) {
+			writeErrors[i] = errOutboxWrite
+			failed = true
+		}
+	}
+	if failed {
+		return writeErrors
+	}
+	return nil
+}
+
+// keys returns message keys of every batch
+func (w *fakeMessageWriter) keys() [][]string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := make([][]string, len(w.batches))
+	for i, batch := range w.batches {
+		for _, msg := range batch {
+			result[i] = append(result[i], string(msg.Key))
+		}
+	}
+	return result
+}
+
+func TestOutboxConfigRetryBackoff(t *testing.T) {
+	cfg := outbox.Config{RetryBackoffMs: 100, MaxRetryBackoffMs: 1000}
+	for attempts, expected := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		50: time.Second,
+	} {
+		if backoff := cfg.RetryBackoff(attempts); backoff != expected {
+			t.Errorf("Expected backoff %v after %d attempts, got %v", expected, attempts, backoff)
+		}
+	}
+
+	if backoff := (outbox.Config{}).RetryBackoff(1); backoff != time.Second {
+		t.Errorf("Expected the default backoff of 1s, got %v", backoff)
+	}
+}
+
+func TestRelayRunWithZeroConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(newLoggerContext(t))
+	cancel()
+
+	// zero intervals used to panic in time.NewTicker
+	relay := outbox.NewRelay(nil, &fakeMessageWriter{}, outbox.Config{})
+	relay.Run(ctx)
+}
+
+// outboxRow is the state of an outbox event after a poll
+type outboxRow struct {
+	attempts      int
+	lastError     *string
+	sent          bool
+	failed        bool
+	nextAttemptAt time.Time
+}
+
+// TestRelayPoll requires TEST_POSTGRES_DSN, see TestConformancePostgresGenericStorage
+func TestRelayPoll(t *testing.T) {
+	pool := newPostgresTestPool(t)
+	cfg := outbox.Config{Table: "outbox_relay_test", RetryBackoffMs: 60000, MaxRetryBackoffMs: 600000}
+
+	setup := func(t *testing.T, cfg outbox.Config, events ...outbox.Event) {
+		t.Helper()
+		ctx := context.Background()
+		if _, err := pool.Exec(ctx, "DROP TABLE IF EXISTS "+cfg.Table); err != nil {
+			t.Fatalf("Error dropping table: %v", err)
+		}
+		if _, err := pool.Exec(ctx, fmt.Sprintf(outbox.Schema, cfg.Table)); err != nil {
+			t.Fatalf("Error creating table: %v", err)
+		}
+		err := postgres.NewTransactor(pool, pgx.TxOptions{}).WithinTransaction(ctx, func(ctx context.Context) error {
+			return outbox.New(cfg).Add(ctx, events...)
+		})
+		if err != nil {
+			t.Fatalf("Error adding events: %v", err)
+		}
+	}
+
+	events := []outbox.Event{
+		{AggregateKey: "a", Topic: "orders", Payload: []byte("a1"), Headers: map[string]string{"type": "created"}},
+		{AggregateKey: "b", Topic: "orders", Payload: []byte("b1")},
+		{AggregateKey: "a", Topic: "orders", Payload: []byte("a2")},
+	}
+
+	t.Run("publish", func(t *testing.T) {
+		ctx := newLoggerContext(t)
+		setup(t, cfg, events...)
+		writer := &fakeMessageWriter{}
+
+		processed, err := outbox.NewRelay(pool, writer, cfg).Poll(ctx)
+		if err != nil || processed != 3 {
+			t.Fatalf("Expected 3 processed events, got %d (%v)", processed, err)
+		}
+		// every round has one event per key, so the order per key is kept
+		if keys := writer.keys(); !reflect.DeepEqual(keys, [][]string{{"a", "b"}, {"a"}}) {
+			t.Errorf("Expected rounds [[a b] [a]], got %v", keys)
+		}
+		first := writer.batches[0][0]
+		if string(first.Value) != "a1" || first.Topic != "orders" ||
+			len(first.Headers) != 1 || first.Headers[0].Key != "type" || string(first.Headers[0].Value) != "created" {
+			t.Errorf("Expected the first event with its headers, got %+v", first)
+		}
+		for id := 1; id <= 3; id++ {
+			if row := readOutboxRow(t, pool, cfg.Table, id); !row.sent || row.attempts != 0 {
+				t.Errorf("Expected event %d to be sent, got %+v", id, row)
+			}
+		}
+
+		if processed, err = outbox.NewRelay(pool, writer, cfg).Poll(ctx); err != nil || processed != 0 {
+			t.Errorf("Expected nothing to publish, got %d (%v)", processed, err)
+		}
+	})
+
+	t.Run("failure postpones the key with backoff", func(t *testing.T) {
+		ctx := newLoggerContext(t)
+		setup(t, cfg, events...)
+		writer := &fakeMessageWriter{fail: func(message kafka.Message) bool { return string(message.Key) == "a" }}
+		relay := outbox.NewRelay(pool, writer, cfg)
+
+		before := time.Now()
+		processed, err := relay.Poll(ctx)
+		if err != nil || processed != 2 {
+			t.Fatalf("Expected 2 processed events (a1 failed, b1 sent), got %d (%v)", processed, err)
+		}
+		if keys := writer.keys(); !reflect.DeepEqual(keys, [][]string{{"a", "b"}}) {
+			t.Errorf("Expected a2 not to be published after a1 failed, got %v", keys)
+		}
+
+		failed := readOutboxRow(t, pool, cfg.Table, 1)
+		if failed.sent || failed.failed || failed.attempts != 1 || failed.lastError == nil {
+			t.Errorf("Expected a1 to be retried later, got %+v", failed)
+		}
+		expected := before.Add(cfg.RetryBackoff(1))
+		if failed.nextAttemptAt.Before(expected.Add(-time.Second)) || failed.nextAttemptAt.After(expected.Add(time.Minute)) {
+			t.Errorf("Expected the next attempt around %v, got %v", expected, failed.nextAttemptAt)
+		}
+		if row := readOutboxRow(t, pool, cfg.Table, 2); !row.sent {
+			t.Errorf("Expected b1 to be sent, got %+v", row)
+		}
+		if row := readOutboxRow(t, pool, cfg.Table, 3); row.sent || row.attempts != 0 {
+			t.Errorf("Expected a2 to wait for a1, got %+v", row)
+		}
+
+		// a1 is postponed, so a2 mustn't overtake it
+		if processed, err = relay.Poll(ctx); err != nil || processed != 0 {
+			t.Errorf("Expected the postponed key to be skipped, got %d (%v)", processed, err)
+		}
+	})
+
+	t.Run("max attempts marks the event as failed", func(t *testing.T) {
+		ctx := newLoggerContext(t)
+		cfg := cfg
+		cfg.MaxAttempts = 1
+		setup(t, cfg, events[0], events[2])
+		writer := &fakeMessageWriter{fail: func(message kafka.Message) bool { return string(message.Value) == "a1" }}
+		relay := outbox.NewRelay(pool, writer, cfg)
+
+		if processed, err := relay.Poll(ctx); err != nil || processed != 1 {
+			t.Fatalf("Expected a1 to fail, got %d (%v)", processed, err)
+		}
+		if row := readOutboxRow(t, pool, cfg.Table, 1); !row.failed || row.attempts != 1 {
+			t.Errorf("Expected a1 to be marked as failed, got %+v", row)
+		}
+
+		// the failed event is skipped, the key goes on
+		if processed, err := relay.Poll(ctx); err != nil || processed != 1 {
+			t.Fatalf("Expected a2 to be published, got %d (%v)", processed, err)
+		}
+		if row := readOutboxRow(t, pool, cfg.Table, 2); !row.sent {
+			t.Errorf("Expected a2 to be sent, got %+v", row)
+		}
+	})
+}
+
+func readOutboxRow(t *testing.T, pool *pgxpool.Pool, table string, id int) outboxRow {
+	t.Helper()
+	var row outboxRow
+	err := pool.QueryRow(context.Background(), fmt.Sprintf(
+		"SELECT attempts, last_error, sent_at IS NOT NULL, failed_at IS NOT NULL, next_attempt_at FROM %s WHERE id = $1", table), id,
+	).Scan(&row.attempts, &row.lastError, &row.sent, &row.failed, &row.nextAttemptAt)
+	if err != nil {
+		t.Fatalf("Error reading outbox event %d: %v", id, err)
+	}
+	return row
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	storagegenericport "github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/storage/genericport"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/types"
+	"sync"
+	"testing"
+)
+
+type patchDelivery struct {
+	City   string `json:"city"`
+	Street string `json:"street,omitempty"`
+}
+
+type patchOrder struct {
+	ID          string             `json:"id"`
+	TrackNumber types.NotEmptyText `json:"track_number"`
+	Comment     string             `json:"comment,omitempty"`
+	Delivery    patchDelivery      `json:"delivery"`
+}
+
+func (o patchOrder) GetUniqueIdentifier() string { return o.ID }
+
+func newPatchOrder() *patchOrder {
+	return &patchOrder{
+		ID:          "order1",
+		TrackNumber: "WB1",
+		Comment:     "leave at the door",
+		Delivery:    patchDelivery{City: "Kazan", Street: "Baumana"},
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	patch, err := genericports.NewMergePatch([]byte(`{"track_number": "WB2", "comment": null, "delivery": {"street": null}}`))
+	if err != nil {
+		t.Fatalf("NewMergePatch failed: %v", err)
+	}
+
+	patched, err := genericports.ApplyPatch(newPatchOrder(), patch)
+	if err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+
+	if patched.TrackNumber != "WB2" {
+		t.Errorf("Expected track number WB2, got %s", patched.TrackNumber)
+	}
+	if patched.Comment != "" {
+		t.Errorf("Expected comment to be removed, got %s", patched.Comment)
+	}
+	// nested objects are merged, not replaced
+	if patched.Delivery.City != "Kazan" || patched.Delivery.Street != "" {
+		t.Errorf("Expected delivery {Kazan, ''}, got %v", patched.Delivery)
+	}
+}
+
+func TestApplyPatchValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{name: "empty not-empty text", patch: `{"track_number": ""}`},
+		{name: "null not-empty text", patch: `{"track_number": null}`},
+		{name: "unknown field", patch: `{"weight": 10}`},
+		{name: "wrong type", patch: `{"comment": 10}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := genericports.NewMergePatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("NewMergePatch failed: %v", err)
+			}
+			if _, err = genericports.ApplyPatch(newPatchOrder(), patch); !errors.Is(err, genericports.ErrInvalidPatch) {
+				t.Errorf("Expected ErrInvalidPatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewMergePatchNotObject(t *testing.T) {
+	for _, data := range []string{`[]`, `"text"`, `null`, `{`} {
+		if _, err := genericports.NewMergePatch([]byte(data)); !errors.Is(err, genericports.ErrInvalidPatch) {
+			t.Errorf("Expected ErrInvalidPatch for %s, got %v", data, err)
+		}
+	}
+}
+
+func TestFieldMaskPatch(t *testing.T) {
+	values := &patchOrder{TrackNumber: "WB3", Delivery: patchDelivery{City: "Moscow", Street: "Arbat"}}
+
+	patch, err := genericports.NewFieldMaskPatch([]string{"track_number", "delivery.city", "comment"}, values)
+	if err != nil {
+		t.Fatalf("NewFieldMaskPatch failed: %v", err)
+	}
+
+	patched, err := genericports.ApplyPatch(newPatchOrder(), patch)
+	if err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+
+	if patched.TrackNumber != "WB3" || patched.Delivery.City != "Moscow" {
+		t.Errorf("Expected masked fields to be copied, got %v", patched)
+	}
+	// not masked
+	if patched.Delivery.Street != "Baumana" || patched.ID != "order1" {
+		t.Errorf("Expected not masked fields to stay, got %v", patched)
+	}
+	// masked but empty in values (omitempty) - removed
+	if patched.Comment != "" {
+		t.Errorf("Expected comment to be removed, got %s", patched.Comment)
+	}
+}
+
+func TestInMemoryPatchObject(t *testing.T) {
+	ctx := context.Background()
+	storage := genericportstest.NewInMemoryStorage[string, patchOrder]()
+
+	if _, err := storage.CreateObject(ctx, newPatchOrder()); err != nil {
+		t.Fatalf("CreateObject failed: %v", err)
+	}
+
+	patch, _ := genericports.NewMergePatch([]byte(`{"comment": "call me"}`))
+	patched, err := storage.PatchObject(ctx, "order1", patch)
+	if err != nil {
+		t.Fatalf("PatchObject failed: %v", err)
+	}
+	if patched.Comment != "call me" || patched.TrackNumber != "WB1" {
+		t.Errorf("Unexpected patched object %v", patched)
+	}
+
+	stored, _ := storage.GetObjectByID(ctx, "order1")
+	if stored.Comment != "call me" {
+		t.Errorf("Expected patch to be stored, got %v", stored)
+	}
+
+	idPatch, _ := genericports.NewMergePatch([]byte(`{"id": "order2"}`))
+	if _, err = storage.PatchObject(ctx, "order1", idPatch); !errors.Is(err, genericports.ErrInvalidPatch) {
+		t.Errorf("Expected ErrInvalidPatch when patching id, got %v", err)
+	}
+
+	if _, err = storage.PatchObject(ctx, "missing", patch); !errors.Is(err, genericports.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing object, got %v", err)
+	}
+}
+
+// hiddenFieldsOrder has fields that JSON doesn't carry, a patch must keep them
+type hiddenFieldsOrder struct {
+	ID       string            `json:"id"`
+	Tags     map[string]string `json:"tags"`
+	Internal string            `json:"-"`
+	version  int
+}
+
+func (o hiddenFieldsOrder) GetUniqueIdentifier() string { return o.ID }
+
+func TestApplyPatchKeepsHiddenFields(t *testing.T) {
+	object := &hiddenFieldsOrder{ID: "order1", Tags: map[string]string{"a": "1"}, Internal: "secret", version: 3}
+
+	patch, _ := genericports.NewMergePatch([]byte(`{"tags": {"a": null, "b": "2"}}`))
+	patched, err := genericports.ApplyPatch(object, patch)
+	if err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+	if patched.Internal != "secret" || patched.version != 3 {
+		t.Errorf("Expected fields without JSON form to be kept, got %+v", patched)
+	}
+	if len(patched.Tags) != 1 || patched.Tags["b"] != "2" {
+		t.Errorf("Expected tags to be merged, got %v", patched.Tags)
+	}
+	if len(object.Tags) != 1 || object.Tags["a"] != "1" {
+		t.Errorf("Expected the original object not to change, got %v", object.Tags)
+	}
+
+	storage := genericportstest.NewInMemoryStorage[string, hiddenFieldsOrder]()
+	_, _ = storage.CreateObject(context.Background(), object)
+	if _, err = storage.PatchObject(context.Background(), "order1", patch); err != nil {
+		t.Fatalf("PatchObject failed: %v", err)
+	}
+	if stored, _ := storage.GetObjectByID(context.Background(), "order1"); stored.Internal != "secret" || stored.version != 3 {
+		t.Errorf("Expected the stored object to keep hidden fields, got %+v", stored)
+	}
+}
+
+type mongoPatchOrder struct {
+	ID   string            `json:"id" bson:"_id"`
+	Tags map[string]string `json:"tags" bson:"tags"`
+}
+
+func (o mongoPatchOrder) GetUniqueIdentifier() string { return o.ID }
+
+// TestMongoPatchObjectConcurrent requires TEST_MONGODB_URI, see TestConformanceMongoGenericStorage
+func TestMongoPatchObjectConcurrent(t *testing.T) {
+	_, collection := newMongoTestCollection(t)
+	dropMongoTestCollection(t, collection)
+	ctx := context.Background()
+	storage := storagegenericport.NewMongoGenericStorage[string, mongoPatchOrder](collection)
+	if _, err := storage.CreateObject(ctx, &mongoPatchOrder{ID: "order1", Tags: map[string]string{}}); err != nil {
+		t.Fatalf("CreateObject failed: %v", err)
+	}
+
+	// every patch rewrites the whole tags document, a lost update would drop a tag
+	const writers = 10
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			patch, _ := genericports.NewMergePatch([]byte(fmt.Sprintf(`{"tags": {"tag-%d": "set"}}`, i)))
+			for {
+				_, err := storage.PatchObject(ctx, "order1", patch)
+				if !errors.Is(err, genericports.ErrConflict) {
+					if err != nil {
+						t.Errorf("PatchObject failed: %v", err)
+					}
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, err := storage.GetObjectByID(ctx, "order1")
+	if err != nil || len(stored.Tags) != writers {
+		t.Errorf("Expected %d tags, got %v (%v)", writers, stored, err)
+	}
+}
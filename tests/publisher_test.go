@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/codec"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/publisher"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"testing"
+)
+
+func TestKafkaPublisherPublish(t *testing.T) {
+	ctx := newLoggerContext(t)
+	writer := &fakeMessageWriter{}
+	objectCodec := codec.NewMsgPack[conformanceObject]()
+	objects := publisher.NewKafkaPublisher[conformanceObject](writer, "objects", objectCodec)
+
+	object := newConformanceObject(1)
+	if err := objects.Publish(ctx, object.ID, *object, map[string]string{"source": "api"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if len(writer.batches) != 1 || len(writer.batches[0]) != 1 {
+		t.Fatalf("Expected 1 batch of 1 message, got %v", writer.keys())
+	}
+	message := writer.batches[0][0]
+	if message.Topic != "objects" || string(message.Key) != object.ID {
+		t.Errorf("Expected topic 'objects' and key '%s', got '%s' and '%s'", object.ID, message.Topic, message.Key)
+	}
+	if !reflect.DeepEqual(message.Headers, []kafka.Header{{Key: "source", Value: []byte("api")}}) {
+		t.Errorf("Expected the source header, got %v", message.Headers)
+	}
+	decoded, err := objectCodec.Decode(message.Value)
+	if err != nil || decoded != *object {
+		t.Errorf("Expected the value encoded by the codec, got %v (%v)", decoded, err)
+	}
+}
+
+func TestKafkaPublisherPublishBatch(t *testing.T) {
+	ctx := newLoggerContext(t)
+	writer := &fakeMessageWriter{}
+	objects := publisher.NewKafkaPublisher[conformanceObject](writer, "objects", codec.NewJSON[conformanceObject]())
+
+	err := objects.PublishBatch(ctx,
+		pkgports.OutgoingMessage[conformanceObject]{Key: "id-1", Value: *newConformanceObject(1)},
+		pkgports.OutgoingMessage[conformanceObject]{Key: "id-2", Value: *newConformanceObject(2),
+			Headers: map[string]string{"type": "updated"}},
+	)
+	if err != nil {
+		t.Fatalf("PublishBatch failed: %v", err)
+	}
+	if keys := writer.keys(); !reflect.DeepEqual(keys, [][]string{{"id-1", "id-2"}}) {
+		t.Fatalf("Expected one batch in order, got %v", keys)
+	}
+	first, second := writer.batches[0][0], writer.batches[0][1]
+	if len(first.Headers) != 0 || len(second.Headers) != 1 || string(second.Headers[0].Value) != "updated" {
+		t.Errorf("Expected headers of every message, got %v and %v", first.Headers, second.Headers)
+	}
+	if string(first.Value) != `{"id":"id-1","name":"name-1"}` {
+		t.Errorf("Expected the JSON value, got %s", first.Value)
+	}
+
+	writer.fail = func(kafka.Message) bool { return true }
+	err = objects.Publish(ctx, "id-3", *newConformanceObject(3), nil)
+	var writeErrors kafka.WriteErrors
+	if !errors.As(err, &writeErrors) || !errors.Is(writeErrors[0], errOutboxWrite) {
+		t.Errorf("Expected the writer error, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/rediscache"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/pkgportstest"
+	"github.com/go-redis/redis/v8"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newRedisCache[K comparable, V any](t *testing.T, opts rediscache.Options[K, V]) (*rediscache.Cache[K, V], *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return rediscache.New(client, opts), server
+}
+
+func TestConformanceRedisCache(t *testing.T) {
+	var server *miniredis.Miniredis
+	pkgportstest.RunCacheSuite(t, pkgportstest.CacheSuite[string, conformanceObject]{
+		NewCache: func(t *testing.T, _ int) pkgports.Cache[string, conformanceObject] {
+			var cache *rediscache.Cache[string, conformanceObject]
+			cache, server = newRedisCache(t, rediscache.Options[string, conformanceObject]{Prefix: "orders"})
+			return cache
+		},
+		NewKey:    func(n int) string { return fmt.Sprintf("key%d", n) },
+		NewValue:  func(n int) conformanceObject { return *newConformanceObject(n) },
+		Unbounded: true,
+		Wait:      func(d time.Duration) { server.FastForward(d) },
+	})
+}
+
+func TestRedisCacheKeySetAndPrefix(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache, server := newRedisCache(t, rediscache.Options[int, string]{Prefix: "orders", TTL: time.Minute, ScanBatch: 2})
+
+	for i := range 5 {
+		if err := cache.Set(ctx, i, fmt.Sprint("order", i)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	_ = cache.SetWithTTL(ctx, 100, "forever", 0)
+
+	if !server.Exists("{orders}:v:3") || !server.Exists("{orders}:keys") {
+		t.Fatalf("Expected values and the key set under the prefix, got keys %v", server.Keys())
+	}
+	if ttl := server.TTL("{orders}:v:3"); ttl != time.Minute {
+		t.Errorf("Expected Options.TTL on Set, got %v", ttl)
+	}
+	if ttl := server.TTL("{orders}:v:100"); ttl != 0 {
+		t.Errorf("Expected no TTL for SetWithTTL(0), got %v", ttl)
+	}
+
+	// expired values are pruned from the key set
+	server.FastForward(2 * time.Minute)
+	keys, err := cache.GetKeys(ctx)
+	if err != nil {
+		t.Fatalf("GetKeys failed: %v", err)
+	}
+	if !slices.Equal(keys, []int{100}) {
+		t.Errorf("Expected keys [100] after expiration, got %v", keys)
+	}
+	if members, _ := server.ZMembers("{orders}:keys"); len(members) != 1 {
+		t.Errorf("Expected expired keys to be removed from the key set, got %v", members)
+	}
+
+	// another prefix is another namespace
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer func() { _ = client.Close() }()
+	other := rediscache.New(client, rediscache.Options[int, string]{Prefix: "users"})
+	if amount, _ := other.GetKeysAmount(ctx); amount != 0 {
+		t.Errorf("Expected an empty namespace, got %d keys", amount)
+	}
+	_ = other.Clear(ctx)
+	if _, found, _ := cache.Get(ctx, 100); !found {
+		t.Error("Expected Clear of another namespace to keep values")
+	}
+
+	// Clear reads the whole key set before deleting, so batches don't skip keys
+	for i := range 7 {
+		_ = cache.Set(ctx, i, "again")
+	}
+	if err = cache.Clear(ctx); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if amount, _ := cache.GetKeysAmount(ctx); amount != 0 || server.Exists("{orders}:keys") {
+		t.Errorf("Expected no keys after Clear, got %d, keys %v", amount, server.Keys())
+	}
+}
+
+func TestRedisCachePrunesExpiredOnWrite(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache, server := newRedisCache(t, rediscache.Options[int, string]{Prefix: "orders", ScanBatch: 2})
+
+	for i := range 5 {
+		_ = cache.SetWithTTL(ctx, i, "short", 20*time.Millisecond)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// every write removes up to ScanBatch expired members, without GetKeys
+	_ = cache.SetWithTTL(ctx, 100, "forever", 0)
+	if members, _ := server.ZMembers("{orders}:keys"); len(members) != 4 {
+		t.Errorf("Expected 2 of 5 expired members to be pruned by a write, got %v", members)
+	}
+	_ = cache.SetWithTTL(ctx, 101, "forever", 0)
+	_ = cache.SetWithTTL(ctx, 102, "forever", 0)
+	members, _ := server.ZMembers("{orders}:keys")
+	slices.Sort(members)
+	if !slices.Equal(members, []string{"100", "101", "102"}) {
+		t.Errorf("Expected expired members to be pruned by writes, got %v", members)
+	}
+	if score, _ := server.ZScore("{orders}:keys", "100"); !math.IsInf(score, 1) {
+		t.Errorf("Expected a member without TTL to be scored +inf, got %v", score)
+	}
+}
+
+func TestRedisCacheStats(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache, server := newRedisCache(t, rediscache.Options[string, int]{})
+
+	_ = cache.Set(ctx, "a", 1)
+	_ = cache.Set(ctx, "b", 2)
+	_, _, _ = cache.Get(ctx, "a")
+	_, _, _ = cache.Get(ctx, "missing")
+	_ = cache.Delete(ctx, "a")
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Sets != 2 || stats.Loads != 2 {
+		t.Errorf("Expected 1 hit, 1 miss, 2 sets and 2 loads, got %+v", stats)
+	}
+	if stats.Evictions["deleted"] != 1 || stats.Size != 1 {
+		t.Errorf("Expected 1 deletion and size 1, got %+v", stats)
+	}
+
+	// a failing Redis doesn't make the cache look empty
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	if stats = cache.Stats(); stats.Size != 1 {
+		t.Errorf("Expected the last known size 1 when Redis fails, got %d", stats.Size)
+	}
+}
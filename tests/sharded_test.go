@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/sharded"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/pkgportstest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConformanceShardedCache(t *testing.T) {
+	// one shard is exactly an LRU
+	pkgportstest.RunCacheSuite(t, pkgportstest.CacheSuite[string, int]{
+		NewCache: func(t *testing.T, capacity int) pkgports.Cache[string, int] {
+			return sharded.NewCache[string, int](sharded.Options{Shards: 1, Capacity: capacity})
+		},
+		NewKey:   func(n int) string { return fmt.Sprintf("key%d", n) },
+		NewValue: func(n int) int { return n },
+		LRU:      true,
+	})
+}
+
+func TestConformanceShardedCacheGlobalLRU(t *testing.T) {
+	// comparing all shards is an exact LRU too
+	pkgportstest.RunCacheSuite(t, pkgportstest.CacheSuite[string, int]{
+		NewCache: func(t *testing.T, capacity int) pkgports.Cache[string, int] {
+			return sharded.NewCache[string, int](sharded.Options{Shards: 4, Samples: 4, Capacity: capacity, GlobalLRU: true})
+		},
+		NewKey:   func(n int) string { return fmt.Sprintf("key%d", n) },
+		NewValue: func(n int) int { return n },
+		LRU:      true,
+	})
+}
+
+func TestShardedCacheSplitsCapacity(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := sharded.NewCache[int, int](sharded.Options{Shards: 5, Capacity: 100})
+
+	if cache.ShardsAmount() != 8 {
+		t.Errorf("Expected shards to be rounded up to 8, got %d", cache.ShardsAmount())
+	}
+
+	for i := 0; i < 1000; i++ {
+		if err := cache.Set(ctx, i, i); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	amount, err := cache.GetKeysAmount(ctx)
+	if err != nil {
+		t.Fatalf("GetKeysAmount failed: %v", err)
+	}
+	if amount == 0 || amount > 100 {
+		t.Errorf("Expected up to 100 keys in all shards, got %d", amount)
+	}
+
+	keys, err := cache.GetKeys(ctx)
+	if err != nil {
+		t.Fatalf("GetKeys failed: %v", err)
+	}
+	if len(keys) != amount {
+		t.Errorf("Expected GetKeys to aggregate %d keys, got %d", amount, len(keys))
+	}
+	for _, key := range keys {
+		if value, found, _ := cache.Peek(ctx, key); !found || value != key {
+			t.Errorf("Expected listed key %d to be found with its value, got %d (found %v)", key, value, found)
+		}
+	}
+}
+
+func TestShardedCacheGlobalLRUKeepsHotKeys(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := sharded.NewCache[int, int](sharded.Options{Shards: 8, Samples: 8, Capacity: 64, GlobalLRU: true})
+
+	// hot keys are read after every insert, so they're never the least used ones
+	hot := []int{-1, -2, -3, -4}
+	for _, key := range hot {
+		if err := cache.Set(ctx, key, key); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if err := cache.Set(ctx, i, i); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		for _, key := range hot {
+			_, _, _ = cache.Get(ctx, key)
+		}
+	}
+
+	amount, _ := cache.GetKeysAmount(ctx)
+	if amount != 64 {
+		t.Errorf("Expected the whole capacity 64 to be used, got %d", amount)
+	}
+	for _, key := range hot {
+		if found, _ := cache.Contains(ctx, key); !found {
+			t.Errorf("Expected hot key %d to survive global eviction", key)
+		}
+	}
+	if found, _ := cache.Contains(ctx, 0); found {
+		t.Error("Expected the oldest key to be evicted")
+	}
+}
+
+func TestShardedCacheConcurrentAccess(t *testing.T) {
+	ctx := newLoggerContext(t)
+
+	for _, globalLRU := range []bool{false, true} {
+		cache := sharded.NewCache[int, int](sharded.Options{Shards: 4, Capacity: 32, GlobalLRU: globalLRU})
+
+		var wg sync.WaitGroup
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					key := (worker*31 + i) % 100
+					switch i % 4 {
+					case 0:
+						_ = cache.Set(ctx, key, i)
+					case 1:
+						_ = cache.Delete(ctx, key)
+					default:
+						_, _, _ = cache.Get(ctx, key)
+					}
+				}
+			}(worker)
+		}
+		wg.Wait()
+
+		if amount, _ := cache.GetKeysAmount(ctx); amount > 32 {
+			t.Errorf("Expected up to 32 keys (global LRU %v), got %d", globalLRU, amount)
+		}
+	}
+}
+
+func TestShardedCacheRemoveExpired(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := sharded.NewCache[int, int](sharded.Options{Shards: 4, Capacity: 100, TTL: 20 * time.Millisecond})
+
+	for i := 0; i < 50; i++ {
+		_ = cache.Set(ctx, i, i)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if removed := cache.RemoveExpired(ctx); removed != 50 {
+		t.Errorf("Expected 50 expired keys to be removed from all shards, got %d", removed)
+	}
+	if amount, _ := cache.GetKeysAmount(ctx); amount != 0 {
+		t.Errorf("Expected no keys, got %d", amount)
+	}
+}
+
+func TestShardedCacheMaxCost(t *testing.T) {
+	ctx := newLoggerContext(t)
+	for _, globalLRU := range []bool{false, true} {
+		cache := sharded.NewCache[int, string](sharded.Options{
+			Shards: 4, MaxCost: 400, GlobalLRU: globalLRU,
+			Cost: func(value any) int64 { return int64(len(value.(string))) },
+		})
+
+		for i := range 1000 {
+			_ = cache.Set(ctx, i, "0123456789")
+		}
+		amount, _ := cache.GetKeysAmount(ctx)
+		if total := amount * 10; total > 400 || total < 300 {
+			t.Errorf("GlobalLRU %v: expected the cost of keys to be close to 400, got %d", globalLRU, total)
+		}
+	}
+}
+
+func TestShardedCacheCapacityBelowShards(t *testing.T) {
+	ctx := newLoggerContext(t)
+
+	cache := sharded.NewCache[int, int](sharded.Options{Shards: 16, Capacity: 5})
+	if cache.ShardsAmount() != 4 {
+		t.Errorf("Expected shards to be reduced to 4 for capacity 5, got %d", cache.ShardsAmount())
+	}
+
+	// every shard keeps at least one key, so a single one is never dropped on Set
+	single := sharded.NewCache[int, int](sharded.Options{Shards: 16, Capacity: 1})
+	if single.ShardsAmount() != 1 {
+		t.Errorf("Expected 1 shard for capacity 1, got %d", single.ShardsAmount())
+	}
+	for i := range 10 {
+		_ = single.Set(ctx, i, i)
+		if value, found, _ := single.Get(ctx, i); !found || value != i {
+			t.Errorf("Expected key %d to be found right after Set, got %d (found %v)", i, value, found)
+		}
+	}
+
+	costly := sharded.NewCache[int, string](sharded.Options{
+		Shards: 16, MaxCost: 3,
+		Cost: func(value any) int64 { return int64(len(value.(string))) },
+	})
+	if costly.ShardsAmount() > 2 {
+		t.Errorf("Expected up to 2 shards for max cost 3, got %d", costly.ShardsAmount())
+	}
+	_ = costly.Set(ctx, 1, "x")
+	if found, _ := costly.Contains(ctx, 1); !found {
+		t.Error("Expected a cheap key to be kept")
+	}
+}
@@ -0,0 +1,346 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/sharding"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type shardedStorage = genericportstest.InMemoryStorage[string, conformanceObject]
+
+func newShards(names ...string) ([]sharding.Shard[string, conformanceObject], map[string]*shardedStorage) {
+	shards := make([]sharding.Shard[string, conformanceObject], 0, len(names))
+	storages := make(map[string]*shardedStorage, len(names))
+	for _, name := range names {
+		storage := genericportstest.NewInMemoryStorage[string, conformanceObject]()
+		storages[name] = storage
+		shards = append(shards, sharding.Shard[string, conformanceObject]{Name: name, Storage: storage})
+	}
+	return shards, storages
+}
+
+func newRouter(t *testing.T, shards []sharding.Shard[string, conformanceObject], placement sharding.PlacementFactory) *sharding.Router[string, conformanceObject] {
+	t.Helper()
+	router, err := sharding.NewRouter(shards, sharding.Options[string]{Placement: placement})
+	if err != nil {
+		t.Fatalf("NewRouter failed: %v", err)
+	}
+	return router
+}
+
+func TestConformanceShardingRouter(t *testing.T) {
+	for name, placement := range map[string]sharding.PlacementFactory{
+		"rendezvous":      sharding.NewRendezvous,
+		"consistent hash": sharding.NewConsistentHash(16),
+	} {
+		t.Run(name, func(t *testing.T) {
+			genericportstest.RunStorageSuite(t, genericportstest.StorageSuite[string, conformanceObject]{
+				NewStorage: func(t *testing.T) genericports.GenericStoragePort[string, conformanceObject] {
+					shards, _ := newShards("a", "b", "c")
+					return newRouter(t, shards, placement)
+				},
+				NewObject: newConformanceObject,
+				Modify:    modifyConformanceObject,
+			})
+		})
+	}
+}
+
+func TestNewRouterInvalidShards(t *testing.T) {
+	storage := genericportstest.NewInMemoryStorage[string, conformanceObject]()
+
+	tests := map[string][]sharding.Shard[string, conformanceObject]{
+		"empty":           nil,
+		"no name":         {{Storage: storage}},
+		"no storage":      {{Name: "a"}},
+		"duplicate names": {{Name: "a", Storage: storage}, {Name: "a", Storage: storage}},
+	}
+	for name, shards := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := sharding.NewRouter(shards, sharding.Options[string]{}); !errors.Is(err, sharding.ErrInvalidShards) {
+				t.Errorf("Expected ErrInvalidShards, got %v", err)
+			}
+		})
+	}
+}
+
+func TestShardingRouterDistribution(t *testing.T) {
+	ctx := context.Background()
+	shards, storages := newShards("a", "b", "c")
+	router := newRouter(t, shards, nil)
+
+	for i := 0; i < 300; i++ {
+		if _, err := router.CreateObject(ctx, newConformanceObject(i)); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+	}
+
+	for name, storage := range storages {
+		objects, _ := storage.GetObjects(ctx)
+		if len(objects) < 50 {
+			t.Errorf("Shard %s got only %d of 300 objects", name, len(objects))
+		}
+		for _, object := range objects {
+			if owner := router.Locate(object.ID); owner != name {
+				t.Errorf("Object %s is stored in %s but located in %s", object.ID, name, owner)
+			}
+		}
+	}
+
+	objects, err := router.GetObjects(ctx)
+	if err != nil {
+		t.Fatalf("GetObjects failed: %v", err)
+	}
+	if len(objects) != 300 {
+		t.Errorf("Expected 300 objects from all shards, got %d", len(objects))
+	}
+}
+
+func TestPlacementStability(t *testing.T) {
+	for name, placement := range map[string]sharding.PlacementFactory{
+		"rendezvous":      sharding.NewRendezvous,
+		"consistent hash": sharding.NewConsistentHash(0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			before := placement([]string{"a", "b", "c"})
+			after := placement([]string{"a", "b", "c", "d"})
+
+			moved := 0
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprint(i)
+				from, to := before.Locate(key), after.Locate(key)
+				if from != to {
+					moved++
+					if to != "d" {
+						t.Fatalf("Key %s moved from %s to %s instead of the new shard", key, from, to)
+					}
+				}
+			}
+			// about a quarter of keys must move to the new shard
+			if moved < 150 || moved > 350 {
+				t.Errorf("Expected about 250 of 1000 keys to move, got %d", moved)
+			}
+		})
+	}
+}
+
+func TestShardingRouterMigration(t *testing.T) {
+	ctx := context.Background()
+	shards, _ := newShards("a", "b")
+	router := newRouter(t, shards, nil)
+
+	const amount = 100
+	for i := 0; i < amount; i++ {
+		if _, err := router.CreateObject(ctx, newConformanceObject(i)); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+	}
+
+	newShard, storages := newShards("c")
+	if err := router.StartMigration(append(shards, newShard...)); err != nil {
+		t.Fatalf("StartMigration failed: %v", err)
+	}
+	if err := router.StartMigration(shards); !errors.Is(err, sharding.ErrMigrationInProgress) {
+		t.Errorf("Expected ErrMigrationInProgress, got %v", err)
+	}
+
+	// objects are readable from old places, IDs stay unique
+	movedID := ""
+	for i := 0; i < amount; i++ {
+		object := newConformanceObject(i)
+		if _, err := router.GetObjectByID(ctx, object.ID); err != nil {
+			t.Fatalf("GetObjectByID %s during migration failed: %v", object.ID, err)
+		}
+		if _, err := router.CreateObject(ctx, object); !errors.Is(err, genericports.ErrConflict) {
+			t.Errorf("Expected ErrConflict for %s during migration, got %v", object.ID, err)
+		}
+		if movedID == "" && router.Locate(object.ID) == "c" {
+			movedID = object.ID
+		}
+	}
+	if movedID == "" {
+		t.Fatal("No object is placed in the new shard")
+	}
+
+	// update moves an object to its new owner
+	object, _ := router.GetObjectByID(ctx, movedID)
+	if _, err := router.UpdateObject(ctx, modifyConformanceObject(object)); err != nil {
+		t.Fatalf("UpdateObject failed: %v", err)
+	}
+	if stored, err := storages["c"].GetObjectByID(ctx, movedID); err != nil || stored.Name != object.Name {
+		t.Errorf("Expected updated object in the new shard, got %v, %v", stored, err)
+	}
+
+	objects, err := router.GetObjects(ctx)
+	if err != nil || len(objects) != amount {
+		t.Fatalf("Expected %d objects during migration, got %d, %v", amount, len(objects), err)
+	}
+
+	moved, err := router.Rebalance(ctx)
+	if err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	newObjects, _ := storages["c"].GetObjects(ctx)
+	if moved+1 != len(newObjects) {
+		t.Errorf("Expected %d moved objects in the new shard, got %d", moved+1, len(newObjects))
+	}
+
+	if err = router.FinishMigration(); err != nil {
+		t.Fatalf("FinishMigration failed: %v", err)
+	}
+	if err = router.FinishMigration(); !errors.Is(err, sharding.ErrNoMigration) {
+		t.Errorf("Expected ErrNoMigration, got %v", err)
+	}
+
+	for i := 0; i < amount; i++ {
+		id := newConformanceObject(i).ID
+		if _, err = router.GetObjectByID(ctx, id); err != nil {
+			t.Errorf("GetObjectByID %s after migration failed: %v", id, err)
+		}
+	}
+}
+
+func TestShardingRouterUpdateDuringRebalance(t *testing.T) {
+	ctx := context.Background()
+	shards, _ := newShards("a", "b")
+	router := newRouter(t, shards, nil)
+
+	const amount = 200
+	for i := 0; i < amount; i++ {
+		if _, err := router.CreateObject(ctx, newConformanceObject(i)); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+	}
+
+	newShard, _ := newShards("c", "d")
+	if err := router.StartMigration(append(shards, newShard...)); err != nil {
+		t.Fatalf("StartMigration failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if _, err := router.Rebalance(ctx); err != nil {
+			t.Errorf("Rebalance failed: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < amount; i++ {
+			// updates that race with the move of the same object mustn't be lost or fail
+			if _, err := router.UpdateObject(ctx, modifyConformanceObject(newConformanceObject(i))); err != nil {
+				t.Errorf("UpdateObject %d failed: %v", i, err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if _, err := router.Rebalance(ctx); err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if err := router.FinishMigration(); err != nil {
+		t.Fatalf("FinishMigration failed: %v", err)
+	}
+
+	objects, err := router.GetObjects(ctx)
+	if err != nil || len(objects) != amount {
+		t.Fatalf("Expected %d objects after migration, got %d (%v)", amount, len(objects), err)
+	}
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Name, "-modified") {
+			t.Errorf("Expected the update of %s to survive the move, got %q", object.ID, object.Name)
+		}
+	}
+}
+
+func TestShardingRouterMoveKeepsAuditFields(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	later := created.Add(time.Hour)
+
+	newAuditedShards := func(names ...string) ([]sharding.Shard[string, auditedObject], map[string]*genericportstest.InMemoryStorage[string, auditedObject]) {
+		shards := make([]sharding.Shard[string, auditedObject], 0, len(names))
+		storages := make(map[string]*genericportstest.InMemoryStorage[string, auditedObject], len(names))
+		for _, name := range names {
+			storage := genericportstest.NewInMemoryStorage[string, auditedObject]()
+			storage.SetClock(genericports.ClockFunc(func() time.Time { return created }))
+			storages[name] = storage
+			shards = append(shards, sharding.Shard[string, auditedObject]{Name: name, Storage: storage})
+		}
+		return shards, storages
+	}
+
+	shards, oldStorages := newAuditedShards("a")
+	router, err := sharding.NewRouter(shards, sharding.Options[string]{})
+	if err != nil {
+		t.Fatalf("NewRouter failed: %v", err)
+	}
+	const amount = 50
+	for i := 0; i < amount; i++ {
+		if _, err = router.CreateObject(ctx, &auditedObject{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+	}
+	_ = router.DeleteObject(ctx, "0")
+
+	newShard, newStorages := newAuditedShards("b")
+	for _, storage := range newStorages {
+		storage.SetClock(genericports.ClockFunc(func() time.Time { return later }))
+	}
+	if err = router.StartMigration(append(shards, newShard...)); err != nil {
+		t.Fatalf("StartMigration failed: %v", err)
+	}
+	if _, err = router.Rebalance(genericports.WithDeleted(ctx)); err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+
+	withDeleted := genericports.WithDeleted(ctx)
+	moved, _ := newStorages["b"].GetObjects(withDeleted)
+	if len(moved) == 0 {
+		t.Fatal("No object is moved to the new shard")
+	}
+	for _, object := range moved {
+		if !object.CreatedAt.Equal(created) || !object.UpdatedAt.Equal(created) {
+			t.Errorf("Expected %s to keep its timestamps, got %+v", object.ID, object)
+		}
+		if (object.ID == "0") != (object.DeletedAt != nil) {
+			t.Errorf("Expected only the soft-deleted object to stay deleted, got %+v", object)
+		}
+		// the source copy is removed, not soft-deleted
+		if _, err = oldStorages["a"].GetObjectByID(withDeleted, object.ID); !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected %s to be removed from the old shard, got %v", object.ID, err)
+		}
+	}
+}
+
+func TestShardingRouterFinishMigrationDuringRebalance(t *testing.T) {
+	ctx := context.Background()
+	shards, _ := newShards("a")
+	router := newRouter(t, shards, nil)
+	for i := 0; i < 200; i++ {
+		_, _ = router.CreateObject(ctx, newConformanceObject(i))
+	}
+
+	// the old shard is forgotten while Rebalance still moves its objects
+	newShard, _ := newShards("b")
+	if err := router.StartMigration(newShard); err != nil {
+		t.Fatalf("StartMigration failed: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := router.Rebalance(ctx)
+		done <- err
+	}()
+	_ = router.FinishMigration()
+	if err := <-done; err != nil {
+		t.Errorf("Rebalance failed: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/lru"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/memory"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheSnapshotRestoresOrderAndTTL(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := lru.NewCacheLRUInMemory[string, conformanceObject](10)
+	for _, object := range []*conformanceObject{newConformanceObject(1), newConformanceObject(2), newConformanceObject(3)} {
+		_ = cache.Set(ctx, object.ID, *object)
+	}
+	_ = cache.SetWithTTL(ctx, "short", *newConformanceObject(4), 50*time.Millisecond)
+	_ = cache.SetWithTTL(ctx, "long", *newConformanceObject(5), time.Hour)
+	_, _, _ = cache.Get(ctx, "id-1")
+
+	path := filepath.Join(t.TempDir(), "orders.snapshot")
+	if err := cache.SaveSnapshot(ctx, path, memory.SnapshotOptions[string, conformanceObject]{}); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	restored := lru.NewCacheLRUInMemory[string, conformanceObject](10)
+	amount, err := restored.LoadSnapshot(ctx, path, memory.SnapshotOptions[string, conformanceObject]{})
+	if err != nil || amount != 4 {
+		t.Fatalf("Expected 4 restored entries, got %d (%v)", amount, err)
+	}
+
+	keys, _ := restored.GetKeys(ctx)
+	if expected := []string{"id-1", "long", "id-3", "id-2"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected LRU order %v, got %v", expected, keys)
+	}
+	if value, found, _ := restored.Peek(ctx, "id-2"); !found || value != *newConformanceObject(2) {
+		t.Errorf("Expected the restored value, got %v (found %v)", value, found)
+	}
+
+	if err = restored.SaveSnapshot(ctx, path, memory.SnapshotOptions[string, conformanceObject]{}); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected temp files to be renamed or removed, got %d files", len(entries))
+	}
+}
+
+func TestMemoryCacheSnapshotRespectsCapacity(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := lru.NewCacheLRUInMemory[string, int](5)
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		_ = cache.Set(ctx, key, i)
+	}
+
+	var snapshot bytes.Buffer
+	if _, err := cache.WriteSnapshot(&snapshot, memory.SnapshotOptions[string, int]{}); err != nil {
+		t.Fatalf("WriteSnapshot failed: %v", err)
+	}
+
+	smaller := lru.NewCacheLRUInMemory[string, int](2)
+	if _, err := smaller.ReadSnapshot(ctx, &snapshot, memory.SnapshotOptions[string, int]{}); err != nil {
+		t.Fatalf("ReadSnapshot failed: %v", err)
+	}
+	if keys, _ := smaller.GetKeys(ctx); !reflect.DeepEqual(keys, []string{"e", "d"}) {
+		t.Errorf("Expected the most recent keys to survive, got %v", keys)
+	}
+}
+
+func TestMemoryCacheSnapshotErrors(t *testing.T) {
+	ctx := newLoggerContext(t)
+	cache := lru.NewCacheLRUInMemory[string, int](5)
+	opts := memory.SnapshotOptions[string, int]{}
+
+	if amount, err := cache.LoadSnapshot(ctx, filepath.Join(t.TempDir(), "missing"), opts); err != nil || amount != 0 {
+		t.Errorf("Expected a missing snapshot to restore nothing, got %d (%v)", amount, err)
+	}
+	if _, err := cache.ReadSnapshot(ctx, bytes.NewBufferString("not a snapshot"), opts); !errors.Is(err, memory.ErrSnapshotFormat) {
+		t.Errorf("Expected ErrSnapshotFormat, got %v", err)
+	}
+	if _, err := cache.ReadSnapshot(ctx, bytes.NewBufferString("SDLCACHE\x00\x02"), opts); !errors.Is(err, memory.ErrSnapshotVersion) {
+		t.Errorf("Expected ErrSnapshotVersion, got %v", err)
+	}
+
+	_ = cache.Set(ctx, "a", 1)
+	var snapshot bytes.Buffer
+	_, _ = cache.WriteSnapshot(&snapshot, opts)
+	truncated := snapshot.Bytes()[:snapshot.Len()-2]
+	if _, err := lru.NewCacheLRUInMemory[string, int](5).ReadSnapshot(ctx, bytes.NewReader(truncated), opts); !errors.Is(err, memory.ErrSnapshotFormat) {
+		t.Errorf("Expected a truncated snapshot to be ErrSnapshotFormat, got %v", err)
+	}
+}
+
+func TestMemoryCacheRunSnapshotsSavesOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(newLoggerContext(t))
+	cache := lru.NewCacheLRUInMemory[string, int](5)
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.RunSnapshots(ctx, path, time.Hour, memory.SnapshotOptions[string, int]{})
+	}()
+	_ = cache.Set(ctx, "a", 1)
+	cancel()
+	<-done
+
+	restored := lru.NewCacheLRUInMemory[string, int](5)
+	if amount, err := restored.LoadSnapshot(newLoggerContext(t), path, memory.SnapshotOptions[string, int]{}); err != nil || amount != 1 {
+		t.Errorf("Expected the shutdown snapshot with 1 entry, got %d (%v)", amount, err)
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	storagegenericport "github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/storage/genericport"
+	"testing"
+	"time"
+)
+
+// softDeleteStorage is an adapter under test with a replaceable clock
+type softDeleteStorage interface {
+	genericports.RestorableStoragePort[string, auditedObject]
+	SetClock(clock genericports.Clock)
+}
+
+func TestInMemorySoftDelete(t *testing.T) {
+	runSoftDeleteTests(t, func(t *testing.T) softDeleteStorage {
+		return genericportstest.NewInMemoryStorage[string, auditedObject]()
+	})
+}
+
+// TestMongoSoftDelete requires TEST_MONGODB_URI, see TestConformanceMongoGenericStorage
+func TestMongoSoftDelete(t *testing.T) {
+	_, collection := newMongoTestCollection(t)
+	runSoftDeleteTests(t, func(t *testing.T) softDeleteStorage {
+		dropMongoTestCollection(t, collection)
+		return storagegenericport.NewMongoGenericStorage[string, auditedObject](collection)
+	})
+}
+
+// runSoftDeleteTests checks audit fields, soft deletes and restores of a storage of auditedObject
+func runSoftDeleteTests(t *testing.T, newStorage func(t *testing.T) softDeleteStorage) {
+	// bson keeps milliseconds, so do the test clocks
+	created := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	deleted := created.Add(2 * time.Hour)
+
+	setup := func(t *testing.T) (context.Context, softDeleteStorage) {
+		ctx := newLoggerContext(t)
+		storage := newStorage(t)
+		storage.SetClock(genericports.ClockFunc(func() time.Time { return created }))
+		if _, err := storage.CreateObject(ctx, &auditedObject{ID: "1", Name: "first"}); err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+		return ctx, storage
+	}
+
+	t.Run("update keeps creation and deletion time", func(t *testing.T) {
+		ctx, storage := setup(t)
+		storage.SetClock(genericports.ClockFunc(func() time.Time { return updated }))
+
+		// the caller's object has neither creation nor deletion time, e.g. built from a request
+		result, err := storage.UpdateObject(ctx, &auditedObject{ID: "1", Name: "second", DeletedAt: &deleted})
+		if err != nil {
+			t.Fatalf("UpdateObject failed: %v", err)
+		}
+		stored, err := storage.GetObjectByID(ctx, "1")
+		if err != nil {
+			t.Fatalf("Expected the updated object to stay visible, got %v", err)
+		}
+		for _, object := range []*auditedObject{result, stored} {
+			if object.Name != "second" || !object.CreatedAt.Equal(created) || !object.UpdatedAt.Equal(updated) || object.DeletedAt != nil {
+				t.Errorf("Expected the new name, the stored creation time and the new update time, got %+v", object)
+			}
+		}
+	})
+
+	t.Run("soft-deleted objects are hidden", func(t *testing.T) {
+		ctx, storage := setup(t)
+		_, _ = storage.CreateObject(ctx, &auditedObject{ID: "2", Name: "kept"})
+		storage.SetClock(genericports.ClockFunc(func() time.Time { return deleted }))
+
+		if err := storage.DeleteObject(ctx, "1"); err != nil {
+			t.Fatalf("DeleteObject failed: %v", err)
+		}
+		if _, err := storage.GetObjectByID(ctx, "1"); !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if objects, err := storage.GetObjects(ctx); err != nil || len(objects) != 1 || objects[0].ID != "2" {
+			t.Errorf("Expected only the kept object, got %v (%v)", objects, err)
+		}
+		if err := storage.DeleteObject(ctx, "1"); !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+		}
+		if _, err := storage.UpdateObject(ctx, &auditedObject{ID: "1", Name: "second"}); !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound updating a deleted object, got %v", err)
+		}
+	})
+
+	t.Run("WithDeleted returns soft-deleted objects", func(t *testing.T) {
+		ctx, storage := setup(t)
+		storage.SetClock(genericports.ClockFunc(func() time.Time { return deleted }))
+		_ = storage.DeleteObject(ctx, "1")
+
+		withDeleted := genericports.WithDeleted(ctx)
+		object, err := storage.GetObjectByID(withDeleted, "1")
+		if err != nil {
+			t.Fatalf("Expected the deleted object, got %v", err)
+		}
+		if object.DeletedAt == nil || !object.DeletedAt.Equal(deleted) || !object.CreatedAt.Equal(created) {
+			t.Errorf("Expected deletion time %v and creation time %v, got %+v", deleted, created, object)
+		}
+		if objects, err := storage.GetObjects(withDeleted); err != nil || len(objects) != 1 {
+			t.Errorf("Expected 1 object, got %v (%v)", objects, err)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		ctx, storage := setup(t)
+		if _, err := storage.RestoreObject(ctx, "1"); !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound restoring an object that isn't deleted, got %v", err)
+		}
+
+		storage.SetClock(genericports.ClockFunc(func() time.Time { return deleted }))
+		_ = storage.DeleteObject(ctx, "1")
+		restored, err := storage.RestoreObject(ctx, "1")
+		if err != nil {
+			t.Fatalf("RestoreObject failed: %v", err)
+		}
+		if restored.DeletedAt != nil || restored.Name != "first" || !restored.CreatedAt.Equal(created) {
+			t.Errorf("Expected the restored object, got %+v", restored)
+		}
+		if _, err = storage.GetObjectByID(ctx, "1"); err != nil {
+			t.Errorf("Expected the restored object to be visible, got %v", err)
+		}
+	})
+
+	t.Run("preserved audit and hard delete", func(t *testing.T) {
+		ctx, storage := setup(t)
+		storage.SetClock(genericports.ClockFunc(func() time.Time { return updated }))
+
+		copied := &auditedObject{ID: "2", Name: "copy", CreatedAt: created, UpdatedAt: created, DeletedAt: &deleted}
+		result, err := storage.CreateObject(genericports.WithAuditPreserved(ctx), copied)
+		if err != nil {
+			t.Fatalf("CreateObject failed: %v", err)
+		}
+		if !result.CreatedAt.Equal(created) || !result.UpdatedAt.Equal(created) || result.DeletedAt == nil {
+			t.Errorf("Expected the given audit fields, got %+v", result)
+		}
+
+		for _, id := range []string{"1", "2"} {
+			if err = storage.DeleteObject(genericports.WithHardDelete(ctx), id); err != nil {
+				t.Fatalf("DeleteObject of '%s' failed: %v", id, err)
+			}
+			if _, err = storage.GetObjectByID(genericports.WithDeleted(ctx), id); !errors.Is(err, genericports.ErrNotFound) {
+				t.Errorf("Expected '%s' to be removed, got %v", id, err)
+			}
+		}
+	})
+}
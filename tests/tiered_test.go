@@ -0,0 +1,314 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports/genericportstest"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports"
+	cachegenericport "github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/genericport"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/memory"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/rediscache"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/cache/tiered"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/pkgportstest"
+	"github.com/go-redis/redis/v8"
+	"sync"
+	"testing"
+	"time"
+)
+
+const tieredChannel = "orders:invalidate"
+
+// newTieredReplica creates a tiered cache over the shared server and runs its invalidation until the test ends
+func newTieredReplica(t *testing.T, server *miniredis.Miniredis, l1TTL time.Duration) *tiered.Cache[string, int] {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	l2 := rediscache.New(client, rediscache.Options[string, int]{Prefix: "orders"})
+	cache, err := tiered.New[string, int](l2, client, tiered.Options[string]{
+		L1:      memory.Options{Capacity: 100, TTL: l1TTL},
+		Channel: tieredChannel,
+	})
+	if err != nil {
+		t.Fatalf("Error creating tiered cache: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(newLoggerContext(t))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return cache
+}
+
+// waitForSubscribers waits until n replicas listen to invalidations
+func waitForSubscribers(t *testing.T, server *miniredis.Miniredis, n int) {
+	t.Helper()
+	waitUntil(t, fmt.Sprintf("%d subscribers", n), func() bool {
+		return server.PubSubNumSub(tieredChannel)[tieredChannel] >= n
+	})
+}
+
+func waitUntil(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func TestConformanceTieredCache(t *testing.T) {
+	var server *miniredis.Miniredis
+	pkgportstest.RunCacheSuite(t, pkgportstest.CacheSuite[string, int]{
+		NewCache: func(t *testing.T, _ int) pkgports.Cache[string, int] {
+			server = miniredis.RunT(t)
+			// reads copy values to L1 for the L1 TTL, it's shorter than the TTL the suite checks
+			return newTieredReplica(t, server, 500*time.Millisecond)
+		},
+		NewKey:    func(n int) string { return fmt.Sprintf("key%d", n) },
+		NewValue:  func(n int) int { return n },
+		Unbounded: true,
+		// L1 expires by the real clock, L2 by the miniredis one
+		Wait: func(d time.Duration) {
+			server.FastForward(d)
+			time.Sleep(d)
+		},
+	})
+}
+
+func TestConformanceTieredGenericCache(t *testing.T) {
+	genericportstest.RunCachePortSuite(t, genericportstest.CachePortSuite[string, conformanceObject]{
+		NewCache: func(t *testing.T) genericports.GenericCachePort[string, conformanceObject] {
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { _ = client.Close() })
+
+			l2 := cachegenericport.NewRedisGenericCache[string, conformanceObject](server.Addr(), "", 0, 60000)
+			cache, err := tiered.NewGeneric[string, conformanceObject](l2, client, tiered.Options[string]{})
+			if err != nil {
+				t.Fatalf("Error creating tiered cache: %v", err)
+			}
+			return cache
+		},
+		NewObject: newConformanceObject,
+		Modify:    modifyConformanceObject,
+	})
+}
+
+func TestTieredCacheInvalidatesReplicas(t *testing.T) {
+	ctx := newLoggerContext(t)
+	server := miniredis.RunT(t)
+	a, b := newTieredReplica(t, server, time.Hour), newTieredReplica(t, server, time.Hour)
+	waitForSubscribers(t, server, 2)
+
+	_ = a.Set(ctx, "order", 1)
+	if value, found, _ := b.Get(ctx, "order"); !found || value != 1 {
+		t.Fatalf("Expected replica b to read 1 from L2, got %d (found %v)", value, found)
+	}
+	// the invalidation of the first Set may arrive during the read, then the value isn't copied to L1 yet
+	waitUntil(t, "the L2 hit to be copied to L1 of replica b", func() bool {
+		_, _, _ = b.Get(ctx, "order")
+		found, _ := b.L1().Contains(ctx, "order")
+		return found
+	})
+
+	_ = a.Set(ctx, "order", 2)
+	waitUntil(t, "replica b to read the new value", func() bool {
+		value, _, _ := b.Get(ctx, "order")
+		return value == 2
+	})
+
+	_ = a.Delete(ctx, "order")
+	waitUntil(t, "replica b to drop the deleted value", func() bool {
+		_, found, _ := b.Get(ctx, "order")
+		return !found
+	})
+
+	_ = b.Set(ctx, "other", 3)
+	_, _, _ = a.Get(ctx, "other")
+	_ = b.Clear(ctx)
+	waitUntil(t, "replica a to flush L1", func() bool {
+		return a.L1().Len() == 0
+	})
+
+	// the writer drops its own copy too, the next read fills L1 from L2
+	_, _, _ = a.Get(ctx, "own")
+	_ = a.Set(ctx, "own", 4)
+	if found, _ := a.L1().Contains(ctx, "own"); found {
+		t.Error("Expected the writer to drop its L1 copy")
+	}
+	if value, _, _ := a.Get(ctx, "own"); value != 4 {
+		t.Errorf("Expected the written value 4, got %d", value)
+	}
+	if value, found, _ := a.L1().Peek(ctx, "own"); !found || value != 4 {
+		t.Errorf("Expected the read to copy 4 to L1, got %d, found %v", value, found)
+	}
+}
+
+func TestTieredCacheFlushesL1OnReconnect(t *testing.T) {
+	ctx := newLoggerContext(t)
+	server := miniredis.RunT(t)
+	cache := newTieredReplica(t, server, time.Hour)
+	waitForSubscribers(t, server, 1)
+
+	_ = cache.Set(ctx, "order", 1)
+	_, _, _ = cache.Get(ctx, "order")
+
+	// an invalidation is missed while the connection is lost
+	server.Close()
+	_ = server.Set("{orders}:v:order", "2")
+	if err := server.Restart(); err != nil {
+		t.Fatalf("Error restarting redis: %v", err)
+	}
+
+	waitUntil(t, "L1 to be flushed after the reconnect", func() bool {
+		value, _, _ := cache.Get(ctx, "order")
+		return value == 2
+	})
+}
+
+func TestTieredCacheConcurrentLocalWrites(t *testing.T) {
+	ctx := newLoggerContext(t)
+	server := miniredis.RunT(t)
+	cache := newTieredReplica(t, server, time.Hour)
+
+	// writes of one key race with each other and with reads filling L1
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 20 {
+				_ = cache.Set(ctx, "order", i*20+j)
+				_, _, _ = cache.Get(ctx, "order")
+			}
+		}()
+	}
+	wg.Wait()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	l2Value, _, err := rediscache.New(client, rediscache.Options[string, int]{Prefix: "orders"}).Get(ctx, "order")
+	if err != nil {
+		t.Fatalf("Error reading L2: %v", err)
+	}
+	if value, found, _ := cache.L1().Peek(ctx, "order"); found && value != l2Value {
+		t.Errorf("Expected L1 to hold the L2 value %d, got %d", l2Value, value)
+	}
+	if value, _, _ := cache.Get(ctx, "order"); value != l2Value {
+		t.Errorf("Expected Get to return the L2 value %d, got %d", l2Value, value)
+	}
+}
+
+// pausedCacheL2 calls pause between reading L2 and returning the value, so a test can write in between
+type pausedCacheL2 struct {
+	pkgports.Cache[string, int]
+	pause func()
+}
+
+func (c *pausedCacheL2) Get(ctx context.Context, key string) (int, bool, error) {
+	value, found, err := c.Cache.Get(ctx, key)
+	c.pause()
+	return value, found, err
+}
+
+// pausedGenericL2 is pausedCacheL2 for GenericCache
+type pausedGenericL2 struct {
+	genericports.GenericCachePort[string, conformanceObject]
+	pause func()
+}
+
+func (c *pausedGenericL2) GetObjectByID(ctx context.Context, id string) (*conformanceObject, error) {
+	object, err := c.GenericCachePort.GetObjectByID(ctx, id)
+	c.pause()
+	return object, err
+}
+
+func TestTieredCacheLocalWriteDuringRead(t *testing.T) {
+	ctx := newLoggerContext(t)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	l2 := &pausedCacheL2{Cache: rediscache.New(client, rediscache.Options[string, int]{Prefix: "orders"})}
+	cache, err := tiered.New[string, int](l2, client, tiered.Options[string]{
+		L1:      memory.Options{Capacity: 100},
+		Channel: tieredChannel,
+	})
+	if err != nil {
+		t.Fatalf("Error creating tiered cache: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		write func()
+	}{
+		{name: "Set", write: func() { _ = cache.Set(ctx, "order", 2) }},
+		{name: "SetWithTTL", write: func() { _ = cache.SetWithTTL(ctx, "order", 2, time.Hour) }},
+		{name: "Delete", write: func() { _ = cache.Delete(ctx, "order") }},
+		{name: "Clear", write: func() { _ = cache.Clear(ctx) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = l2.Cache.Set(ctx, "order", 1)
+			_ = cache.L1().Delete(ctx, "order")
+
+			// the write lands after Get has read the old value from L2
+			l2.pause = tt.write
+			_, _, _ = cache.Get(ctx, "order")
+			l2.pause = func() {}
+
+			if value, found, _ := cache.L1().Peek(ctx, "order"); found && value == 1 {
+				t.Error("Expected the old L2 value not to be copied to L1 over a local write")
+			}
+		})
+	}
+}
+
+func TestTieredGenericCacheLocalWriteDuringRead(t *testing.T) {
+	ctx := newLoggerContext(t)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	l2 := &pausedGenericL2{GenericCachePort: cachegenericport.NewRedisGenericCache[string, conformanceObject](server.Addr(), "", 0, 60000)}
+	cache, err := tiered.NewGeneric[string, conformanceObject](l2, client, tiered.Options[string]{
+		L1:      memory.Options{Capacity: 100},
+		Channel: tieredChannel,
+	})
+	if err != nil {
+		t.Fatalf("Error creating tiered cache: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		write func()
+	}{
+		{name: "SaveObject", write: func() { _, _ = cache.SaveObject(ctx, modifyConformanceObject(newConformanceObject(1))) }},
+		{name: "DeleteObject", write: func() { _ = cache.DeleteObject(ctx, "id-1") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _ = l2.GenericCachePort.SaveObject(ctx, newConformanceObject(1))
+			_ = cache.L1().Delete(ctx, "id-1")
+
+			// the write lands after GetObjectByID has read the old object from L2
+			l2.pause = tt.write
+			_, _ = cache.GetObjectByID(ctx, "id-1")
+			l2.pause = func() {}
+
+			if object, found, _ := cache.L1().Peek(ctx, "id-1"); found && object.Name == "name-1" {
+				t.Error("Expected the old L2 object not to be copied to L1 over a local write")
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/genericports"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/mongodb"
+	storagegenericport "github.com/chempik1234/super-danis-library-golang/v2/pkg/pkgports/adapters/storage/genericport"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/postgres"
+	"github.com/jackc/pgx/v5"
+	"testing"
+)
+
+var errTransactorTest = errors.New("transactor test error")
+
+// transactorCase is a scenario run by every Transactor implementation
+type transactorCase struct {
+	name string
+	// run calls WithinTransaction, returns IDs that must exist and IDs that mustn't after it
+	run func(t *testing.T, ctx context.Context, transactor genericports.Transactor,
+		storage genericports.GenericStoragePort[string, conformanceObject]) (created, missing []string)
+}
+
+// withinTransactionPanic runs WithinTransaction and recovers the panic that must be re-raised
+func withinTransactionPanic(t *testing.T, ctx context.Context, transactor genericports.Transactor, fn func(ctx context.Context) error) {
+	t.Helper()
+	defer func() {
+		if p := recover(); p != errTransactorTest {
+			t.Errorf("Expected the panic to be re-raised, got %v", p)
+		}
+	}()
+	_ = transactor.WithinTransaction(ctx, fn)
+}
+
+var transactorCases = []transactorCase{
+	{
+		name: "commit",
+		run: func(t *testing.T, ctx context.Context, transactor genericports.Transactor, storage genericports.GenericStoragePort[string, conformanceObject]) ([]string, []string) {
+			err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+				if _, err := storage.CreateObject(ctx, newConformanceObject(1)); err != nil {
+					return err
+				}
+				_, err := storage.CreateObject(ctx, newConformanceObject(2))
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WithinTransaction failed: %v", err)
+			}
+			return []string{"id-1", "id-2"}, nil
+		},
+	},
+	{
+		name: "rollback on error",
+		run: func(t *testing.T, ctx context.Context, transactor genericports.Transactor, storage genericports.GenericStoragePort[string, conformanceObject]) ([]string, []string) {
+			err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+				if _, err := storage.CreateObject(ctx, newConformanceObject(1)); err != nil {
+					return err
+				}
+				return errTransactorTest
+			})
+			if !errors.Is(err, errTransactorTest) {
+				t.Errorf("Expected the error of fn, got %v", err)
+			}
+			return nil, []string{"id-1"}
+		},
+	},
+	{
+		name: "rollback on panic",
+		run: func(t *testing.T, ctx context.Context, transactor genericports.Transactor, storage genericports.GenericStoragePort[string, conformanceObject]) ([]string, []string) {
+			withinTransactionPanic(t, ctx, transactor, func(ctx context.Context) error {
+				if _, err := storage.CreateObject(ctx, newConformanceObject(1)); err != nil {
+					return err
+				}
+				panic(errTransactorTest)
+			})
+			return nil, []string{"id-1"}
+		},
+	},
+}
+
+// TestPostgresTransactor requires TEST_POSTGRES_DSN, see TestConformancePostgresGenericStorage
+func TestPostgresTransactor(t *testing.T) {
+	pool, storage := newPostgresTestStorage(t)
+	transactor := postgres.NewTransactor(pool, pgx.TxOptions{})
+
+	for _, tc := range transactorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			truncatePostgresTestTable(t, pool)
+			created, missing := tc.run(t, ctx, transactor, storage)
+			checkTransactorResult(t, ctx, storage, created, missing)
+		})
+	}
+
+	t.Run("nested savepoint rollback", func(t *testing.T) {
+		ctx := context.Background()
+		truncatePostgresTestTable(t, pool)
+
+		err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			if _, err := storage.CreateObject(ctx, newConformanceObject(1)); err != nil {
+				return err
+			}
+			innerErr := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+				if _, err := storage.CreateObject(ctx, newConformanceObject(2)); err != nil {
+					return err
+				}
+				return errTransactorTest
+			})
+			if !errors.Is(innerErr, errTransactorTest) {
+				t.Errorf("Expected the error of the nested fn, got %v", innerErr)
+			}
+			// the outer transaction goes on after the savepoint is rolled back
+			_, err := storage.CreateObject(ctx, newConformanceObject(3))
+			return err
+		})
+		if err != nil {
+			t.Fatalf("WithinTransaction failed: %v", err)
+		}
+		checkTransactorResult(t, ctx, storage, []string{"id-1", "id-3"}, []string{"id-2"})
+	})
+
+	t.Run("adapters use the transaction from ctx", func(t *testing.T) {
+		ctx := context.Background()
+		truncatePostgresTestTable(t, pool)
+
+		err := transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
+			if _, ok := postgres.TxFromContext(txCtx); !ok {
+				t.Error("Expected TxFromContext to return the transaction")
+			}
+			if _, err := storage.CreateObject(txCtx, newConformanceObject(1)); err != nil {
+				return err
+			}
+			if _, err := storage.GetObjectByID(txCtx, "id-1"); err != nil {
+				t.Errorf("Expected the transaction to see its own insert, got %v", err)
+			}
+			if _, err := storage.GetObjectByID(ctx, "id-1"); !errors.Is(err, genericports.ErrNotFound) {
+				t.Errorf("Expected the pool not to see the uncommitted insert, got %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithinTransaction failed: %v", err)
+		}
+		if _, ok := postgres.TxFromContext(ctx); ok {
+			t.Error("Expected no transaction outside WithinTransaction")
+		}
+	})
+}
+
+// TestMongoTransactor requires TEST_MONGODB_URI of a replica set, transactions aren't supported by a standalone server
+func TestMongoTransactor(t *testing.T) {
+	client, collection := newMongoTestCollection(t)
+	storage := storagegenericport.NewMongoGenericStorage[string, conformanceObject](collection)
+	transactor := mongodb.NewTransactor(client, nil)
+
+	// the collection is created outside transactions, older servers can't create it inside one
+	recreate := func(t *testing.T) {
+		dropMongoTestCollection(t, collection)
+		if err := collection.Database().CreateCollection(context.Background(), collection.Name()); err != nil {
+			t.Fatalf("Error creating collection: %v", err)
+		}
+	}
+
+	for _, tc := range transactorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			recreate(t)
+			created, missing := tc.run(t, ctx, transactor, storage)
+			checkTransactorResult(t, ctx, storage, created, missing)
+		})
+	}
+
+	t.Run("nested error aborts the whole transaction", func(t *testing.T) {
+		ctx := context.Background()
+		recreate(t)
+
+		err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			if _, err := storage.CreateObject(ctx, newConformanceObject(1)); err != nil {
+				return err
+			}
+			return transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+				if _, err := storage.CreateObject(ctx, newConformanceObject(2)); err != nil {
+					return err
+				}
+				return errTransactorTest
+			})
+		})
+		if !errors.Is(err, errTransactorTest) {
+			t.Errorf("Expected the error of the nested fn, got %v", err)
+		}
+		checkTransactorResult(t, ctx, storage, nil, []string{"id-1", "id-2"})
+	})
+
+	t.Run("adapters use the session from ctx", func(t *testing.T) {
+		ctx := context.Background()
+		recreate(t)
+
+		err := transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
+			if !mongodb.InTransaction(txCtx) {
+				t.Error("Expected InTransaction to be true")
+			}
+			if _, err := storage.CreateObject(txCtx, newConformanceObject(1)); err != nil {
+				return err
+			}
+			if _, err := storage.GetObjectByID(txCtx, "id-1"); err != nil {
+				t.Errorf("Expected the transaction to see its own insert, got %v", err)
+			}
+			if _, err := storage.GetObjectByID(ctx, "id-1"); !errors.Is(err, genericports.ErrNotFound) {
+				t.Errorf("Expected reads outside the session not to see the uncommitted insert, got %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithinTransaction failed: %v", err)
+		}
+		checkTransactorResult(t, ctx, storage, []string{"id-1"}, nil)
+	})
+}
+
+func checkTransactorResult(t *testing.T, ctx context.Context, storage genericports.GenericStoragePort[string, conformanceObject], created, missing []string) {
+	t.Helper()
+	for _, id := range created {
+		if _, err := storage.GetObjectByID(ctx, id); err != nil {
+			t.Errorf("Expected '%s' to be committed, got %v", id, err)
+		}
+	}
+	for _, id := range missing {
+		if _, err := storage.GetObjectByID(ctx, id); !errors.Is(err, genericports.ErrNotFound) {
+			t.Errorf("Expected '%s' to be rolled back, got %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/chempik1234/super-danis-library-golang/v2/pkg/types"
+	"testing"
+)
+
+type typesJSONModel struct {
+	ID       types.PositiveIntID `json:"id"`
+	UUID     types.UUID          `json:"uuid"`
+	Name     types.NotEmptyText  `json:"name"`
+	Birthday types.DateOnly      `json:"birthday"`
+	Created  types.DateTime      `json:"created"`
+}
+
+func TestTypesJSONRoundTrip(t *testing.T) {
+	input := `{"id":7,"uuid":"f47ac10b-58cc-4372-a567-0e02b2c3d479","name":"danis",` +
+		`"birthday":"2000-01-02","created":"2024-05-06 07:08:09"}`
+
+	var model typesJSONModel
+	if err := json.Unmarshal([]byte(input), &model); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if model.ID.Value() != 7 || model.Name.String() != "danis" || model.Birthday.String() != "2000-01-02" ||
+		model.UUID.String() != "f47ac10b-58cc-4372-a567-0e02b2c3d479" || model.Created.String() != "2024-05-06 07:08:09" {
+		t.Errorf("Unexpected unmarshalled model %+v", model)
+	}
+
+	output, err := json.Marshal(model)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(output) != input {
+		t.Errorf("Expected %s, got %s", input, output)
+	}
+}
+
+func TestTypesJSONValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "non-positive id", input: `{"id": 0}`},
+		{name: "null id", input: `{"id": null}`},
+		{name: "invalid uuid", input: `{"uuid": "not-a-uuid"}`},
+		{name: "empty text", input: `{"name": ""}`},
+		{name: "invalid date", input: `{"birthday": "2023-02-30"}`},
+		{name: "invalid datetime", input: `{"created": "2024-05-06"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var model typesJSONModel
+			if err := json.Unmarshal([]byte(tt.input), &model); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}